@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-rod/rod/lib/input"
+)
+
+// bannerJS writes the banner over the line of the prompt.
+const bannerJS = `(banner) => term.write("\x1b[2K\r" + banner)`
+
+// showBanner writes the Banner to the terminal, in place of the prompt, and
+// has the shell print its prompt again below it. The banner is written to
+// xterm.js rather than run in the shell, so that no command shows, and any
+// ANSI colors in it are drawn with the colors of the theme.
+func (vhs *VHS) showBanner() error {
+	if vhs.Options.Banner == "" {
+		return nil
+	}
+	banner := strings.TrimRight(vhs.Options.Banner, "\r\n")
+	banner = strings.ReplaceAll(strings.ReplaceAll(banner, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := vhs.Page.Eval(bannerJS, banner); err != nil {
+		return fmt.Errorf("could not show banner: %w", err)
+	}
+
+	// An empty command line only prints the prompt again.
+	if err := vhs.Page.Keyboard.Type(input.Enter); err != nil {
+		return fmt.Errorf("could not show banner: %w", err)
+	}
+	if vhs.Options.WaitPattern != "" {
+		if pattern, err := regexp.Compile(vhs.Options.WaitPattern); err == nil {
+			if err := vhs.WaitPattern(pattern, vhs.Options.WaitTimeout); err != nil {
+				return fmt.Errorf("could not show banner: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // JPEG frames
+	_ "image/png"  // PNG frames
+	"os"
+	"path/filepath"
+)
+
+// jpegTolerance is how far off the background the colors of a blank JPEG frame
+// may be, in each channel, since JPEG is lossy.
+const jpegTolerance = 8
+
+// isBlankFrame returns whether the frame only shows the background color.
+// Transparent pixels are blank too, since the background is drawn behind them.
+func isBlankFrame(frame []byte, background color.RGBA) (bool, error) {
+	img, format, err := image.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return false, err
+	}
+	var tolerance uint8
+	if format == "jpeg" {
+		tolerance = jpegTolerance
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if c.A != 0 && !nearColor(c, background, tolerance) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// nearColor returns whether the colors are at most the tolerance apart in each
+// channel.
+func nearColor(a, b color.RGBA, tolerance uint8) bool {
+	near := func(x, y uint8) bool {
+		if x > y {
+			return x-y <= tolerance
+		}
+		return y-x <= tolerance
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && near(a.A, b.A)
+}
+
+// leadingBlankFrames returns the number of blank frames at the start of the
+// recording, once the frames trimmed off the start are skipped, e.g. while the
+// prompt renders after the setup. Blank frames within the recording aren't
+// counted, and the last frame kept is never blank, so there's always a frame
+// left to render.
+func (vhs *VHS) leadingBlankFrames() (int, error) {
+	opts := vhs.Options.Video
+	background, err := parseHexColor(opts.Style.BackgroundColor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid background color: %w", err)
+	}
+
+	framerate := opts.captureFramerate()
+	start := opts.TrimStart.frames(framerate)
+	kept := vhs.totalFrames - start - opts.TrimEnd.frames(framerate)
+
+	blank := 0
+	for ; blank < kept-1; blank++ {
+		frame := opts.StartingFrame + start + blank
+		bts, err := os.ReadFile(filepath.Join(opts.Input, fmt.Sprintf(opts.textFrames(), frame)))
+		if err != nil {
+			return 0, fmt.Errorf("could not read frame %d: %w", frame, err)
+		}
+		if ok, err := isBlankFrame(bts, background); err != nil || !ok {
+			return blank, err
+		}
+	}
+	return blank, nil
+}
+
+// skipBlankFrames trims the blank frames at the start of the recording off
+// along with the frames trimmed off the start, if SkipBlankFrames is set.
+func (vhs *VHS) skipBlankFrames() error {
+	if !vhs.Options.Video.SkipBlankFrames {
+		return nil
+	}
+	blank, err := vhs.leadingBlankFrames()
+	if err != nil {
+		return fmt.Errorf("could not skip blank frames: %w", err)
+	}
+	start := vhs.Options.Video.TrimStart.frames(vhs.Options.Video.captureFramerate())
+	vhs.Options.Video.TrimStart = Trim{Frames: start + blank}
+	return nil
+}
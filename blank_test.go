@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFrame returns a PNG frame filled with the color, with a pixel of text if
+// set.
+func testFrame(t *testing.T, bg color.RGBA, text bool) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, bg)
+		}
+	}
+	if text {
+		img.Set(2, 2, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testJPEGFrame returns the test frame as a JPEG image.
+func testJPEGFrame(t *testing.T, bg color.RGBA, text bool) []byte {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(testFrame(t, bg, text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSkipBlankFrames(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	if err := os.MkdirAll(v.Options.Video.Input, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	bg, err := parseHexColor(v.Options.Video.Style.BackgroundColor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The blank frame in the middle is kept.
+	frames := []bool{false, false, false, true, false, true}
+	for i, text := range frames {
+		path := filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, i+1))
+		if err := os.WriteFile(path, testFrame(t, bg, text), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v.totalFrames = len(frames)
+
+	if err := v.skipBlankFrames(); err != nil || v.Options.Video.TrimStart.Frames != 0 {
+		t.Fatalf("expected no frames to be skipped by default, got %+v (%v)", v.Options.Video.TrimStart, err)
+	}
+
+	v.Options.Video.SkipBlankFrames = true
+	v.Options.Video.TrimStart = Trim{Frames: 1}
+	if err := v.skipBlankFrames(); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Options.Video.TrimStart; got.Frames != 3 {
+		t.Errorf("expected the first 3 frames to be trimmed, got %+v", got)
+	}
+
+	// A frame is kept when they're all blank.
+	v.Options.Video.TrimStart = Trim{}
+	v.Options.Video.TrimEnd = Trim{Frames: 3}
+	if err := v.skipBlankFrames(); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Options.Video.TrimStart; got.Frames != 2 {
+		t.Errorf("expected the last frame kept not to be trimmed, got %+v", got)
+	}
+}
+
+func TestIsBlankFrame(t *testing.T) {
+	bg := color.RGBA{R: 0x17, G: 0x17, B: 0x17, A: 0xff}
+	tests := []struct {
+		name  string
+		frame []byte
+		want  bool
+	}{
+		{"background", testFrame(t, bg, false), true},
+		{"transparent", testFrame(t, color.RGBA{}, false), true},
+		{"text", testFrame(t, bg, true), false},
+		{"other color", testFrame(t, color.RGBA{R: 0x18, G: 0x17, B: 0x17, A: 0xff}, false), false},
+		{"jpeg background", testJPEGFrame(t, bg, false), true},
+		{"jpeg text", testJPEGFrame(t, bg, true), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := isBlankFrame(tc.frame, bg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected blank to be %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
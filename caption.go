@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The positions of the caption, set with `Set CaptionPosition`.
+const (
+	captionTop    = "top"
+	captionBottom = "bottom"
+)
+
+const (
+	defaultCaptionPosition = captionBottom
+	defaultCaptionColor    = "#FFFFFF"
+	defaultCaptionFont     = "monospace"
+
+	// captionHeightRatio is the height of the output for each pixel of the
+	// caption's font size.
+	captionHeightRatio = 25
+	minCaptionSize     = 12
+)
+
+// captionFontSize returns the font size of the caption, which scales with the
+// height of the output.
+func captionFontSize(style *StyleOptions) int {
+	size := style.Height / captionHeightRatio
+	if size < minCaptionSize {
+		return minCaptionSize
+	}
+	return size
+}
+
+// isFontFile returns whether the caption font is a font file, rather than the
+// name of a font family.
+func isFontFile(font string) bool {
+	return fontFormats[strings.ToLower(filepath.Ext(font))] != ""
+}
+
+// escapeFilterValue escapes a value of an ffmpeg filter option, which is
+// quoted within the filter graph.
+func escapeFilterValue(s string) string {
+	s = filepath.ToSlash(s)
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "'", `'\''`)
+	s = strings.ReplaceAll(s, ":", `\:`)
+	return "'" + s + "'"
+}
+
+// drawtext returns the ffmpeg drawtext filter which draws the text of the file
+// as a caption. The text is read from a file, so that it doesn't need to be
+// escaped for the filter graph. An empty enable expression draws it on every
+// frame.
+func drawtext(style *StyleOptions, textFile, enable string) string {
+	font := style.CaptionFont
+	if font == "" {
+		font = defaultCaptionFont
+	}
+	fontOption := "font"
+	if isFontFile(font) {
+		fontOption = "fontfile"
+	}
+
+	size := captionFontSize(style)
+	y := fmt.Sprintf("h-text_h-%d", size)
+	if style.CaptionPosition == captionTop {
+		y = fmt.Sprint(size)
+	}
+
+	filter := fmt.Sprintf(
+		"drawtext=textfile=%s:expansion=none:%s=%s:fontsize=%d:fontcolor=%s:box=1:boxcolor=black@0.5:boxborderw=%d:x=(w-text_w)/2:y=%s",
+		escapeFilterValue(textFile),
+		fontOption,
+		escapeFilterValue(font),
+		size,
+		style.CaptionColor,
+		size/2, //nolint:gomnd
+		y,
+	)
+	if enable != "" {
+		filter += ":enable=" + escapeFilterValue(enable)
+	}
+	return filter
+}
+
+// The files in the input directory which hold the text of the caption of the
+// style, and of each Caption command, numbered in the order of the tape.
+const (
+	styleCaptionFile  = "caption.txt"
+	captionFileFormat = "caption-%d.txt"
+)
+
+// writeCaptions writes the texts of the captions to the input directory, once
+// for all the outputs, which read them while they are rendered.
+func (vhs *VHS) writeCaptions() error {
+	files := map[string]string{}
+	if vhs.Options.Video.Style.Caption != "" {
+		files[styleCaptionFile] = vhs.Options.Video.Style.Caption
+	}
+	for i, c := range vhs.captions {
+		files[fmt.Sprintf(captionFileFormat, i)] = c.text
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(vhs.Options.Video.Input, name), []byte(text), os.ModePerm); err != nil {
+			return fmt.Errorf("could not write caption: %w", err)
+		}
+	}
+	return nil
+}
+
+// captionUntilEnd is the last frame of a caption shown until the end of the
+// recording.
+const captionUntilEnd = math.MaxInt
+
+// caption is a caption shown over a range of the recorded frames.
+type caption struct {
+	text string
+	// first and last are the first and last frames the caption is shown on,
+	// both included.
+	first, last int
+}
+
+// TimedCaption is a caption shown over a range of the output.
+type TimedCaption struct {
+	Text string
+	// Start and End are the times the caption is shown from and until in the
+	// output, in seconds.
+	Start, End float64
+	// Index is the position of the caption among the Caption commands, which
+	// numbers the file its text is read from.
+	Index int
+}
+
+// enable returns the ffmpeg expression enabling a filter while the caption is
+// shown. Unlike between(t,start,end), the end is excluded so that a caption
+// and the one following it aren't both drawn on the frame where they meet.
+func (c TimedCaption) enable() string {
+	return fmt.Sprintf("gte(t,%.3f)*lt(t,%.3f)", c.Start, c.End)
+}
+
+// addCaption shows the text as a caption from the next frame captured, for the
+// given duration or until the next caption if it's zero. A caption ends the
+// one shown before it, so that they never overlap, and an empty text only ends
+// the previous caption.
+func (vhs *VHS) addCaption(text string, dur time.Duration) {
+	first := vhs.frames() + 1
+	if n := len(vhs.captions); n > 0 && vhs.captions[n-1].last >= first {
+		vhs.captions[n-1].last = first - 1
+	}
+	if text == "" {
+		return
+	}
+
+	c := caption{text: text, first: first, last: captionUntilEnd}
+	if dur > 0 {
+		frames := int(math.Round(dur.Seconds() * float64(vhs.Options.Video.captureFramerate())))
+		if frames < 1 {
+			frames = 1
+		}
+		c.last = first + frames - 1
+	}
+	vhs.captions = append(vhs.captions, c)
+}
+
+// captionTimes returns the times the captions are shown in the output. With
+// Boomerang, the captions are only shown while the frames play forward.
+func (vhs *VHS) captionTimes(tl timeline) []TimedCaption {
+	if tl.frames <= 0 {
+		return nil
+	}
+
+	var times []TimedCaption
+	for i, c := range vhs.captions {
+		// The positions of the frames of the caption once the recording is
+		// trimmed.
+		first := c.first - 1 - tl.trimStart
+		if first < 0 {
+			first = 0
+		}
+		last := c.last - 1 - tl.trimStart
+		if last > tl.frames-1 {
+			last = tl.frames - 1
+		}
+		if first > last {
+			continue
+		}
+
+		// The loop offset moves the frames before the offset to the end, which
+		// splits a caption shown across it in two.
+		offset, frames := tl.offset, tl.frames
+		switch {
+		case first >= offset:
+			times = append(times, TimedCaption{c.text, tl.seconds(first - offset), tl.seconds(last - offset + 1), i})
+		case last < offset:
+			times = append(times, TimedCaption{c.text, tl.seconds(first - offset + frames), tl.seconds(last - offset + frames + 1), i})
+		default:
+			times = append(times,
+				TimedCaption{c.text, tl.seconds(0), tl.seconds(last - offset + 1), i},
+				TimedCaption{c.text, tl.seconds(first - offset + frames), tl.seconds(frames), i},
+			)
+		}
+	}
+	return times
+}
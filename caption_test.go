@@ -0,0 +1,197 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildFFoptsCaption(t *testing.T) {
+	opts := testVideoOptions(t)
+	if args := strings.Join(buildFFopts(opts, "out.gif"), " "); strings.Contains(args, "drawtext") {
+		t.Errorf("expected no caption by default, got: %s", args)
+	}
+
+	opts.Style.Caption = "Building: the 'project'"
+	opts.Style.CaptionPosition = captionTop
+	args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+	textFile := filepath.Join(opts.Input, styleCaptionFile)
+	for _, want := range []string{
+		"drawtext=textfile=" + escapeFilterValue(textFile),
+		"font='monospace'",
+		"fontsize=24",
+		"fontcolor=#FFFFFF",
+		"y=24[captioned]",
+		"[captioned]split",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected the caption filter to contain %q, got: %s", want, args)
+		}
+	}
+
+	// The text is written by the render, rather than the builder, so that
+	// the outputs rendered in parallel don't write it over each other.
+	if _, err := os.Stat(textFile); !os.IsNotExist(err) {
+		t.Errorf("expected the caption text not to be written, got %v", err)
+	}
+}
+
+func TestDrawtextFont(t *testing.T) {
+	style := DefaultStyleOptions()
+	style.CaptionFont = "/fonts/JetBrains Mono.ttf"
+	if got := drawtext(style, "caption.txt", ""); !strings.Contains(got, "fontfile='/fonts/JetBrains Mono.ttf'") {
+		t.Errorf("expected a font file, got %s", got)
+	}
+	if got := drawtext(style, "caption.txt", "between(t,1,2)"); !strings.HasSuffix(got, ":enable='between(t,1,2)'") {
+		t.Errorf("expected the caption to be enabled between 1s and 2s, got %s", got)
+	}
+}
+
+func TestEscapeFilterValue(t *testing.T) {
+	for s, want := range map[string]string{
+		"/tmp/caption.txt":   `'/tmp/caption.txt'`,
+		"C:/tmp/caption.txt": `'C\:/tmp/caption.txt'`,
+		"it's":               `'it'\''s'`,
+	} {
+		if got := escapeFilterValue(s); got != want {
+			t.Errorf("expected %s to be escaped as %s, got %s", s, want, got)
+		}
+	}
+}
+
+func TestCaptionTimes(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.Video.Framerate = 10
+
+	// The first caption is shown for 1s, the second one until the third
+	// one replaces it, and the last one until the end.
+	v.addCaption("one", time.Second)
+	v.totalFrames = 20
+	v.addCaption("two", 0)
+	v.totalFrames = 25
+	v.addCaption("three", 2*time.Second)
+	v.totalFrames = 30
+	v.addCaption("four", 0)
+	v.totalFrames = 40
+
+	tests := []struct {
+		name       string
+		trimStart  int
+		loopOffset float64
+		speed      float64
+		want       []TimedCaption
+	}{
+		{
+			name:  "default",
+			speed: 1,
+			want: []TimedCaption{
+				{"one", 0, 1, 0},
+				{"two", 2, 2.5, 1},
+				{"three", 2.5, 3, 2},
+				{"four", 3, 4, 3},
+			},
+		},
+		{
+			name:  "playback speed",
+			speed: 2,
+			want: []TimedCaption{
+				{"one", 0, 0.5, 0},
+				{"two", 1, 1.25, 1},
+				{"three", 1.25, 1.5, 2},
+				{"four", 1.5, 2, 3},
+			},
+		},
+		{
+			name:      "trimmed",
+			trimStart: 5,
+			speed:     1,
+			want: []TimedCaption{
+				{"one", 0, 0.5, 0},
+				{"two", 1.5, 2, 1},
+				{"three", 2, 2.5, 2},
+				{"four", 2.5, 3.5, 3},
+			},
+		},
+		{
+			name:       "loop offset",
+			loopOffset: 25,
+			speed:      1,
+			want: []TimedCaption{
+				{"one", 3, 4, 0},
+				{"two", 1, 1.5, 1},
+				{"three", 1.5, 2, 2},
+				{"four", 2, 3, 3},
+			},
+		},
+		{
+			name:       "split by the loop offset",
+			loopOffset: 55,
+			speed:      1,
+			want: []TimedCaption{
+				{"one", 1.8, 2.8, 0},
+				{"two", 0, 0.3, 1},
+				{"two", 3.8, 4, 1},
+				{"three", 0.3, 0.8, 2},
+				{"four", 0.8, 1.8, 3},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v.Options.Video.TrimStart = Trim{Frames: tc.trimStart}
+			v.Options.LoopOffset = tc.loopOffset
+			v.Options.Video.PlaybackSpeed = tc.speed
+			got := v.captionTimes(v.timeline())
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected captions %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteCaptions(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	requireNoErr(t, os.MkdirAll(v.Options.Video.Input, os.ModePerm))
+	v.Options.Video.Style.Caption = "Building: the 'project'"
+	v.addCaption("one", 0)
+	v.addCaption("two", 0)
+	requireNoErr(t, v.writeCaptions())
+
+	// The texts are written as is, so that they don't need to be escaped.
+	for name, want := range map[string]string{
+		styleCaptionFile: v.Options.Video.Style.Caption,
+		"caption-0.txt":  "one",
+		"caption-1.txt":  "two",
+	} {
+		bts, err := os.ReadFile(filepath.Join(v.Options.Video.Input, name))
+		requireNoErr(t, err)
+		if string(bts) != want {
+			t.Errorf("expected %s to hold %q, got %q", name, want, bts)
+		}
+	}
+}
+
+func TestBuildFFoptsTimedCaptions(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.Style.Caption = "Demo"
+	opts.Captions = []TimedCaption{{"one", 0, 1.5, 0}, {"two", 2, 3, 1}}
+	args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+	for _, want := range []string{
+		"textfile=" + escapeFilterValue(filepath.Join(opts.Input, "caption.txt")),
+		":enable='not(gte(t,0.000)*lt(t,1.500)+gte(t,2.000)*lt(t,3.000))'",
+		"textfile=" + escapeFilterValue(filepath.Join(opts.Input, "caption-0.txt")),
+		":enable='gte(t,0.000)*lt(t,1.500)'",
+		"textfile=" + escapeFilterValue(filepath.Join(opts.Input, "caption-1.txt")),
+		":enable='gte(t,2.000)*lt(t,3.000)'[captioned]",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected the caption filters to contain %q, got: %s", want, args)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package vhs cast.go records the output written to the terminal as an
+// asciinema v2 cast file, so that recordings can be replayed as text.
+//
+// Output demo.cast
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// castFile is the name of the file in the input directory which holds the
+// cast events until they are moved to the output.
+const castFile = "session.cast"
+
+// castVersion is the asciinema file format version written by VHS.
+const castVersion = 2
+
+// castHeader is the first line of an asciinema v2 cast file.
+// https://docs.asciinema.org/manual/asciicast/v2/
+type castHeader struct {
+	Version   int   `json:"version"`
+	Width     int   `json:"width"`
+	Height    int   `json:"height"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// castEvent is a single timestamped event of an asciinema v2 cast file.
+// It is encoded as [time, type, data].
+type castEvent [3]interface{}
+
+// StartCast hooks into xterm.js so that every write to the terminal (output)
+// and every key sent to the terminal (input) is timestamped. The keys typed
+// while Echo is off are left out, so they can't be read from the cast, and
+// neither can their echo, which is masked before it's written.
+func (vhs *VHS) StartCast() {
+	vhs.castStart = time.Now()
+	vhs.Page.MustEval(`() => {
+		window.vhsCast = { start: performance.now(), events: [] };
+		const elapsed = () => (performance.now() - window.vhsCast.start) / 1000;
+		const decoder = new TextDecoder();
+		const write = term.write.bind(term);
+		term.write = (data, callback) => {
+			const s = typeof data === "string" ? data : decoder.decode(data, { stream: true });
+			window.vhsCast.events.push([elapsed(), "o", s]);
+			return write(data, callback);
+		};
+		term.onData((data) => {
+			if (!window.vhsEcho?.off) {
+				window.vhsCast.events.push([elapsed(), "i", data]);
+			}
+		});
+	}`)
+}
+
+// SaveCast writes the events collected since StartCast to the input
+// directory as an asciinema v2 cast file.
+func (vhs *VHS) SaveCast() error {
+	res, err := vhs.Page.Eval(`() => JSON.stringify({ cols: term.cols, rows: term.rows, events: window.vhsCast.events })`)
+	if err != nil {
+		return fmt.Errorf("could not read cast events: %w", err)
+	}
+
+	var session struct {
+		Cols   int         `json:"cols"`
+		Rows   int         `json:"rows"`
+		Events []castEvent `json:"events"`
+	}
+	if err := json.Unmarshal([]byte(res.Value.Str()), &session); err != nil {
+		return fmt.Errorf("could not parse cast events: %w", err)
+	}
+
+	f, err := os.Create(filepath.Join(vhs.Options.Video.Input, castFile))
+	if err != nil {
+		return fmt.Errorf("could not create cast file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	enc := json.NewEncoder(f)
+	if err := enc.Encode(castHeader{
+		Version:   castVersion,
+		Width:     session.Cols,
+		Height:    session.Rows,
+		Timestamp: vhs.castStart.Unix(),
+	}); err != nil {
+		return err
+	}
+	for _, event := range session.Events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MakeCast moves the recorded cast file to its output location.
+func MakeCast(opts VideoOptions) error {
+	if opts.Output.Cast == "" {
+		return nil
+	}
+
+	ensureDir(opts.Output.Cast)
+
+	bts, err := os.ReadFile(filepath.Join(opts.Input, castFile))
+	if err != nil {
+		return fmt.Errorf("could not read cast file: %w", err)
+	}
+	return os.WriteFile(opts.Output.Cast, bts, os.ModePerm)
+}
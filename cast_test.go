@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/vhs/parser"
+	"github.com/charmbracelet/vhs/token"
+)
+
+func TestMakeCast(t *testing.T) {
+	t.Run("unset output", func(t *testing.T) {
+		opts := testVideoOptions(t)
+		requireNoErr(t, MakeCast(opts))
+	})
+
+	t.Run("copies cast to output", func(t *testing.T) {
+		opts := testVideoOptions(t)
+		opts.Output.Cast = filepath.Join(t.TempDir(), "nested", "out.cast")
+
+		content := "{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":0}\n[0.1,\"o\",\"hello\"]\n"
+		if err := os.WriteFile(filepath.Join(opts.Input, castFile), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		requireNoErr(t, MakeCast(opts))
+
+		got, err := os.ReadFile(opts.Output.Cast)
+		requireNoErr(t, err)
+		if string(got) != content {
+			t.Errorf("expected %q, got %q", content, string(got))
+		}
+	})
+
+	t.Run("missing events", func(t *testing.T) {
+		opts := testVideoOptions(t)
+		opts.Output.Cast = filepath.Join(t.TempDir(), "out.cast")
+		requireErr(t, MakeCast(opts))
+	})
+}
+
+func TestStartCastEchoOff(t *testing.T) {
+	// A terminal which sends what's typed to the program, and echoes it.
+	page := testPage(t, `<script>
+		window.term = {
+			handlers: [],
+			onData(handler) { this.handlers.push(handler) },
+			write(data, callback) { callback && callback() },
+			type(data) { this.handlers.forEach((handler) => handler(data)); this.write(data) },
+		};
+		</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+	v.StartCast()
+
+	ExecuteEcho(parser.Command{Type: token.ECHO, Args: "off"}, &v)
+	page.MustEval(`() => term.type("secret")`)
+	ExecuteEcho(parser.Command{Type: token.ECHO, Args: "on"}, &v)
+	page.MustEval(`() => term.type("shown")`)
+
+	events := page.MustEval("() => JSON.stringify(window.vhsCast.events)").Str()
+	if strings.Contains(events, "secret") {
+		t.Errorf("expected the keys typed with Echo off to be left out of the cast, got %s", events)
+	}
+	if !strings.Contains(events, `"i","shown"`) || !strings.Contains(events, `"o","shown"`) {
+		t.Errorf("expected the keys typed with Echo on to be in the cast, got %s", events)
+	}
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +25,9 @@ import (
 
 // Execute executes a command on a running instance of vhs.
 func Execute(c parser.Command, v *VHS) {
+	start, frames := time.Now(), v.frames()
+	defer v.logCommand(c, start, frames)
+
 	if c.Type == token.SOURCE {
 		ExecuteSourceTape(c, v)
 	} else {
@@ -46,10 +54,12 @@ var CommandFuncs = map[parser.CommandType]CommandFunc{
 	token.RIGHT:      ExecuteKey(input.ArrowRight),
 	token.SPACE:      ExecuteKey(input.Space),
 	token.UP:         ExecuteKey(input.ArrowUp),
-	token.TAB:        ExecuteKey(input.Tab),
+	token.TAB:        ExecuteTab,
 	token.ESCAPE:     ExecuteKey(input.Escape),
 	token.PAGEUP:     ExecuteKey(input.PageUp),
 	token.PAGEDOWN:   ExecuteKey(input.PageDown),
+	token.HOME:       ExecuteKey(input.Home),
+	token.END:        ExecuteKey(input.End),
 	token.HIDE:       ExecuteHide,
 	token.REQUIRE:    ExecuteRequire,
 	token.SHOW:       ExecuteShow,
@@ -64,6 +74,13 @@ var CommandFuncs = map[parser.CommandType]CommandFunc{
 	token.SCREENSHOT: ExecuteScreenshot,
 	token.COPY:       ExecuteCopy,
 	token.PASTE:      ExecutePaste,
+	token.WAIT:       ExecuteWait,
+	token.ENV:        ExecuteEnv,
+	token.SELECT:     ExecuteSelect,
+	token.CAPTION:    ExecuteCaption,
+	token.ECHO:       ExecuteEcho,
+	token.TYPE_FILE:  ExecuteTypeFile,
+	token.EXEC:       ExecuteExec,
 }
 
 // ExecuteNoop is a no-op command that does nothing.
@@ -79,118 +96,267 @@ func ExecuteNoop(_ parser.Command, _ *VHS) {}
 // the ArrowDown key press.
 func ExecuteKey(k input.Key) CommandFunc {
 	return func(c parser.Command, v *VHS) {
-		typingSpeed, err := time.ParseDuration(c.Options)
-		if err != nil {
-			typingSpeed = v.Options.TypingSpeed
-		}
-		repeat, err := strconv.Atoi(c.Args)
-		if err != nil {
-			repeat = 1
-		}
-		for i := 0; i < repeat; i++ {
-			_ = v.Page.Keyboard.Type(k)
-			time.Sleep(typingSpeed)
-		}
+		pressKey(k, c, v, 0)
+	}
+}
+
+// ExecuteTab is a CommandFunc that presses the tab key, waiting for TabSettle
+// after each press so that shell completions have time to render.
+func ExecuteTab(c parser.Command, v *VHS) {
+	pressKey(input.Tab, c, v, v.Options.TabSettle)
+}
+
+// pressKey presses the key as many times as the command is repeated, waiting
+// for the typing speed and the given settle time after each press.
+func pressKey(k input.Key, c parser.Command, v *VHS, settle time.Duration) {
+	typingSpeed := commandTypingSpeed(c, v)
+	repeat, err := strconv.Atoi(c.Args)
+	if err != nil {
+		repeat = 1
+	}
+	for i := 0; i < repeat; i++ {
+		v.keystroke()
+		_ = v.Page.Keyboard.Type(k)
+		time.Sleep(typingSpeed + settle)
+	}
+}
+
+// commandTypingSpeed returns the typing speed for a single command. The
+// @<time> override (i.e. Type@100ms) takes precedence over the TypingSpeed
+// setting, which is left untouched so the override only lasts for the command.
+func commandTypingSpeed(c parser.Command, v *VHS) time.Duration {
+	typingSpeed, err := time.ParseDuration(c.Options)
+	if err != nil {
+		return v.Options.TypingSpeed
 	}
+	return typingSpeed
+}
+
+// modifierRepeat returns the number of times a key combination is pressed.
+func modifierRepeat(c parser.Command) int {
+	repeat, err := strconv.Atoi(c.Options)
+	if err != nil || repeat < 1 {
+		return 1
+	}
+	return repeat
 }
 
 // ExecuteCtrl is a CommandFunc that presses the argument keys and/or modifiers
 // with the ctrl key held down on the running instance of vhs.
 func ExecuteCtrl(c parser.Command, v *VHS) {
-	// Create key combination by holding ControlLeft
-	action := v.Page.KeyActions().Press(input.ControlLeft)
 	keys := strings.Split(c.Args, " ")
 
-	for i, key := range keys {
-		var inputKey *input.Key
-
-		switch key {
-		case "Shift":
-			inputKey = &input.ShiftLeft
-		case "Alt":
-			inputKey = &input.AltLeft
-		case "Enter":
-			inputKey = &input.Enter
-		case "Space":
-			inputKey = &input.Space
-		case "Backspace":
-			inputKey = &input.Backspace
-		default:
-			r := rune(key[0])
-			if k, ok := keymap[r]; ok {
-				inputKey = &k
-			}
+	for n := 0; n < modifierRepeat(c); n++ {
+		if n > 0 {
+			time.Sleep(v.Options.TypingSpeed)
 		}
 
-		// Press or hold key in case it's valid
-		if inputKey != nil {
-			if i != len(keys)-1 {
-				action.Press(*inputKey)
-			} else {
-				// Other keys will remain pressed until the combination reaches the end
-				action.Type(*inputKey)
+		v.keystroke()
+
+		// Create key combination by holding ControlLeft
+		action := v.Page.KeyActions().Press(input.ControlLeft)
+
+		for i, key := range keys {
+			var inputKey *input.Key
+
+			switch key {
+			case "Shift":
+				inputKey = &input.ShiftLeft
+			case "Alt":
+				inputKey = &input.AltLeft
+			case "Enter":
+				inputKey = &input.Enter
+			case "Space":
+				inputKey = &input.Space
+			case "Backspace":
+				inputKey = &input.Backspace
+			default:
+				r := rune(key[0])
+				if k, ok := keymap[r]; ok {
+					inputKey = &k
+				}
+			}
+
+			// Press or hold key in case it's valid
+			if inputKey != nil {
+				if i != len(keys)-1 {
+					action.Press(*inputKey)
+				} else {
+					// Other keys will remain pressed until the combination reaches the end
+					action.Type(*inputKey)
+				}
 			}
 		}
-	}
 
-	action.MustDo()
+		action.MustDo()
+	}
 }
 
 // ExecuteAlt is a CommandFunc that presses the argument key with the alt key
 // held down on the running instance of vhs.
 func ExecuteAlt(c parser.Command, v *VHS) {
-	_ = v.Page.Keyboard.Press(input.AltLeft)
-	if k, ok := token.Keywords[c.Args]; ok {
-		switch k {
-		case token.ENTER:
-			_ = v.Page.Keyboard.Type(input.Enter)
-		case token.TAB:
-			_ = v.Page.Keyboard.Type(input.Tab)
-		}
-	} else {
-		for _, r := range c.Args {
-			if k, ok := keymap[r]; ok {
-				_ = v.Page.Keyboard.Type(k)
-			}
-		}
-	}
-
-	_ = v.Page.Keyboard.Release(input.AltLeft)
+	executeModifier(input.AltLeft, c, v)
 }
 
 // ExecuteShift is a CommandFunc that presses the argument key with the shift
 // key held down on the running instance of vhs.
 func ExecuteShift(c parser.Command, v *VHS) {
-	_ = v.Page.Keyboard.Press(input.ShiftLeft)
-	if k, ok := token.Keywords[c.Args]; ok {
-		switch k {
-		case token.ENTER:
-			_ = v.Page.Keyboard.Type(input.Enter)
-		case token.TAB:
-			_ = v.Page.Keyboard.Type(input.Tab)
+	executeModifier(input.ShiftLeft, c, v)
+}
+
+// executeModifier presses the argument key with the modifier held down, as
+// many times as the command is repeated.
+func executeModifier(modifier input.Key, c parser.Command, v *VHS) {
+	for n := 0; n < modifierRepeat(c); n++ {
+		if n > 0 {
+			time.Sleep(v.Options.TypingSpeed)
 		}
-	} else {
-		for _, r := range c.Args {
-			if k, ok := keymap[r]; ok {
-				_ = v.Page.Keyboard.Type(k)
+
+		v.keystroke()
+		_ = v.Page.Keyboard.Press(modifier)
+		if k, ok := token.Keywords[c.Args]; ok {
+			switch k {
+			case token.ENTER:
+				_ = v.Page.Keyboard.Type(input.Enter)
+			case token.TAB:
+				_ = v.Page.Keyboard.Type(input.Tab)
+			}
+		} else {
+			for _, r := range c.Args {
+				if k, ok := keymap[r]; ok {
+					_ = v.Page.Keyboard.Type(k)
+				}
 			}
 		}
+		_ = v.Page.Keyboard.Release(modifier)
 	}
-
-	_ = v.Page.Keyboard.Release(input.AltLeft)
 }
 
-// ExecuteHide is a CommandFunc that starts or stops the recording of the vhs.
+// ExecuteHide is a CommandFunc that pauses the recording of the vhs. Commands
+// run while hidden are not captured in the output.
 func ExecuteHide(_ parser.Command, v *VHS) {
 	v.PauseRecording()
 }
 
+// ExecuteEnv is a CommandFunc that sets an environment variable of the shell.
+// The shell is started with the variables, so they must be set before it is.
+func ExecuteEnv(c parser.Command, v *VHS) {
+	if v.Options.Env == nil {
+		v.Options.Env = map[string]string{}
+	}
+	v.Options.Env[c.Options] = c.Args
+}
+
+// ExecuteSelect is a CommandFunc that selects the text from the start to the
+// end row and column of the screen, both included, or clears the selection if
+// there are none.
+func ExecuteSelect(c parser.Command, v *VHS) {
+	var startRow, startCol, endRow, endCol int
+	if _, err := fmt.Sscan(c.Args, &startRow, &startCol, &endRow, &endCol); err != nil {
+		evalTerm(v, "() => term.clearSelection()")
+		return
+	}
+	// The rows are relative to the screen, and term.select selects a number
+	// of cells from the start, wrapping at the end of the rows.
+	evalTerm(v, fmt.Sprintf(`() => {
+		const row = term.buffer.active.viewportY + %d;
+		term.select(%d, row, %d * term.cols + %d);
+	}`, startRow, startCol, endRow-startRow, endCol-startCol+1))
+}
+
+// ExecuteCaption is a CommandFunc that shows a caption from the next frame
+// captured, for the given time or until the next caption. The caption is
+// drawn over the frames when they are rendered.
+func ExecuteCaption(c parser.Command, v *VHS) {
+	var dur time.Duration
+	if c.Options != "" {
+		var err error
+		dur, err = time.ParseDuration(c.Options)
+		if err != nil || dur <= 0 {
+			v.Errors = append(v.Errors, fmt.Errorf("invalid `Caption %q %s`: time must be positive", c.Args, c.Options))
+			return
+		}
+	}
+	v.addCaption(c.Args, dur)
+}
+
+// ExecuteEcho is a CommandFunc that turns the echo of the terminal on or off.
+// The keys typed while the echo is off are hidden when the program prints them
+// back, like after `stty -echo`, while the rest of what it prints is shown.
+// Unlike stty, it also hides the keys shells which echo them themselves print,
+// e.g. with readline.
+func ExecuteEcho(c parser.Command, v *VHS) {
+	if v.Page == nil {
+		return
+	}
+	_, _ = v.Page.Eval(echoJS, c.Args == "off")
+}
+
+// echoJS turns the echo of the terminal on or off. The printable characters
+// of the input sent to the program while the echo is off are written over with
+// spaces once the program prints them back, so that the cursor still moves
+// like the program expects. They are matched in order with what the program
+// prints, skipping control characters and escape sequences, and anything else
+// it prints ends the match, e.g. when the program doesn't echo them at all.
+// The input typed before the echo is turned back on is still hidden, as the
+// program may print it afterwards.
+const echoJS = `(off) => {
+	if (!window.vhsEcho) {
+		const echo = { off: false, pending: [], escape: 0 };
+		const printable = (ch) => ch >= " " && ch !== "\x7f";
+		term.onData((data) => {
+			if (echo.off) {
+				echo.pending.push(...[...data].filter(printable));
+			}
+		});
+		const write = term.write.bind(term);
+		const decoder = new TextDecoder();
+		term.write = (data, callback) => {
+			if (echo.pending.length === 0) {
+				echo.escape = 0;
+				return write(data, callback);
+			}
+			if (typeof data !== "string") {
+				data = decoder.decode(data, { stream: true });
+			}
+			let out = "";
+			for (const ch of data) {
+				switch (echo.escape) {
+				case 1:
+					echo.escape = ch === "[" ? 2 : ch === "]" || ch === "P" ? 3 : 0;
+					break;
+				case 2:
+					echo.escape = ch >= "@" && ch <= "~" ? 0 : 2;
+					break;
+				case 3:
+					echo.escape = ch === "\x07" ? 0 : ch === "\x1b" ? 1 : 3;
+					break;
+				default:
+					if (ch === "\x1b") {
+						echo.escape = 1;
+					} else if (printable(ch) && ch === echo.pending[0]) {
+						echo.pending.shift();
+						out += " ";
+						continue;
+					} else if (printable(ch)) {
+						echo.pending = [];
+					}
+				}
+				out += ch;
+			}
+			return write(out, callback);
+		};
+		window.vhsEcho = echo;
+	}
+	window.vhsEcho.off = off;
+}`
+
 // ExecuteRequire is a CommandFunc that checks if all the binaries mentioned in the
-// Require command are present. If not, it exits with a non-zero error.
+// Require command are present. If not, an error is added to the vhs errors.
 func ExecuteRequire(c parser.Command, v *VHS) {
 	_, err := exec.LookPath(c.Args)
 	if err != nil {
-		v.Errors = append(v.Errors, err)
+		v.Errors = append(v.Errors, fmt.Errorf("`Require %s`: %s is not installed or not in $PATH", c.Args, c.Args))
 	}
 }
 
@@ -201,35 +367,180 @@ func ExecuteShow(_ parser.Command, v *VHS) {
 
 // ExecuteSleep sleeps for the desired time specified through the argument of
 // the Sleep command.
-func ExecuteSleep(c parser.Command, _ *VHS) {
+func ExecuteSleep(c parser.Command, v *VHS) {
 	dur, err := time.ParseDuration(c.Args)
 	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Sleep %s`: %w", c.Args, err))
+		return
+	}
+	if dur < 0 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Sleep %s`: time must not be negative", c.Args))
+		return
+	}
+	_ = v.sleep(dur)
+}
+
+// ExecuteWait is a CommandFunc that waits until the terminal matches the
+// regular expression argument, or the WaitPattern if there is none. The
+// @<time> option overrides the WaitTimeout.
+func ExecuteWait(c parser.Command, v *VHS) {
+	timeout := v.Options.WaitTimeout
+	if t, err := time.ParseDuration(c.Options); err == nil {
+		timeout = t
+	}
+
+	expr := c.Args
+	if expr == "" {
+		expr = v.Options.WaitPattern
+	}
+	if expr == "" {
+		v.Errors = append(v.Errors, errNoWaitPattern)
+		return
+	}
+
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Wait /%s/`: %w", expr, err))
 		return
 	}
-	time.Sleep(dur)
+
+	if err := v.WaitPattern(pattern, timeout); err != nil {
+		v.Errors = append(v.Errors, err)
+	}
+}
+
+// ExecuteExec is a CommandFunc that runs the argument command in the shell
+// without recording it. The recording is paused while the command is typed
+// and until the WaitPattern (by default, the prompt) matches again. With
+// ExecClear, the screen is cleared before it resumes, so nothing of the
+// command shows. The @<time> option overrides the WaitTimeout.
+func ExecuteExec(c parser.Command, v *VHS) {
+	if v.Options.WaitPattern == "" {
+		v.Errors = append(v.Errors, errNoWaitPattern)
+		return
+	}
+	pattern, err := regexp.Compile(v.Options.WaitPattern)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Exec %s`: %w", c.Args, err))
+		return
+	}
+	timeout := v.Options.WaitTimeout
+	if t, err := time.ParseDuration(c.Options); err == nil {
+		timeout = t
+	}
+
+	// Resume the recording afterwards only if it wasn't hidden already.
+	v.mutex.Lock()
+	recording := v.recording
+	v.mutex.Unlock()
+	v.PauseRecording()
+	if recording {
+		defer v.ResumeRecording()
+	}
+
+	typeLines(v, c.Args, 0)
+	_ = v.Page.Keyboard.Type(input.Enter)
+	if err := v.WaitPattern(pattern, timeout); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("`Exec %s`: %w", c.Args, err))
+	}
+	if v.Options.ExecClear {
+		evalTerm(v, "() => term.clear()")
+	}
 }
 
 // ExecuteType types the argument string on the running instance of vhs.
 func ExecuteType(c parser.Command, v *VHS) {
-	typingSpeed, err := time.ParseDuration(c.Options)
+	typeLines(v, c.Args, commandTypingSpeed(c, v))
+}
+
+// ExecuteTypeFile types the contents of the argument file on the running
+// instance of vhs, as it's read. The whole file is read at once with
+// BracketedPaste, so that it can be pasted.
+func ExecuteTypeFile(c parser.Command, v *VHS) {
+	f, err := os.Open(c.Args)
 	if err != nil {
-		typingSpeed = v.Options.TypingSpeed
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `TypeFile %s`: %w", c.Args, err))
+		return
+	}
+	defer f.Close() //nolint:errcheck
+
+	if v.Options.BracketedPaste {
+		bts, err := io.ReadAll(f)
+		if err != nil {
+			v.Errors = append(v.Errors, fmt.Errorf("invalid `TypeFile %s`: %w", c.Args, err))
+			return
+		}
+		typeLines(v, string(bts), commandTypingSpeed(c, v))
+		return
 	}
-	for _, r := range c.Args {
-		k, ok := keymap[r]
+	if err := typeText(v, bufio.NewReader(f), commandTypingSpeed(c, v)); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `TypeFile %s`: %w", c.Args, err))
+	}
+}
+
+// bracketedPasteJS pastes the text, wrapped in bracketed paste sequences, if
+// the program in the terminal asked for them. It returns whether it did.
+const bracketedPasteJS = `(text) => {
+	if (!term.modes.bracketedPasteMode) {
+		return false;
+	}
+	term.paste(text);
+	return true;
+}`
+
+// typeLines types the text at the typing speed. Text over several lines is
+// pasted at once with BracketedPaste, when the program in the terminal
+// supports it, so that the lines land together, e.g. a heredoc in a shell.
+// The line ending at the end of the text is still typed, as Enter.
+func typeLines(v *VHS, text string, typingSpeed time.Duration) {
+	lines := strings.TrimRight(text, "\r\n")
+	if v.Options.BracketedPaste && strings.ContainsAny(lines, "\r\n") {
+		res, err := v.Page.Eval(bracketedPasteJS, lines)
+		if err == nil && res.Value.Bool() {
+			v.keystroke()
+			text = text[len(lines):]
+		}
+	}
+	_ = typeText(v, strings.NewReader(text), typingSpeed)
+}
+
+// typeText types the text read from the reader at the typing speed, until
+// the end of the text. Line endings are typed as Enter, and tabs as Tab, even
+// for \r\n line endings.
+func typeText(v *VHS, r io.RuneReader, typingSpeed time.Duration) error {
+	var prev rune
+	for {
+		char, _, err := r.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if char == '\n' && prev == '\r' {
+			prev = char
+			continue
+		}
+		prev = char
+
+		v.keystroke()
+		k, ok := keymap[char]
 		if ok {
 			_ = v.Page.Keyboard.Type(k)
 		} else {
-			_ = v.Page.MustElement("textarea").Input(string(r))
+			_ = v.Page.MustElement("textarea").Input(string(char))
 			v.Page.MustWaitIdle()
 		}
-		time.Sleep(typingSpeed)
+		time.Sleep(v.typingDelay(typingSpeed))
 	}
 }
 
-// ExecuteOutput applies the output on the vhs videos.
+// ExecuteOutput applies the output on the vhs videos, depending on the format
+// of the file.
 func ExecuteOutput(c parser.Command, v *VHS) {
 	switch c.Options {
+	case ".gif":
+		v.Options.Video.Output.GIF = c.Args
 	case ".mp4":
 		v.Options.Video.Output.MP4 = c.Args
 	case ".test", ".ascii", ".txt":
@@ -238,54 +549,128 @@ func ExecuteOutput(c parser.Command, v *VHS) {
 		v.Options.Video.Output.Frames = c.Args
 	case ".webm":
 		v.Options.Video.Output.WebM = c.Args
+	case ".apng":
+		v.Options.Video.Output.APNG = c.Args
+	case ".webp":
+		v.Options.Video.Output.WebP = c.Args
+	case ".cast":
+		v.Options.Video.Output.Cast = c.Args
 	default:
-		v.Options.Video.Output.GIF = c.Args
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Output %s`: unsupported format %s, expected one of %s", c.Args, c.Options, strings.Join(parser.OutputFormats, ", ")))
 	}
 }
 
 // ExecuteCopy copies text to the clipboard.
-func ExecuteCopy(c parser.Command, _ *VHS) {
+func ExecuteCopy(c parser.Command, v *VHS) {
+	v.clipboard = c.Args
 	_ = clipboard.WriteAll(c.Args)
 }
 
-// ExecutePaste pastes text from the clipboard.
+// ExecutePaste pastes text from the clipboard, or the last copied text if the
+// clipboard isn't available.
+//
+// The text is pasted at once, like a real paste, and wrapped in bracketed
+// paste sequences when the program running in the terminal asks for them.
 func ExecutePaste(_ parser.Command, v *VHS) {
 	clip, err := clipboard.ReadAll()
 	if err != nil {
-		return
+		clip = v.clipboard
 	}
-	for _, r := range clip {
-		k, ok := keymap[r]
-		if ok {
-			_ = v.Page.Keyboard.Type(k)
-		} else {
-			_ = v.Page.MustElement("textarea").Input(string(r))
-			v.Page.MustWaitIdle()
-		}
+	if clip == "" {
+		return
 	}
+	_, _ = v.Page.Eval("(text) => term.paste(text)", clip)
 }
 
 // Settings maps the Set commands to their respective functions.
 var Settings = map[string]CommandFunc{
-	"FontFamily":    ExecuteSetFontFamily,
-	"FontSize":      ExecuteSetFontSize,
-	"Framerate":     ExecuteSetFramerate,
-	"Height":        ExecuteSetHeight,
-	"LetterSpacing": ExecuteSetLetterSpacing,
-	"LineHeight":    ExecuteSetLineHeight,
-	"PlaybackSpeed": ExecuteSetPlaybackSpeed,
-	"Padding":       ExecuteSetPadding,
-	"Theme":         ExecuteSetTheme,
-	"TypingSpeed":   ExecuteSetTypingSpeed,
-	"Width":         ExecuteSetWidth,
-	"Shell":         ExecuteSetShell,
-	"LoopOffset":    ExecuteLoopOffset,
-	"MarginFill":    ExecuteSetMarginFill,
-	"Margin":        ExecuteSetMargin,
-	"WindowBar":     ExecuteSetWindowBar,
-	"WindowBarSize": ExecuteSetWindowBarSize,
-	"BorderRadius":  ExecuteSetBorderRadius,
-	"CursorBlink":   ExecuteSetCursorBlink,
+	"FontFamily":           ExecuteSetFontFamily,
+	"FontSize":             ExecuteSetFontSize,
+	"FontFile":             ExecuteSetFontFile,
+	"Logging":              ExecuteSetLogging,
+	"Scale":                ExecuteSetScale,
+	"Debug":                ExecuteSetDebug,
+	"Headless":             ExecuteSetHeadless,
+	"BrowserFlags":         ExecuteSetBrowserFlags,
+	"ControlURL":           ExecuteSetControlURL,
+	"FontWeight":           ExecuteSetFontWeight,
+	"FontWeightBold":       ExecuteSetFontWeightBold,
+	"MinimumContrastRatio": ExecuteSetMinimumContrastRatio,
+	"Framerate":            ExecuteSetFramerate,
+	"Height":               ExecuteSetHeight,
+	"LetterSpacing":        ExecuteSetLetterSpacing,
+	"LineHeight":           ExecuteSetLineHeight,
+	"PlaybackSpeed":        ExecuteSetPlaybackSpeed,
+	"Padding":              ExecuteSetPadding,
+	"Theme":                ExecuteSetTheme,
+	"Theme.Dark":           ExecuteSetThemeVariant,
+	"Theme.Light":          ExecuteSetThemeVariant,
+	"TypingSpeed":          ExecuteSetTypingSpeed,
+	"Width":                ExecuteSetWidth,
+	"Shell":                ExecuteSetShell,
+	"LoopOffset":           ExecuteLoopOffset,
+	"MarginFill":           ExecuteSetMarginFill,
+	"Margin":               ExecuteSetMargin,
+	"WindowBar":            ExecuteSetWindowBar,
+	"WindowBarSize":        ExecuteSetWindowBarSize,
+	"BorderRadius":         ExecuteSetBorderRadius,
+	"Caption":              ExecuteSetCaption,
+	"CaptionPosition":      ExecuteSetCaptionPosition,
+	"CaptionColor":         ExecuteSetCaptionColor,
+	"CaptionFont":          ExecuteSetCaptionFont,
+	"CursorBlink":          ExecuteSetCursorBlink,
+	"CursorBlinkRate":      ExecuteSetCursorBlinkRate,
+	"CursorStyle":          ExecuteSetCursorStyle,
+	"CursorColor":          ExecuteSetCursorColor,
+	"CursorAccentColor":    ExecuteSetCursorColor,
+	"SelectionColor":       ExecuteSetSelectionColor,
+	"CaptureFramerate":     ExecuteSetCaptureFramerate,
+	"TypingVariance":       ExecuteSetTypingVariance,
+	"TypingSeed":           ExecuteSetTypingSeed,
+	"WaitTimeout":          ExecuteSetWaitTimeout,
+	"MaxDuration":          ExecuteSetMaxDuration,
+	"TabSettle":            ExecuteSetTabSettle,
+	"TermRows":             ExecuteSetTermRows,
+	"TermCols":             ExecuteSetTermCols,
+	"Boomerang":            ExecuteSetBoomerang,
+	"TrimStart":            ExecuteSetTrim,
+	"TrimEnd":              ExecuteSetTrim,
+	"Command":              ExecuteSetCommand,
+	"Poster":               ExecuteSetPoster,
+	"PosterFrame":          ExecuteSetPosterFrame,
+	"ParallelRender":       ExecuteSetParallelRender,
+	"TextDump":             ExecuteSetTextDump,
+	"ExpectedOutput":       ExecuteSetExpectedOutput,
+	"Crop":                 ExecuteSetCrop,
+	"KeySound":             ExecuteSetKeySound,
+	"Audio":                ExecuteSetAudio,
+	"AudioLoop":            ExecuteSetAudioLoop,
+	"SkipBlankFrames":      ExecuteSetSkipBlankFrames,
+	"BracketedPaste":       ExecuteSetBracketedPaste,
+	"CleanupWait":          ExecuteSetCleanupWait,
+	"Tmux":                 ExecuteSetTmux,
+	"Banner":               ExecuteSetBanner,
+	"BannerFile":           ExecuteSetBannerFile,
+	"BannerDuration":       ExecuteSetBannerDuration,
+	"Prompt":               ExecuteSetPrompt,
+	"Dedup":                ExecuteSetDedup,
+	"LoopCount":            ExecuteSetLoopCount,
+	"PixelFormat":          ExecuteSetPixelFormat,
+	"CaptureQuality":       ExecuteSetCaptureQuality,
+	"FrameFormat":          ExecuteSetFrameFormat,
+	"VariableFramerate":    ExecuteSetVariableFramerate,
+	"StreamFrames":         ExecuteSetStreamFrames,
+	"ExecClear":            ExecuteSetExecClear,
+	"WaitPattern":          ExecuteSetWaitPattern,
+	"Port":                 ExecuteSetPort,
+	"WorkingDir":           ExecuteSetWorkingDir,
+	"FFmpegPath":           ExecuteSetFFmpegPath,
+	"FFmpegArgs":           ExecuteSetFFmpegArgs,
+	"CRF":                  ExecuteSetCRF,
+	"Bitrate":              ExecuteSetBitrate,
+	"GIFDither":            ExecuteSetGIFDither,
+	"GIFColors":            ExecuteSetGIFColors,
+	"HideCursor":           ExecuteSetHideCursor,
 }
 
 // ExecuteSet applies the settings on the running vhs specified by the
@@ -294,23 +679,69 @@ func ExecuteSet(c parser.Command, v *VHS) {
 	Settings[c.Options](c, v)
 }
 
+// evalTerm evaluates the JavaScript expression on the terminal, if it has been
+// started. Settings are also applied without a terminal, e.g. when validating
+// a tape.
+func evalTerm(v *VHS, js string) {
+	if v.Page == nil {
+		return
+	}
+	_, _ = v.Page.Eval(js)
+}
+
+// termSizeJS returns the JavaScript which sizes the terminal: it is resized to
+// TermCols and TermRows when either is set, keeping the current size for the
+// other, and fit to the window otherwise.
+func termSizeJS(opts *Options) string {
+	if opts.TermCols <= 0 && opts.TermRows <= 0 {
+		return "term.fit"
+	}
+	return fmt.Sprintf("() => term.resize(%d || term.cols, %d || term.rows)", opts.TermCols, opts.TermRows)
+}
+
+// ExecuteSetTermRows sets the number of rows of the terminal.
+func ExecuteSetTermRows(c parser.Command, v *VHS) {
+	rows, err := strconv.Atoi(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set TermRows %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.TermRows = rows
+	evalTerm(v, termSizeJS(v.Options))
+}
+
+// ExecuteSetTermCols sets the number of columns of the terminal.
+func ExecuteSetTermCols(c parser.Command, v *VHS) {
+	cols, err := strconv.Atoi(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set TermCols %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.TermCols = cols
+	evalTerm(v, termSizeJS(v.Options))
+}
+
 // ExecuteSetFontSize applies the font size on the vhs.
 func ExecuteSetFontSize(c parser.Command, v *VHS) {
 	fontSize, _ := strconv.Atoi(c.Args)
 	v.Options.FontSize = fontSize
-	_, _ = v.Page.Eval(fmt.Sprintf("() => term.options.fontSize = %d", fontSize))
+	if !v.Options.Video.Style.windowBarSizeSet {
+		v.Options.Video.Style.WindowBarSize = scaledWindowBarSize(fontSize)
+	}
 
 	// When changing the font size only the canvas dimensions change which are
 	// scaled back during the render to fit the aspect ration and dimensions.
 	//
 	// We need to call term.fit to ensure that everything is resized properly.
-	_, _ = v.Page.Eval("term.fit")
+	if err := v.resizeTerminal(fontSize); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("`Set FontSize %s`: %w", c.Args, err))
+	}
 }
 
 // ExecuteSetFontFamily applies the font family on the vhs.
 func ExecuteSetFontFamily(c parser.Command, v *VHS) {
 	v.Options.FontFamily = c.Args
-	_, _ = v.Page.Eval(fmt.Sprintf("() => term.options.fontFamily = '%s'", withSymbolsFallback(c.Args)))
+	evalTerm(v, fmt.Sprintf("() => term.options.fontFamily = '%s'", withSymbolsFallback(c.Args)))
 }
 
 // ExecuteSetHeight applies the height on the vhs.
@@ -324,9 +755,35 @@ func ExecuteSetWidth(c parser.Command, v *VHS) {
 }
 
 // ExecuteSetShell applies the shell on the vhs.
+// Shells unknown to VHS are assumed to be POSIX compatible and only have their
+// PS1 prompt configured.
 func ExecuteSetShell(c parser.Command, v *VHS) {
-	if s, ok := Shells[c.Args]; ok {
-		v.Options.Shell = s
+	s, ok := Shells[c.Args]
+	if !ok {
+		s = Shell{Command: []string{c.Args}, Env: []string{"PS1=" + defaultPrompt}}
+	}
+	if _, err := exec.LookPath(s.Command[0]); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Shell %q`: %w", c.Args, err))
+		return
+	}
+	v.Options.Shell = s
+}
+
+// ExecuteSetPrompt applies the prompt of the shell on the vhs. Once the
+// terminal is set up, the prompt is changed in the running shell, which shows
+// in the recording unless it's hidden.
+func ExecuteSetPrompt(c parser.Command, v *VHS) {
+	if len(v.Options.Command) > 0 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Prompt %q`: the Command has no prompt", c.Args))
+		return
+	}
+	v.Options.Prompt = c.Args
+	if v.TextCanvas == nil {
+		// The prompt is set during Setup.
+		return
+	}
+	if err := v.setPrompt(); err != nil {
+		v.Errors = append(v.Errors, err)
 	}
 }
 
@@ -340,14 +797,68 @@ const (
 func ExecuteSetLetterSpacing(c parser.Command, v *VHS) {
 	letterSpacing, _ := strconv.ParseFloat(c.Args, bitSize)
 	v.Options.LetterSpacing = letterSpacing
-	_, _ = v.Page.Eval(fmt.Sprintf("() => term.options.letterSpacing = %f", letterSpacing))
+	evalTerm(v, fmt.Sprintf("() => term.options.letterSpacing = %f", letterSpacing))
+	// The cells change size, refit the terminal when it is changed live.
+	evalTerm(v, termSizeJS(v.Options))
+}
+
+// ExecuteSetLogging sets the format of the logs.
+func ExecuteSetLogging(c parser.Command, v *VHS) {
+	if c.Args != logText && c.Args != logJSON {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Logging %s`: expected text or json", c.Args))
+		return
+	}
+	v.Options.Logging = c.Args
+}
+
+// ExecuteSetFontFile sets the font file loaded into the terminal.
+func ExecuteSetFontFile(c parser.Command, v *VHS) {
+	if err := checkFontFile(c.Args); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set FontFile %q`: %w", c.Args, err))
+		return
+	}
+	v.Options.FontFile = c.Args
+}
+
+// ExecuteSetFontWeight applies the weight of normal text on the vhs.
+func ExecuteSetFontWeight(c parser.Command, v *VHS) {
+	if !parser.IsValidFontWeight(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set FontWeight %s`: expected normal, bold, or 100 to 900", c.Args))
+		return
+	}
+	v.Options.FontWeight = c.Args
+	evalTerm(v, fmt.Sprintf("() => term.options.fontWeight = '%s'", c.Args))
+}
+
+// ExecuteSetFontWeightBold applies the weight of bold text on the vhs.
+func ExecuteSetFontWeightBold(c parser.Command, v *VHS) {
+	if !parser.IsValidFontWeight(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set FontWeightBold %s`: expected normal, bold, or 100 to 900", c.Args))
+		return
+	}
+	v.Options.FontWeightBold = c.Args
+	evalTerm(v, fmt.Sprintf("() => term.options.fontWeightBold = '%s'", c.Args))
+}
+
+// ExecuteSetMinimumContrastRatio applies the minimum contrast ratio of the
+// text on the vhs.
+func ExecuteSetMinimumContrastRatio(c parser.Command, v *VHS) {
+	ratio, err := strconv.ParseFloat(c.Args, bitSize)
+	if err != nil || ratio < 1 || ratio > 21 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set MinimumContrastRatio %s`: expected a number between 1 and 21", c.Args))
+		return
+	}
+	v.Options.MinimumContrastRatio = ratio
+	evalTerm(v, fmt.Sprintf("() => term.options.minimumContrastRatio = %f", ratio))
 }
 
 // ExecuteSetLineHeight applies the line height on the vhs.
 func ExecuteSetLineHeight(c parser.Command, v *VHS) {
 	lineHeight, _ := strconv.ParseFloat(c.Args, bitSize)
 	v.Options.LineHeight = lineHeight
-	_, _ = v.Page.Eval(fmt.Sprintf("() => term.options.lineHeight = %f", lineHeight))
+	evalTerm(v, fmt.Sprintf("() => term.options.lineHeight = %f", lineHeight))
+	// The cells change size, refit the terminal when it is changed live.
+	evalTerm(v, termSizeJS(v.Options))
 }
 
 // ExecuteSetTheme applies the theme on the vhs.
@@ -359,12 +870,30 @@ func ExecuteSetTheme(c parser.Command, v *VHS) {
 		return
 	}
 
-	bts, _ := json.Marshal(v.Options.Theme)
-	_, _ = v.Page.Eval(fmt.Sprintf("() => term.options.theme = %s", string(bts)))
+	evalTerm(v, fmt.Sprintf("() => term.options.theme = %s", v.Options.termTheme()))
 	v.Options.Video.Style.BackgroundColor = v.Options.Theme.Background
 	v.Options.Video.Style.WindowBarColor = v.Options.Theme.Background
 }
 
+// themeVariants maps the theme variant settings to the variant names, which
+// are used as the suffix of the outputs of each variant.
+var themeVariants = map[string]string{
+	"Theme.Dark":  "dark",
+	"Theme.Light": "light",
+}
+
+// ExecuteSetThemeVariant applies the theme of the variant which is being
+// recorded. The themes of the other variants are only checked.
+func ExecuteSetThemeVariant(c parser.Command, v *VHS) {
+	if themeVariants[c.Options] != v.themeVariant {
+		if _, err := getTheme(c.Args); err != nil {
+			v.Errors = append(v.Errors, err)
+		}
+		return
+	}
+	ExecuteSetTheme(c, v)
+}
+
 // ExecuteSetTypingSpeed applies the default typing speed on the vhs.
 func ExecuteSetTypingSpeed(c parser.Command, v *VHS) {
 	typingSpeed, err := time.ParseDuration(c.Args)
@@ -374,6 +903,465 @@ func ExecuteSetTypingSpeed(c parser.Command, v *VHS) {
 	v.Options.TypingSpeed = typingSpeed
 }
 
+// ExecuteSetTypingVariance applies the typing variance on the vhs.
+func ExecuteSetTypingVariance(c parser.Command, v *VHS) {
+	variance, err := strconv.ParseFloat(c.Args, bitSize)
+	if err != nil || variance < 0 || variance > 1 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set TypingVariance %s`: must be between 0 and 1", c.Args))
+		return
+	}
+	v.Options.TypingVariance = variance
+}
+
+// ExecuteSetTypingSeed seeds the random source used for typing variance so
+// that recordings are reproducible.
+func ExecuteSetTypingSeed(c parser.Command, v *VHS) {
+	seed, err := strconv.ParseInt(c.Args, base, bitSize)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set TypingSeed %s`: %w", c.Args, err))
+		return
+	}
+	v.typingRand = rand.New(rand.NewSource(seed)) //nolint:gosec
+}
+
+// ExecuteSetWaitTimeout applies the default timeout of Wait commands on the vhs.
+func ExecuteSetWaitTimeout(c parser.Command, v *VHS) {
+	timeout, err := time.ParseDuration(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set WaitTimeout %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.WaitTimeout = timeout
+}
+
+// ExecuteSetCleanupWait applies the longest time the commands run last are
+// given to finish on the vhs.
+func ExecuteSetCleanupWait(c parser.Command, v *VHS) {
+	wait, err := time.ParseDuration(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CleanupWait %s`: %w", c.Args, err))
+		return
+	}
+	if wait < 0 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CleanupWait %s`: time must not be negative", c.Args))
+		return
+	}
+	v.Options.CleanupWait = wait
+}
+
+// ExecuteSetTabSettle applies the time to wait after each Tab key press on the
+// vhs.
+func ExecuteSetTabSettle(c parser.Command, v *VHS) {
+	settle, err := time.ParseDuration(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set TabSettle %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.TabSettle = settle
+}
+
+// ExecuteSetMaxDuration applies the maximum duration of the recording on the
+// vhs.
+func ExecuteSetMaxDuration(c parser.Command, v *VHS) {
+	maxDuration, err := time.ParseDuration(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set MaxDuration %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.MaxDuration = maxDuration
+}
+
+// ExecuteSetWaitPattern applies the pattern that Wait commands without a
+// pattern wait for on the vhs.
+func ExecuteSetWaitPattern(c parser.Command, v *VHS) {
+	if _, err := regexp.Compile(c.Args); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set WaitPattern /%s/`: %w", c.Args, err))
+		return
+	}
+	v.Options.WaitPattern = c.Args
+}
+
+// ExecuteSetPort applies the port ttyd listens on to the vhs.
+func ExecuteSetPort(c parser.Command, v *VHS) {
+	port, err := strconv.Atoi(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Port %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Port = port
+}
+
+// ExecuteSetDebug sets whether the browser is shown and left open.
+func ExecuteSetDebug(c parser.Command, v *VHS) {
+	debug, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Debug %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Debug = debug
+}
+
+// ExecuteSetHeadless sets whether the browser runs without a window.
+func ExecuteSetHeadless(c parser.Command, v *VHS) {
+	headless, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Headless %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Headless = headless
+}
+
+// ExecuteSetBrowserFlags sets the extra command-line flags of the browser.
+func ExecuteSetBrowserFlags(c parser.Command, v *VHS) {
+	browserFlags := strings.Fields(c.Args)
+	for _, flag := range browserFlags {
+		if !strings.HasPrefix(flag, "--") {
+			v.Errors = append(v.Errors, fmt.Errorf("invalid `Set BrowserFlags %q`: %s should start with --", c.Args, flag))
+			return
+		}
+	}
+	v.Options.BrowserFlags = browserFlags
+}
+
+// ExecuteSetControlURL sets the DevTools URL of a running browser to connect
+// to, instead of launching one.
+func ExecuteSetControlURL(c parser.Command, v *VHS) {
+	u, err := url.Parse(c.Args)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss" && u.Scheme != "http" && u.Scheme != "https") {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set ControlURL %s`: expected a ws:// or http:// URL", c.Args))
+		return
+	}
+	v.Options.ControlURL = c.Args
+}
+
+// ExecuteSetCommand sets the program run in the terminal instead of the
+// shell.
+func ExecuteSetCommand(c parser.Command, v *VHS) {
+	command, err := shellWords(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Command %q`: %w", c.Args, err))
+		return
+	}
+	if len(command) == 0 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Command %q`: expected a program to run", c.Args))
+		return
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Command %q`: %w", c.Args, err))
+		return
+	}
+	v.Options.Command = command
+}
+
+// ExecuteSetTmux applies the tmux commands which set up the session the shell
+// runs in to the vhs.
+func ExecuteSetTmux(c parser.Command, v *VHS) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Tmux %q`: %w", c.Args, err))
+		return
+	}
+	commands, err := shellCommands(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Tmux %q`: %w", c.Args, err))
+		return
+	}
+	v.Options.Tmux = commands
+}
+
+// ExecuteSetWorkingDir applies the directory the shell starts in to the vhs.
+func ExecuteSetWorkingDir(c parser.Command, v *VHS) {
+	info, err := os.Stat(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set WorkingDir %s`: %w", c.Args, err))
+		return
+	}
+	if !info.IsDir() {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set WorkingDir %s`: not a directory", c.Args))
+		return
+	}
+	v.Options.WorkingDir = c.Args
+}
+
+// ExecuteSetFFmpegPath sets the ffmpeg binary used to render the outputs.
+func ExecuteSetFFmpegPath(c parser.Command, v *VHS) {
+	if err := checkFFmpeg(c.Args); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set FFmpegPath %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.FFmpegPath = c.Args
+	v.Options.Screenshot.ffmpegPath = c.Args
+}
+
+// ExecuteSetFFmpegArgs sets extra arguments passed to ffmpeg when rendering
+// the outputs.
+func ExecuteSetFFmpegArgs(c parser.Command, v *VHS) {
+	args, err := shellWords(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set FFmpegArgs %q`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.ExtraArgs = args
+}
+
+// ExecuteSetCRF sets the constant rate factor of the MP4 and WebM outputs.
+func ExecuteSetCRF(c parser.Command, v *VHS) {
+	crf, err := strconv.Atoi(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CRF %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.CRF = crf
+}
+
+// ExecuteSetBitrate sets the maximum bitrate of the MP4 and WebM outputs.
+func ExecuteSetBitrate(c parser.Command, v *VHS) {
+	v.Options.Video.Bitrate = c.Args
+}
+
+// ExecuteSetPixelFormat sets the pixel format of the MP4 and WebM outputs.
+func ExecuteSetPixelFormat(c parser.Command, v *VHS) {
+	if !isPixelFormat(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set PixelFormat %s`: expected one of %s", c.Args, strings.Join(pixelFormats, ", ")))
+		return
+	}
+	v.Options.Video.PixelFormat = c.Args
+}
+
+// ExecuteSetCaptureQuality sets the quality the frames are captured with.
+func ExecuteSetCaptureQuality(c parser.Command, v *VHS) {
+	q, err := strconv.ParseFloat(c.Args, bitSize)
+	if err != nil || q <= 0 || q > 1 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CaptureQuality %s`: expected a number above 0, up to 1", c.Args))
+		return
+	}
+	v.Options.Video.CaptureQuality = q
+}
+
+// ExecuteSetFrameFormat sets the image format of the text frames.
+func ExecuteSetFrameFormat(c parser.Command, v *VHS) {
+	if c.Args != framePNG && c.Args != frameJPEG {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set FrameFormat %s`: expected png or jpeg", c.Args))
+		return
+	}
+	v.Options.Video.FrameFormat = c.Args
+}
+
+// ExecuteSetGIFDither sets the dithering algorithm of the GIF output.
+func ExecuteSetGIFDither(c parser.Command, v *VHS) {
+	if !parser.IsValidDither(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set GIFDither %s`: unknown dithering algorithm", c.Args))
+		return
+	}
+	v.Options.Video.Dither = c.Args
+}
+
+// ExecuteSetGIFColors sets the size of the palette of the GIF output.
+func ExecuteSetGIFColors(c parser.Command, v *VHS) {
+	colors, err := strconv.Atoi(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set GIFColors %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.MaxColors = colors
+}
+
+// ExecuteSetBoomerang sets whether the output plays backward after playing
+// forward.
+func ExecuteSetBoomerang(c parser.Command, v *VHS) {
+	boomerang, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Boomerang %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.Boomerang = boomerang
+}
+
+// ExecuteSetParallelRender sets whether the outputs are created at once.
+func ExecuteSetParallelRender(c parser.Command, v *VHS) {
+	parallel, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set ParallelRender %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.Parallel = parallel
+}
+
+// ExecuteSetTextDump sets the file, or directory, the text of each frame is
+// written to.
+func ExecuteSetTextDump(c parser.Command, v *VHS) {
+	v.Options.Video.TextDump = c.Args
+}
+
+// ExecuteSetExpectedOutput reads the text the screen must show after the last
+// command from the file.
+func ExecuteSetExpectedOutput(c parser.Command, v *VHS) {
+	bts, err := os.ReadFile(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set ExpectedOutput %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Test.Golden = c.Args
+	v.Options.Test.ExpectedOutput = string(bts)
+}
+
+// ExecuteSetCrop sets the rectangle of the terminal the video is cropped to.
+func ExecuteSetCrop(c parser.Command, v *VHS) {
+	crop, err := parseCrop(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Crop %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.Crop = crop
+}
+
+// ExecuteSetKeySound sets whether the key presses can be heard in the MP4 and
+// WebM outputs.
+func ExecuteSetKeySound(c parser.Command, v *VHS) {
+	keySound, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set KeySound %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.KeySound = keySound
+}
+
+// ExecuteSetAudio sets the audio file played over the MP4 and WebM outputs.
+func ExecuteSetAudio(c parser.Command, v *VHS) {
+	if _, err := os.Stat(c.Args); err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Audio %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.Audio = c.Args
+}
+
+// ExecuteSetAudioLoop sets whether the audio file is played over and over
+// until the end of the video.
+func ExecuteSetAudioLoop(c parser.Command, v *VHS) {
+	loop, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set AudioLoop %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.AudioLoop = loop
+}
+
+// ExecuteSetSkipBlankFrames sets whether the blank frames at the start of the
+// recording are trimmed off.
+func ExecuteSetSkipBlankFrames(c parser.Command, v *VHS) {
+	skip, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set SkipBlankFrames %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.SkipBlankFrames = skip
+}
+
+// ExecuteSetBracketedPaste sets whether the text typed over several lines is
+// pasted at once, when the program in the terminal supports it.
+func ExecuteSetBracketedPaste(c parser.Command, v *VHS) {
+	paste, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set BracketedPaste %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.BracketedPaste = paste
+}
+
+// ExecuteSetBanner sets the banner shown when the recording starts.
+func ExecuteSetBanner(c parser.Command, v *VHS) {
+	v.Options.Banner = c.Args
+}
+
+// ExecuteSetBannerFile sets the banner shown when the recording starts to the
+// contents of a file.
+func ExecuteSetBannerFile(c parser.Command, v *VHS) {
+	banner, err := os.ReadFile(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set BannerFile %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Banner = string(banner)
+}
+
+// ExecuteSetBannerDuration sets how long the banner is shown before the first
+// command.
+func ExecuteSetBannerDuration(c parser.Command, v *VHS) {
+	dur, err := time.ParseDuration(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set BannerDuration %s`: %w", c.Args, err))
+		return
+	}
+	if dur < 0 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set BannerDuration %s`: time must not be negative", c.Args))
+		return
+	}
+	v.Options.BannerDuration = dur
+}
+
+// ExecuteSetVariableFramerate sets whether the frames are held for as long as
+// they were shown while recording, rather than for a constant duration.
+func ExecuteSetVariableFramerate(c parser.Command, v *VHS) {
+	variable, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set VariableFramerate %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.VariableFramerate = variable
+}
+
+// ExecuteSetStreamFrames sets whether the frames are piped into ffmpeg as
+// they are captured, rather than written to disk.
+func ExecuteSetStreamFrames(c parser.Command, v *VHS) {
+	stream, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set StreamFrames %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.StreamFrames = stream
+}
+
+// ExecuteSetExecClear sets whether the screen is cleared after an Exec.
+func ExecuteSetExecClear(c parser.Command, v *VHS) {
+	clearScreen, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set ExecClear %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.ExecClear = clearScreen
+}
+
+// ExecuteSetDedup sets whether the identical frames of GIF outputs are
+// collapsed into single frames held for as long.
+func ExecuteSetDedup(c parser.Command, v *VHS) {
+	dedup, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Dedup %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.Dedup = dedup
+}
+
+// ExecuteSetLoopCount sets the number of times GIF and APNG outputs are
+// played again, where 0 loops forever and -1 plays them once.
+func ExecuteSetLoopCount(c parser.Command, v *VHS) {
+	loopCount, err := strconv.Atoi(c.Args)
+	if err != nil || loopCount < -1 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set LoopCount %s`: expected -1 or more", c.Args))
+		return
+	}
+	v.Options.Video.LoopCount = loopCount
+}
+
+// ExecuteSetHideCursor sets whether the cursor is left out of the recording.
+func ExecuteSetHideCursor(c parser.Command, v *VHS) {
+	hide, err := strconv.ParseBool(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set HideCursor %s`: %w", c.Args, err))
+		return
+	}
+	v.Options.Video.HideCursor = hide
+}
+
 // ExecuteSetPadding applies the padding on the vhs.
 func ExecuteSetPadding(c parser.Command, v *VHS) {
 	v.Options.Video.Style.Padding, _ = strconv.Atoi(c.Args)
@@ -381,28 +1369,82 @@ func ExecuteSetPadding(c parser.Command, v *VHS) {
 
 // ExecuteSetFramerate applies the framerate on the vhs.
 func ExecuteSetFramerate(c parser.Command, v *VHS) {
-	framerate, err := strconv.ParseInt(c.Args, base, 0)
-	if err != nil {
+	if !parser.IsValidFramerate(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Framerate %s`: expected a number between %d and %d", c.Args, parser.MinFramerate, parser.MaxFramerate))
+		return
+	}
+	v.Options.Video.Framerate, _ = strconv.Atoi(c.Args)
+}
+
+// ExecuteSetCaptureFramerate applies the capture framerate on the vhs.
+func ExecuteSetCaptureFramerate(c parser.Command, v *VHS) {
+	if !parser.IsValidFramerate(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CaptureFramerate %s`: expected a number between %d and %d", c.Args, parser.MinFramerate, parser.MaxFramerate))
 		return
 	}
-	v.Options.Video.Framerate = int(framerate)
+	v.Options.Video.CaptureFramerate, _ = strconv.Atoi(c.Args)
+}
+
+// ExecuteSetScale applies the device scale factor of the terminal on the vhs.
+func ExecuteSetScale(c parser.Command, v *VHS) {
+	scale, err := strconv.ParseFloat(c.Args, bitSize)
+	if err != nil || scale <= 0 || scale > parser.MaxScale {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Scale %s`: expected a positive number up to %d", c.Args, parser.MaxScale))
+		return
+	}
+	v.Options.Video.Scale = scale
 }
 
 // ExecuteSetPlaybackSpeed applies the playback speed option on the vhs.
 func ExecuteSetPlaybackSpeed(c parser.Command, v *VHS) {
 	playbackSpeed, err := strconv.ParseFloat(c.Args, bitSize)
-	if err != nil {
+	if err != nil || playbackSpeed <= 0 {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set PlaybackSpeed %s`: expected a positive number", c.Args))
 		return
 	}
 	v.Options.Video.PlaybackSpeed = playbackSpeed
 }
 
+// ExecuteSetTrim sets the number of frames, or the duration, cut off the start
+// or end of the recording.
+func ExecuteSetTrim(c parser.Command, v *VHS) {
+	trim, err := parseTrim(c.Args)
+	if err != nil {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set %s %s`: %w", c.Options, c.Args, err))
+		return
+	}
+	if c.Options == "TrimStart" {
+		v.Options.Video.TrimStart = trim
+	} else {
+		v.Options.Video.TrimEnd = trim
+	}
+}
+
+// ExecuteSetPoster sets the image the poster frame is rendered to.
+func ExecuteSetPoster(c parser.Command, v *VHS) {
+	if !posterFormats[strings.ToLower(filepath.Ext(c.Args))] {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set Poster %s`: expected a .png, .jpg or .jpeg image", c.Args))
+		return
+	}
+	v.Options.Video.Poster = c.Args
+}
+
+// ExecuteSetPosterFrame sets the position of the poster frame in the output.
+func ExecuteSetPosterFrame(c parser.Command, v *VHS) {
+	if !parser.IsValidPosterFrame(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set PosterFrame %s`: expected a percentage from 0 to 100", c.Args))
+		return
+	}
+	v.Options.Video.PosterFrame, _ = strconv.ParseFloat(strings.TrimSuffix(c.Args, "%"), bitSize)
+}
+
 // ExecuteLoopOffset applies the loop offset option on the vhs.
 func ExecuteLoopOffset(c parser.Command, v *VHS) {
-	loopOffset, err := strconv.ParseFloat(strings.TrimRight(c.Args, "%"), bitSize)
-	if err != nil {
+	if !parser.IsValidLoopOffset(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set LoopOffset %s`: expected a percentage from 0 up to 100", c.Args))
 		return
 	}
+	loopOffset, _ := strconv.ParseFloat(strings.TrimSuffix(c.Args, "%"), bitSize)
 	v.Options.LoopOffset = loopOffset
 }
 
@@ -424,6 +1466,7 @@ func ExecuteSetWindowBar(c parser.Command, v *VHS) {
 // ExecuteSetWindowBar sets window bar size
 func ExecuteSetWindowBarSize(c parser.Command, v *VHS) {
 	v.Options.Video.Style.WindowBarSize, _ = strconv.Atoi(c.Args)
+	v.Options.Video.Style.windowBarSizeSet = true
 }
 
 // ExecuteSetWindowBar sets corner radius
@@ -431,6 +1474,42 @@ func ExecuteSetBorderRadius(c parser.Command, v *VHS) {
 	v.Options.Video.Style.BorderRadius, _ = strconv.Atoi(c.Args)
 }
 
+// ExecuteSetCaption sets the caption drawn over the output.
+func ExecuteSetCaption(c parser.Command, v *VHS) {
+	v.Options.Video.Style.Caption = c.Args
+}
+
+// ExecuteSetCaptionPosition sets whether the caption is drawn at the top or
+// the bottom of the output.
+func ExecuteSetCaptionPosition(c parser.Command, v *VHS) {
+	if c.Args != captionTop && c.Args != captionBottom {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CaptionPosition %s`: expected top or bottom", c.Args))
+		return
+	}
+	v.Options.Video.Style.CaptionPosition = c.Args
+}
+
+// ExecuteSetCaptionColor sets the color of the caption.
+func ExecuteSetCaptionColor(c parser.Command, v *VHS) {
+	if !isHexColor(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CaptionColor %q`: expected #RGB or #RRGGBB", c.Args))
+		return
+	}
+	v.Options.Video.Style.CaptionColor = c.Args
+}
+
+// ExecuteSetCaptionFont sets the font family, or the font file, of the
+// caption.
+func ExecuteSetCaptionFont(c parser.Command, v *VHS) {
+	if isFontFile(c.Args) {
+		if _, err := os.Stat(c.Args); err != nil {
+			v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CaptionFont %q`: %w", c.Args, err))
+			return
+		}
+	}
+	v.Options.Video.Style.CaptionFont = c.Args
+}
+
 // ExecuteSetCursorBlink sets cursor blinking
 func ExecuteSetCursorBlink(c parser.Command, v *VHS) {
 	var err error
@@ -440,9 +1519,75 @@ func ExecuteSetCursorBlink(c parser.Command, v *VHS) {
 	}
 }
 
+// ExecuteSetCursorBlinkRate sets the rate at which the cursor blinks.
+func ExecuteSetCursorBlinkRate(c parser.Command, v *VHS) {
+	rate, err := time.ParseDuration(c.Args)
+	if err != nil {
+		return
+	}
+	v.Options.CursorBlinkRate = rate
+}
+
+// ExecuteSetCursorStyle sets the shape of the cursor.
+func ExecuteSetCursorStyle(c parser.Command, v *VHS) {
+	if !parser.IsValidCursorStyle(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set CursorStyle %q`: expected block, bar, or underline", c.Args))
+		return
+	}
+	v.Options.CursorStyle = c.Args
+}
+
+// ExecuteSetCursorColor sets the color of the cursor, or of the character
+// under it, overriding the theme.
+func ExecuteSetCursorColor(c parser.Command, v *VHS) {
+	if !isHexColor(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set %s %q`: expected #RGB or #RRGGBB", c.Options, c.Args))
+		return
+	}
+	if c.Options == "CursorColor" {
+		v.Options.CursorColor = c.Args
+	} else {
+		v.Options.CursorAccentColor = c.Args
+	}
+	evalTerm(v, fmt.Sprintf("() => term.options.theme = %s", v.Options.termTheme()))
+}
+
+// ExecuteSetSelectionColor sets the background color of selected text,
+// overriding the theme.
+func ExecuteSetSelectionColor(c parser.Command, v *VHS) {
+	if !isHexColor(c.Args) {
+		v.Errors = append(v.Errors, fmt.Errorf("invalid `Set SelectionColor %q`: expected #RGB or #RRGGBB", c.Args))
+		return
+	}
+	v.Options.SelectionColor = c.Args
+	evalTerm(v, fmt.Sprintf("() => term.options.theme = %s", v.Options.termTheme()))
+}
+
+// termTheme returns the theme of the terminal, with the cursor and selection
+// colors overridden if they are set.
+func (opts *Options) termTheme() Theme {
+	theme := opts.Theme
+	if theme.SelectionBackground == "" {
+		theme.SelectionBackground = theme.Selection
+	}
+	if opts.SelectionColor != "" {
+		theme.Selection = opts.SelectionColor
+		theme.SelectionBackground = opts.SelectionColor
+	}
+	if opts.CursorColor != "" {
+		theme.Cursor = opts.CursorColor
+	}
+	if opts.CursorAccentColor != "" {
+		theme.CursorAccent = opts.CursorAccentColor
+	}
+	return theme
+}
+
 const sourceDisplayMaxLength = 10
 
 // ExecuteSourceTape is a CommandFunc that executes all commands of source tape.
+// Nested Source commands are executed as well, the parser guarantees they are
+// not recursive.
 func ExecuteSourceTape(c parser.Command, v *VHS) {
 	tapePath := c.Args
 	var out io.Writer = os.Stdout
@@ -458,7 +1603,7 @@ func ExecuteSourceTape(c parser.Command, v *VHS) {
 	}
 
 	l := lexer.New(string(tape))
-	p := parser.New(l)
+	p := parser.NewWithPath(l, tapePath)
 
 	cmds := p.Parse()
 
@@ -478,30 +1623,54 @@ func ExecuteSourceTape(c parser.Command, v *VHS) {
 	// Run all commands from the sourced tape file.
 	for _, cmd := range cmds {
 		// Output have to be avoid in order to not overwrite output of the original tape.
-		if cmd.Type == token.SOURCE ||
-			cmd.Type == token.OUTPUT {
+		if cmd.Type == token.OUTPUT {
 			continue
 		}
 		fmt.Fprintf(out, "%s %s\n", GrayStyle.Render(displayPath+":"), Highlight(cmd, false))
+		// The sourced tape may source another one in turn.
+		if cmd.Type == token.SOURCE {
+			ExecuteSourceTape(cmd, v)
+			continue
+		}
 		CommandFuncs[cmd.Type](cmd, v)
 	}
 }
 
-// ExecuteScreenshot is a CommandFunc that indicates a new screenshot must be taken.
+// ExecuteScreenshot is a CommandFunc that takes a screenshot of the current
+// state of the terminal.
 func ExecuteScreenshot(c parser.Command, v *VHS) {
-	v.ScreenshotNextFrame(c.Args)
+	if err := v.Screenshot(c.Args); err != nil {
+		v.Errors = append(v.Errors, err)
+	}
 }
 
 func getTheme(s string) (Theme, error) {
 	if strings.TrimSpace(s) == "" {
 		return DefaultTheme, nil
 	}
-	switch s[0] {
-	case '{':
-		return getJSONTheme(s)
+
+	var theme Theme
+	var err error
+	switch {
+	case s[0] == '{':
+		theme, err = getJSONTheme(s)
+	case isThemeFile(s):
+		theme, err = LoadTheme(s)
+		if err != nil {
+			err = fmt.Errorf("invalid `Set Theme %q`: %w", s, err)
+		}
 	default:
-		return findTheme(s)
+		theme, err = findTheme(s)
+	}
+	if err != nil {
+		return DefaultTheme, err
+	}
+
+	// Fail early rather than rendering a terminal with broken colors.
+	if err := theme.Validate(); err != nil {
+		return DefaultTheme, fmt.Errorf("invalid `Set Theme %q`: %w", s, err)
 	}
+	return theme, nil
 }
 
 func getJSONTheme(s string) (Theme, error) {
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/charmbracelet/vhs/lexer"
 	"github.com/charmbracelet/vhs/parser"
+	"github.com/charmbracelet/vhs/token"
 )
 
 func TestCommand(t *testing.T) {
-	const numberOfCommands = 27
+	const numberOfCommands = 36
 	if len(parser.CommandTypes) != numberOfCommands {
 		t.Errorf("Expected %d commands, got %d", numberOfCommands, len(parser.CommandTypes))
 	}
 
-	const numberOfCommandFuncs = 27
+	const numberOfCommandFuncs = 36
 	if len(CommandFuncs) != numberOfCommandFuncs {
 		t.Errorf("Expected %d commands, got %d", numberOfCommandFuncs, len(CommandFuncs))
 	}
@@ -53,6 +60,213 @@ func TestExecuteSetTheme(t *testing.T) {
 		requireErr(t, err)
 		requireDefaultTheme(t, theme)
 	})
+	t.Run("invalid colors", func(t *testing.T) {
+		theme, err := getTheme(`{"background": "#29283", "red": "red", "blue": "#abc"}`)
+		requireErr(t, err)
+		requireDefaultTheme(t, theme)
+	})
+}
+
+func TestExecuteSetThemeVariant(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.themeVariant = "light"
+
+	ExecuteSetThemeVariant(parser.Command{Type: token.SET, Options: "Theme.Dark", Args: "Catppuccin Mocha"}, &v)
+	requireDefaultTheme(t, v.Options.Theme)
+
+	ExecuteSetThemeVariant(parser.Command{Type: token.SET, Options: "Theme.Light", Args: "Catppuccin Latte"}, &v)
+	if v.Options.Theme.Name != "Catppuccin Latte" {
+		t.Errorf("expected the light theme, got %q", v.Options.Theme.Name)
+	}
+
+	ExecuteSetThemeVariant(parser.Command{Type: token.SET, Options: "Theme.Dark", Args: "vhs-missing-theme"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected the dark theme to be checked, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetCursorStyle(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetCursorStyle(parser.Command{Args: "underline"}, &v)
+	if v.Options.CursorStyle != "underline" {
+		t.Errorf("expected cursor style to be underline, got %q", v.Options.CursorStyle)
+	}
+	if len(v.Errors) != 0 {
+		t.Errorf("expected no errors, got %v", v.Errors)
+	}
+
+	ExecuteSetCursorStyle(parser.Command{Args: "beam"}, &v)
+	if v.Options.CursorStyle != "underline" {
+		t.Errorf("expected cursor style to be unchanged, got %q", v.Options.CursorStyle)
+	}
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an invalid cursor style, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetCursorColor(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetCursorColor(parser.Command{Options: "CursorColor", Args: "#FF5FD2"}, &v)
+	ExecuteSetCursorColor(parser.Command{Options: "CursorAccentColor", Args: "#171717"}, &v)
+	// The colors are kept when the theme changes afterwards.
+	ExecuteSetTheme(parser.Command{Args: "Dracula"}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	theme := v.Options.termTheme()
+	if theme.Cursor != "#FF5FD2" || theme.CursorAccent != "#171717" {
+		t.Errorf("expected the cursor colors to override the theme, got %q and %q", theme.Cursor, theme.CursorAccent)
+	}
+	if theme.Background != v.Options.Theme.Background {
+		t.Errorf("expected the rest of the theme to be kept, got %q", theme.Background)
+	}
+
+	ExecuteSetCursorColor(parser.Command{Options: "CursorColor", Args: "pink"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an invalid color, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetCursorColorTerm(t *testing.T) {
+	// A terminal which only holds the options applied to it.
+	page := testPage(t, `<script>window.term = { options: {} };</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+
+	ExecuteSetCursorColor(parser.Command{Options: "CursorColor", Args: "#FF5FD2"}, &v)
+	ExecuteSetTheme(parser.Command{Args: "Dracula"}, &v)
+	ExecuteSetCursorColor(parser.Command{Options: "CursorAccentColor", Args: "#171717"}, &v)
+	theme := page.MustEval("() => term.options.theme")
+	if cursor := theme.Get("cursor").Str(); cursor != "#FF5FD2" {
+		t.Errorf("expected the cursor color to be applied to the terminal, got %q", cursor)
+	}
+	if accent := theme.Get("cursorAccent").Str(); accent != "#171717" {
+		t.Errorf("expected the cursor accent color to be applied to the terminal, got %q", accent)
+	}
+	if bg := theme.Get("background").Str(); bg != v.Options.Theme.Background {
+		t.Errorf("expected the background of the theme to be applied, got %q", bg)
+	}
+}
+
+func TestExecuteSetSelectionColor(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	if theme := v.Options.termTheme(); theme.SelectionBackground != theme.Selection {
+		t.Errorf("expected the selection of the theme to be used, got %q", theme.SelectionBackground)
+	}
+
+	ExecuteSetSelectionColor(parser.Command{Args: "#FF5FD2"}, &v)
+	if theme := v.Options.termTheme(); theme.SelectionBackground != "#FF5FD2" {
+		t.Errorf("expected the selection color to override the theme, got %q", theme.SelectionBackground)
+	}
+
+	ExecuteSetSelectionColor(parser.Command{Args: "pink"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an invalid color, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetCaption(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetCaption(parser.Command{Args: "Building the project"}, &v)
+	ExecuteSetCaptionPosition(parser.Command{Args: "top"}, &v)
+	ExecuteSetCaptionColor(parser.Command{Args: "#FF5FD2"}, &v)
+	ExecuteSetCaptionFont(parser.Command{Args: "JetBrains Mono"}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	style := v.Options.Video.Style
+	if style.Caption != "Building the project" || style.CaptionPosition != "top" || style.CaptionColor != "#FF5FD2" || style.CaptionFont != "JetBrains Mono" {
+		t.Errorf("expected the caption to be set, got %+v", style)
+	}
+
+	ExecuteSetCaptionPosition(parser.Command{Args: "middle"}, &v)
+	ExecuteSetCaptionColor(parser.Command{Args: "pink"}, &v)
+	ExecuteSetCaptionFont(parser.Command{Args: "missing.ttf"}, &v)
+	if len(v.Errors) != 3 {
+		t.Errorf("expected an error for each invalid setting, got %v", v.Errors)
+	}
+}
+
+func TestExecuteCaption(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteCaption(parser.Command{Type: token.CAPTION, Options: "2s", Args: "Install"}, &v)
+	ExecuteCaption(parser.Command{Type: token.CAPTION, Args: "Test"}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	if len(v.captions) != 2 {
+		t.Errorf("expected 2 captions, got %+v", v.captions)
+	}
+
+	ExecuteCaption(parser.Command{Type: token.CAPTION, Options: "0s", Args: "Never"}, &v)
+	if len(v.Errors) != 1 || len(v.captions) != 2 {
+		t.Errorf("expected an error for an empty time, got %v", v.Errors)
+	}
+}
+
+func TestWindowBarSize(t *testing.T) {
+	t.Run("scales with font size", func(t *testing.T) {
+		if got := scaledWindowBarSize(defaultFontSize); got != defaultWindowBarSize {
+			t.Errorf("expected default font size to give default bar size, got %d", got)
+		}
+		if got := scaledWindowBarSize(double(defaultFontSize)); got != double(defaultWindowBarSize) {
+			t.Errorf("expected bar size to double with the font size, got %d", got)
+		}
+	})
+
+	t.Run("explicit size wins", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+
+		ExecuteSetWindowBarSize(parser.Command{Args: "40"}, &v)
+		if v.Options.Video.Style.WindowBarSize != 40 {
+			t.Errorf("expected bar size to be 40, got %d", v.Options.Video.Style.WindowBarSize)
+		}
+		if !v.Options.Video.Style.windowBarSizeSet {
+			t.Error("expected bar size to be marked as explicitly set")
+		}
+	})
+}
+
+func TestExecuteSetShell(t *testing.T) {
+	t.Run("custom shell", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+
+		ExecuteSetShell(parser.Command{Args: "sh"}, &v)
+		if len(v.Errors) != 0 {
+			t.Fatalf("expected no errors, got %v", v.Errors)
+		}
+		if !reflect.DeepEqual(v.Options.Shell.Command, []string{"sh"}) {
+			t.Errorf("expected custom shell command, got %v", v.Options.Shell.Command)
+		}
+	})
+
+	t.Run("missing shell", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+
+		ExecuteSetShell(parser.Command{Args: "vhs-missing-shell"}, &v)
+		if len(v.Errors) != 1 {
+			t.Fatalf("expected an error for a missing shell, got %v", v.Errors)
+		}
+		if !reflect.DeepEqual(v.Options.Shell, Shells[defaultShell]) {
+			t.Errorf("expected shell to be unchanged, got %v", v.Options.Shell)
+		}
+	})
 }
 
 func requireErr(tb testing.TB, err error) {
@@ -92,3 +306,769 @@ func requireNotDefaultTheme(tb testing.TB, theme Theme) {
 		tb.Fatalf("expected theme to be different from the default theme, got the default instead")
 	}
 }
+
+func TestExecuteHideWaitsForCapture(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	// Simulate a capture in progress.
+	v.mutex.Lock()
+	done := make(chan struct{})
+	go func() {
+		ExecuteHide(parser.Command{}, &v)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Hide returned while a frame was being captured")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	v.mutex.Unlock()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Hide did not return after the capture finished")
+	}
+
+	if v.recording {
+		t.Error("expected recording to be paused")
+	}
+
+	ExecuteShow(parser.Command{}, &v)
+	if !v.recording {
+		t.Error("expected recording to be resumed")
+	}
+}
+
+func TestCommandTypingSpeed(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.TypingSpeed = 50 * time.Millisecond
+
+	if got := commandTypingSpeed(parser.Command{Type: token.TYPE, Args: "fast"}, &v); got != 50*time.Millisecond {
+		t.Errorf("expected default typing speed, got %s", got)
+	}
+	if got := commandTypingSpeed(parser.Command{Type: token.TYPE, Options: "100ms", Args: "slow"}, &v); got != 100*time.Millisecond {
+		t.Errorf("expected overridden typing speed, got %s", got)
+	}
+	if v.Options.TypingSpeed != 50*time.Millisecond {
+		t.Errorf("expected TypingSpeed to be unchanged, got %s", v.Options.TypingSpeed)
+	}
+}
+
+func TestExecuteHomeEnd(t *testing.T) {
+	// A page which records the keys pressed.
+	page := testPage(t, `<script>
+		window.keys = [];
+		document.addEventListener("keydown", (e) => window.keys.push(e.key));
+		</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+	v.Options.TypingSpeed = 0
+
+	CommandFuncs[token.HOME](parser.Command{Type: token.HOME, Args: "1"}, &v)
+	CommandFuncs[token.END](parser.Command{Type: token.END, Args: "2"}, &v)
+	keys := page.MustEval("() => window.keys.join(' ')").Str()
+	if keys != "Home End End" {
+		t.Errorf("expected Home to be pressed once and End twice, got %q", keys)
+	}
+}
+
+func TestExecuteSetCommand(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetCommand(parser.Command{Args: "go  version"}, &v)
+	if !reflect.DeepEqual(v.Options.Command, []string{"go", "version"}) {
+		t.Fatalf("expected the command to be set, got %v", v.Options.Command)
+	}
+
+	ExecuteSetCommand(parser.Command{Args: `go run -ldflags "-s -w" .`}, &v)
+	if want := []string{"go", "run", "-ldflags", "-s -w", "."}; !reflect.DeepEqual(v.Options.Command, want) {
+		t.Fatalf("expected the quoted argument to be kept whole, got %q", v.Options.Command)
+	}
+
+	ExecuteSetCommand(parser.Command{Args: "vhs-missing-program"}, &v)
+	ExecuteSetCommand(parser.Command{Args: ""}, &v)
+	ExecuteSetCommand(parser.Command{Args: `go run "main.go`}, &v)
+	if len(v.Errors) != 3 {
+		t.Errorf("expected errors for a missing, an empty and an unterminated command, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetWorkingDir(t *testing.T) {
+	t.Run("directory", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+
+		dir := t.TempDir()
+		ExecuteSetWorkingDir(parser.Command{Args: dir}, &v)
+		if len(v.Errors) != 0 {
+			t.Fatalf("expected no errors, got %v", v.Errors)
+		}
+		if v.Options.WorkingDir != dir {
+			t.Errorf("expected working dir %s, got %s", dir, v.Options.WorkingDir)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+
+		ExecuteSetWorkingDir(parser.Command{Args: filepath.Join(t.TempDir(), "missing")}, &v)
+		if len(v.Errors) != 1 {
+			t.Fatalf("expected an error for a missing directory, got %v", v.Errors)
+		}
+		if v.Options.WorkingDir != "" {
+			t.Errorf("expected working dir to be unchanged, got %s", v.Options.WorkingDir)
+		}
+	})
+}
+
+func TestExecuteSetMaxDuration(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	if v.Options.MaxDuration != 0 {
+		t.Errorf("expected no maximum duration by default, got %s", v.Options.MaxDuration)
+	}
+
+	ExecuteSetMaxDuration(parser.Command{Args: "5m"}, &v)
+	if v.Options.MaxDuration != 5*time.Minute {
+		t.Errorf("expected maximum duration to be 5m, got %s", v.Options.MaxDuration)
+	}
+
+	ExecuteSetMaxDuration(parser.Command{Args: "forever"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an invalid duration, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetScale(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetScale(parser.Command{Args: "4"}, &v)
+	if v.Options.Video.Scale != 4 {
+		t.Errorf("expected a scale of 4, got %v", v.Options.Video.Scale)
+	}
+
+	ExecuteSetScale(parser.Command{Args: "4.5"}, &v)
+	ExecuteSetScale(parser.Command{Args: "0"}, &v)
+	if len(v.Errors) != 2 {
+		t.Errorf("expected an error for a scale above 4 and for 0, got %v", v.Errors)
+	}
+	if v.Options.Video.Scale != 4 {
+		t.Errorf("expected the scale to be unchanged, got %v", v.Options.Video.Scale)
+	}
+}
+
+func TestExecuteSetFramerate(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetFramerate(parser.Command{Args: "30"}, &v)
+	ExecuteSetCaptureFramerate(parser.Command{Args: "60"}, &v)
+	if v.Options.Video.Framerate != 30 || v.Options.Video.CaptureFramerate != 60 {
+		t.Errorf("expected framerates 30 and 60, got %d and %d", v.Options.Video.Framerate, v.Options.Video.CaptureFramerate)
+	}
+
+	for _, framerate := range []string{"0", "-10", "121", "fast"} {
+		ExecuteSetFramerate(parser.Command{Args: framerate}, &v)
+		ExecuteSetCaptureFramerate(parser.Command{Args: framerate}, &v)
+	}
+	if len(v.Errors) != 8 {
+		t.Errorf("expected an error for each invalid framerate, got %v", v.Errors)
+	}
+	if v.Options.Video.Framerate != 30 || v.Options.Video.CaptureFramerate != 60 {
+		t.Errorf("expected invalid framerates to be ignored, got %d and %d", v.Options.Video.Framerate, v.Options.Video.CaptureFramerate)
+	}
+}
+
+func TestExecuteSetFontFile(t *testing.T) {
+	font := filepath.Join(t.TempDir(), "mono.woff2")
+	if err := os.WriteFile(font, []byte("font"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetFontFile(parser.Command{Args: font}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", v.Errors)
+	}
+	if v.Options.FontFile != font {
+		t.Errorf("expected font file %q, got %q", font, v.Options.FontFile)
+	}
+	src, err := fontFileSource(font)
+	requireNoErr(t, err)
+	if want := "data:font/woff2;base64,Zm9udA=="; src != want {
+		t.Errorf("expected source %q, got %q", want, src)
+	}
+
+	ExecuteSetFontFile(parser.Command{Args: "https://example.com/mono.ttf"}, &v)
+	if len(v.Errors) != 0 {
+		t.Errorf("expected font URLs to be accepted, got %v", v.Errors)
+	}
+
+	for _, path := range []string{filepath.Join(t.TempDir(), "missing.ttf"), "mono.svg"} {
+		ExecuteSetFontFile(parser.Command{Args: path}, &v)
+	}
+	if len(v.Errors) != 2 {
+		t.Errorf("expected errors for a missing font and an unsupported format, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetFontWeight(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	if v.Options.FontWeight != "normal" || v.Options.FontWeightBold != "bold" {
+		t.Errorf("expected the xterm.js default weights, got %q and %q", v.Options.FontWeight, v.Options.FontWeightBold)
+	}
+
+	ExecuteSetFontWeight(parser.Command{Args: "300"}, &v)
+	ExecuteSetFontWeightBold(parser.Command{Args: "800"}, &v)
+	if v.Options.FontWeight != "300" || v.Options.FontWeightBold != "800" {
+		t.Errorf("expected weights 300 and 800, got %q and %q", v.Options.FontWeight, v.Options.FontWeightBold)
+	}
+
+	ExecuteSetFontWeight(parser.Command{Args: "heavy"}, &v)
+	if len(v.Errors) != 1 || v.Options.FontWeight != "300" {
+		t.Errorf("expected an error for an invalid weight, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetDebug(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	cmd := parser.Command{Type: token.SET, Options: "Debug", Args: "true"}
+	if !isStartCommand(cmd) {
+		t.Error("expected Debug to be applied before the browser is launched")
+	}
+	ExecuteSetDebug(cmd, &v)
+	if !v.Options.Debug {
+		t.Error("expected debug to be enabled")
+	}
+
+	ExecuteSetDebug(parser.Command{Args: "maybe"}, &v)
+	if len(v.Errors) != 1 || !v.Options.Debug {
+		t.Errorf("expected an error for an invalid boolean, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetHeadless(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	if !v.Options.Headless {
+		t.Error("expected the browser to be headless by default")
+	}
+	ExecuteSetHeadless(parser.Command{Args: "false"}, &v)
+	if v.Options.Headless {
+		t.Error("expected the browser to have a window")
+	}
+}
+
+func TestExecuteSetBrowserFlags(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetBrowserFlags(parser.Command{Args: "--no-sandbox  --window-size=800,600"}, &v)
+	want := []string{"--no-sandbox", "--window-size=800,600"}
+	if !reflect.DeepEqual(v.Options.BrowserFlags, want) {
+		t.Fatalf("expected flags %v, got %v", want, v.Options.BrowserFlags)
+	}
+	if name, value := browserFlag(want[0]); name != "no-sandbox" || value != "" {
+		t.Errorf("expected no-sandbox without a value, got %q=%q", name, value)
+	}
+	if name, value := browserFlag(want[1]); name != "window-size" || value != "800,600" {
+		t.Errorf("expected window-size=800,600, got %q=%q", name, value)
+	}
+
+	ExecuteSetBrowserFlags(parser.Command{Args: "no-sandbox"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for a flag without dashes, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetFFmpegArgs(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetFFmpegArgs(parser.Command{Args: `-tune animation -vf 'scale=320:-1, fps=10'`}, &v)
+	want := []string{"-tune", "animation", "-vf", "scale=320:-1, fps=10"}
+	if !reflect.DeepEqual(v.Options.Video.ExtraArgs, want) {
+		t.Fatalf("expected args %q, got %q", want, v.Options.Video.ExtraArgs)
+	}
+
+	ExecuteSetFFmpegArgs(parser.Command{Args: `-vf 'scale=320:-1`}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an unterminated quote, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetTrim(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetTrim(parser.Command{Options: "TrimStart", Args: "10"}, &v)
+	ExecuteSetTrim(parser.Command{Options: "TrimEnd", Args: "2s"}, &v)
+	if v.Options.Video.TrimStart != (Trim{Frames: 10}) {
+		t.Errorf("expected to trim 10 frames off the start, got %+v", v.Options.Video.TrimStart)
+	}
+	if v.Options.Video.TrimEnd != (Trim{Duration: 2 * time.Second}) {
+		t.Errorf("expected to trim 2s off the end, got %+v", v.Options.Video.TrimEnd)
+	}
+
+	ExecuteSetTrim(parser.Command{Options: "TrimEnd", Args: "-2s"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for a negative trim, got %v", v.Errors)
+	}
+}
+
+func TestExecuteOutput(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	for _, path := range []string{"demo.gif", "demo.mp4", "demo.webm", "frames/"} {
+		p := parser.New(lexer.New("Output " + path))
+		cmds := p.Parse()
+		if len(cmds) != 1 || len(p.Errors()) != 0 {
+			t.Fatalf("expected an Output command, got %v (%v)", cmds, p.Errors())
+		}
+		ExecuteOutput(cmds[0], &v)
+	}
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	want := VideoOutputs{GIF: "demo.gif", MP4: "demo.mp4", WebM: "demo.webm", Frames: "frames/"}
+	if v.Options.Video.Output != want {
+		t.Errorf("expected the outputs %+v, got %+v", want, v.Options.Video.Output)
+	}
+
+	ExecuteOutput(parser.Command{Type: token.OUTPUT, Options: ".mov", Args: "demo.mov"}, &v)
+	if len(v.Errors) != 1 || !strings.Contains(v.Errors[0].Error(), ".gif, .mp4, .webm") {
+		t.Errorf("expected an error listing the supported formats, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetExpectedOutput(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	path := filepath.Join(t.TempDir(), "expected.txt")
+	requireNoErr(t, os.WriteFile(path, []byte("$ echo hello\nhello\n"), os.ModePerm))
+	ExecuteSetExpectedOutput(parser.Command{Args: path}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	if v.Options.Test.Golden != path || v.Options.Test.ExpectedOutput != "$ echo hello\nhello\n" {
+		t.Errorf("expected the expected output to be read, got %+v", v.Options.Test)
+	}
+
+	ExecuteSetExpectedOutput(parser.Command{Args: filepath.Join(t.TempDir(), "missing.txt")}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for a missing file, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetPoster(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetPoster(parser.Command{Args: "poster.JPG"}, &v)
+	ExecuteSetPosterFrame(parser.Command{Args: "50%"}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	if v.Options.Video.Poster != "poster.JPG" || v.Options.Video.PosterFrame != 50 {
+		t.Errorf("expected the poster to be set, got %q at %v%%", v.Options.Video.Poster, v.Options.Video.PosterFrame)
+	}
+
+	ExecuteSetPoster(parser.Command{Args: "poster.gif"}, &v)
+	ExecuteSetPosterFrame(parser.Command{Args: "101"}, &v)
+	if len(v.Errors) != 2 {
+		t.Errorf("expected an error for each invalid setting, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetParallelRender(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetParallelRender(parser.Command{Args: "true"}, &v)
+	if !v.Options.Video.Parallel {
+		t.Errorf("expected the outputs to be rendered in parallel")
+	}
+	ExecuteSetParallelRender(parser.Command{Args: "maybe"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an invalid boolean, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetAudio(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	audio := filepath.Join(t.TempDir(), "narration.mp3")
+	if err := os.WriteFile(audio, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ExecuteSetAudio(parser.Command{Args: audio}, &v)
+	ExecuteSetAudioLoop(parser.Command{Args: "true"}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	if v.Options.Video.Audio != audio || !v.Options.Video.AudioLoop {
+		t.Errorf("expected the audio to be looped, got %q (loop: %t)", v.Options.Video.Audio, v.Options.Video.AudioLoop)
+	}
+
+	ExecuteSetAudio(parser.Command{Args: filepath.Join(t.TempDir(), "missing.mp3")}, &v)
+	ExecuteSetAudioLoop(parser.Command{Args: "maybe"}, &v)
+	if len(v.Errors) != 2 {
+		t.Errorf("expected an error for each invalid setting, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetBannerFile(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	banner := filepath.Join(t.TempDir(), "banner.txt")
+	if err := os.WriteFile(banner, []byte("\x1b[1mWelcome\x1b[0m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ExecuteSetBannerFile(parser.Command{Args: banner}, &v)
+	ExecuteSetBannerDuration(parser.Command{Args: "2s"}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	if v.Options.Banner != "\x1b[1mWelcome\x1b[0m\n" || v.Options.BannerDuration != 2*time.Second {
+		t.Errorf("expected the banner to be read from the file, got %q for %s", v.Options.Banner, v.Options.BannerDuration)
+	}
+
+	ExecuteSetBannerFile(parser.Command{Args: filepath.Join(t.TempDir(), "missing.txt")}, &v)
+	ExecuteSetBannerDuration(parser.Command{Args: "-1s"}, &v)
+	if len(v.Errors) != 2 {
+		t.Errorf("expected an error for each invalid setting, got %v", v.Errors)
+	}
+}
+
+func TestTypeLinesBracketedPaste(t *testing.T) {
+	// A terminal which asks for bracketed paste, and records what's pasted.
+	page := testPage(t, `<textarea autofocus></textarea>
+		<script>
+		window.pasted = "";
+		window.term = {
+			modes: { bracketedPasteMode: true },
+			paste: (text) => { window.pasted = text },
+		};
+		</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+
+	// The lines are typed unless BracketedPaste is set.
+	typeLines(&v, "a\nb", 0)
+	if pasted := page.MustEval("() => window.pasted").Str(); pasted != "" {
+		t.Errorf("expected the lines to be typed by default, got %q pasted", pasted)
+	}
+
+	v.Options.BracketedPaste = true
+	typeLines(&v, "cat <<EOF\nhi\nEOF\n", 0)
+	if pasted := page.MustEval("() => window.pasted").Str(); pasted != "cat <<EOF\nhi\nEOF" {
+		t.Errorf("expected the lines to be pasted, got %q", pasted)
+	}
+}
+
+func TestExecuteEcho(t *testing.T) {
+	// A terminal which records what's written to it, and sends what's typed
+	// to the program.
+	page := testPage(t, `<script>
+		window.term = {
+			written: [],
+			handlers: [],
+			onData(handler) { this.handlers.push(handler) },
+			write(data, callback) { this.written.push(data); callback && callback() },
+			type(data) { this.handlers.forEach((handler) => handler(data)) },
+		};
+		</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+
+	// The keys typed are hidden when the program echoes them, but not the
+	// escape sequences around them, or what it prints afterwards.
+	ExecuteEcho(parser.Command{Type: token.ECHO, Args: "off"}, &v)
+	page.MustEval(`() => {
+		term.type("secret\r");
+		term.write("sec");
+		term.write(new TextEncoder().encode("\x1b[1mret\r\nok"));
+	}`)
+	ExecuteEcho(parser.Command{Type: token.ECHO, Args: "on"}, &v)
+	page.MustEval(`() => { term.type("shown"); term.write("shown") }`)
+
+	written := page.MustEval("() => term.written.join('')").Str()
+	if want := "   \x1b[1m   \r\nokshown"; written != want {
+		t.Errorf("expected %q to be written, got %q", want, written)
+	}
+}
+
+func TestExecuteSetCleanupWait(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	if v.Options.CleanupWait != defaultCleanupWait {
+		t.Fatalf("expected the default cleanup wait, got %s", v.Options.CleanupWait)
+	}
+
+	ExecuteSetCleanupWait(parser.Command{Args: "0s"}, &v)
+	if len(v.Errors) != 0 || v.Options.CleanupWait != 0 {
+		t.Fatalf("expected no cleanup wait, got %s (%v)", v.Options.CleanupWait, v.Errors)
+	}
+	start := time.Now()
+	v.waitForCleanup()
+	if elapsed := time.Since(start); elapsed >= defaultCleanupWait {
+		t.Errorf("expected not to wait, waited %s", elapsed)
+	}
+
+	ExecuteSetCleanupWait(parser.Command{Args: "-1s"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for a negative wait, got %v", v.Errors)
+	}
+}
+
+func TestExecuteExecWithoutPrompt(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	// Without a prompt to wait for, the command isn't run, nor the recording
+	// paused.
+	ExecuteExec(parser.Command{Type: token.EXEC, Args: "cd /tmp"}, &v)
+	if len(v.Errors) != 1 || !errors.Is(v.Errors[0], errNoWaitPattern) {
+		t.Errorf("expected an error without a wait pattern, got %v", v.Errors)
+	}
+	if !v.recording {
+		t.Error("expected the recording not to be paused")
+	}
+}
+
+func TestExecuteSetExecClear(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	if v.Options.ExecClear {
+		t.Error("expected the screen not to be cleared after Exec by default")
+	}
+	ExecuteSetExecClear(parser.Command{Args: "true"}, &v)
+	if !v.Options.ExecClear {
+		t.Error("expected the screen to be cleared after Exec")
+	}
+	ExecuteSetExecClear(parser.Command{Args: "always"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an invalid boolean, got %v", v.Errors)
+	}
+}
+
+func TestExecuteLoopOffset(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteLoopOffset(parser.Command{Args: "25%"}, &v)
+	if v.Options.LoopOffset != 25 {
+		t.Fatalf("expected a loop offset of 25, got %v", v.Options.LoopOffset)
+	}
+
+	for _, offset := range []string{"100%", "-5%", "150", "half"} {
+		ExecuteLoopOffset(parser.Command{Args: offset}, &v)
+	}
+	if len(v.Errors) != 4 {
+		t.Errorf("expected an error for each invalid offset, got %v", v.Errors)
+	}
+	if v.Options.LoopOffset != 25 {
+		t.Errorf("expected the loop offset to be unchanged, got %v", v.Options.LoopOffset)
+	}
+}
+
+func TestExecuteSetControlURL(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetControlURL(parser.Command{Args: "ws://127.0.0.1:9222/devtools/browser/abc"}, &v)
+	if v.Options.ControlURL != "ws://127.0.0.1:9222/devtools/browser/abc" {
+		t.Fatalf("expected the control URL to be set, got %q", v.Options.ControlURL)
+	}
+
+	ExecuteSetControlURL(parser.Command{Args: "127.0.0.1:9222"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for a URL without a scheme, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSleep(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	start := time.Now()
+	ExecuteSleep(parser.Command{Args: "50ms"}, &v)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected to sleep for 50ms, slept for %s", elapsed)
+	}
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+
+	ExecuteSleep(parser.Command{Args: "-1s"}, &v)
+	ExecuteSleep(parser.Command{Args: "1.2.3s"}, &v)
+	if len(v.Errors) != 2 {
+		t.Errorf("expected errors for negative and invalid times, got %v", v.Errors)
+	}
+
+	// An interrupted tape doesn't sleep.
+	interrupt := make(chan struct{})
+	close(interrupt)
+	v.interrupt = interrupt
+	start = time.Now()
+	ExecuteSleep(parser.Command{Args: "10s"}, &v)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the sleep to stop once interrupted, slept for %s", elapsed)
+	}
+}
+
+func TestExecuteSourceTapeNested(t *testing.T) {
+	quiet := quietFlag
+	quietFlag = true
+	t.Cleanup(func() { quietFlag = quiet })
+
+	// The tapes source each other relative to their own directory.
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "s"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for name, tape := range map[string]string{
+		"s/b.tape": "Source c.tape\nSet FontSize 30\n",
+		"s/c.tape": "Set TypingSpeed 123ms\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(tape), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	ExecuteSourceTape(parser.Command{Type: token.SOURCE, Args: filepath.Join(dir, "s", "b.tape")}, &v)
+	if len(v.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", v.Errors)
+	}
+	if v.Options.TypingSpeed != 123*time.Millisecond {
+		t.Errorf("expected the nested tape to set the typing speed, got %s", v.Options.TypingSpeed)
+	}
+	if v.Options.FontSize != 30 {
+		t.Errorf("expected the sourced tape to set the font size, got %d", v.Options.FontSize)
+	}
+}
+
+func TestExecuteRequire(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	for _, program := range []string{"go", "vhs-missing-program", "vhs-other-missing-program"} {
+		ExecuteRequire(parser.Command{Type: token.REQUIRE, Args: program}, &v)
+	}
+	if len(v.Errors) != 2 {
+		t.Fatalf("expected an error for each missing program, got %v", v.Errors)
+	}
+	requireEqualErr(t, v.Errors[0], "`Require vhs-missing-program`: vhs-missing-program is not installed or not in $PATH")
+}
+
+func TestExecuteCopy(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteCopy(parser.Command{Type: token.COPY, Args: "echo one\necho two"}, &v)
+	if v.clipboard != "echo one\necho two" {
+		t.Errorf("expected copied text to be kept for Paste, got %q", v.clipboard)
+	}
+}
+
+func TestTermSizeJS(t *testing.T) {
+	opts := DefaultVHSOptions()
+	t.Cleanup(func() { _ = os.RemoveAll(opts.Video.Input) })
+
+	if got := termSizeJS(&opts); got != "term.fit" {
+		t.Errorf("expected terminal to be fit by default, got %q", got)
+	}
+
+	opts.TermCols = 80
+	if got, want := termSizeJS(&opts), "() => term.resize(80 || term.cols, 0 || term.rows)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	opts.TermRows = 24
+	if got, want := termSizeJS(&opts), "() => term.resize(80 || term.cols, 24 || term.rows)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestExecuteSetPrompt(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	// Before the terminal is set up, the prompt is only stored for Setup.
+	ExecuteSetPrompt(parser.Command{Args: "$ "}, &v)
+	if len(v.Errors) != 0 || v.Options.Prompt != "$ " {
+		t.Fatalf("expected the prompt to be set, got %q (%v)", v.Options.Prompt, v.Errors)
+	}
+
+	v.Options.Command = []string{"python3"}
+	ExecuteSetPrompt(parser.Command{Args: ">>> "}, &v)
+	if len(v.Errors) != 1 || v.Options.Prompt != "$ " {
+		t.Errorf("expected an error for a Command without a prompt, got %v", v.Errors)
+	}
+}
+
+func TestExecuteSetPixelFormat(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	ExecuteSetPixelFormat(parser.Command{Args: "yuv444p"}, &v)
+	if v.Options.Video.PixelFormat != "yuv444p" {
+		t.Errorf("expected the pixel format to be set, got %q", v.Options.Video.PixelFormat)
+	}
+
+	ExecuteSetPixelFormat(parser.Command{Args: "yuv444"}, &v)
+	if len(v.Errors) != 1 || !strings.Contains(v.Errors[0].Error(), "invalid `Set PixelFormat yuv444`") {
+		t.Errorf("expected an error for an unknown pixel format, got %v", v.Errors)
+	}
+	if v.Options.Video.PixelFormat != "yuv444p" {
+		t.Errorf("expected the pixel format to be unchanged, got %q", v.Options.Video.PixelFormat)
+	}
+}
+
+func TestExecuteSetCaptureQuality(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	if v.Options.Video.CaptureQuality != defaultCaptureQuality {
+		t.Fatalf("expected the default capture quality, got %f", v.Options.Video.CaptureQuality)
+	}
+
+	ExecuteSetCaptureQuality(parser.Command{Args: "0.5"}, &v)
+	if len(v.Errors) != 0 || v.Options.Video.CaptureQuality != 0.5 {
+		t.Fatalf("expected a capture quality of 0.5, got %f (%v)", v.Options.Video.CaptureQuality, v.Errors)
+	}
+
+	for _, q := range []string{"0", "1.5", "high"} {
+		ExecuteSetCaptureQuality(parser.Command{Args: q}, &v)
+	}
+	if len(v.Errors) != 3 || v.Options.Video.CaptureQuality != 0.5 {
+		t.Errorf("expected an error for each invalid quality, got %v", v.Errors)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"time"
+)
+
+// cursorVisible reports whether the cursor should be captured at the given
+// time since the recording started.
+//
+// This is only relevant when a custom CursorBlinkRate is set, in which case
+// VHS blinks the cursor itself instead of relying on xterm.js, whose blink
+// interval is fixed.
+func (vhs *VHS) cursorVisible(elapsed time.Duration) bool {
+	rate := vhs.Options.CursorBlinkRate
+	if !vhs.Options.CursorBlink || rate <= 0 {
+		return true
+	}
+	return (elapsed/rate)%2 == 0
+}
+
+// blankFrame returns a fully transparent PNG with the same dimensions as the
+// given PNG frame.
+func blankFrame(frame []byte) ([]byte, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	img := image.NewNRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compositeFrame draws the cursor PNG frame over the text frame and returns
+// the result as a single frame in the format of the text frame, png or jpeg.
+func compositeFrame(text, cursor []byte, format string, quality float64) ([]byte, error) {
+	textImg, _, err := image.Decode(bytes.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	cursorImg, err := png.Decode(bytes.NewReader(cursor))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := textImg.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), textImg, bounds.Min, draw.Src)
+	draw.Draw(img, img.Bounds(), cursorImg, cursorImg.Bounds().Min, draw.Over)
+
+	// Favor speed, frames are only kept until they are rendered.
+	var buf bytes.Buffer
+	if format == frameJPEG {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: int(math.Round(quality * 100))}); err != nil { //nolint:gomnd
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
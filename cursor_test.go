@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCursorVisible(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	t.Run("default blink", func(t *testing.T) {
+		for _, elapsed := range []time.Duration{0, time.Second, 3 * time.Second} {
+			if !v.cursorVisible(elapsed) {
+				t.Errorf("expected cursor to be visible at %s", elapsed)
+			}
+		}
+	})
+
+	t.Run("custom rate", func(t *testing.T) {
+		v.Options.CursorBlinkRate = 500 * time.Millisecond
+		tests := map[time.Duration]bool{
+			0:                      true,
+			499 * time.Millisecond: true,
+			500 * time.Millisecond: false,
+			999 * time.Millisecond: false,
+			time.Second:            true,
+		}
+		for elapsed, want := range tests {
+			if got := v.cursorVisible(elapsed); got != want {
+				t.Errorf("cursorVisible(%s) = %t, want %t", elapsed, got, want)
+			}
+		}
+	})
+
+	t.Run("blink disabled", func(t *testing.T) {
+		v.Options.CursorBlink = false
+		if !v.cursorVisible(750 * time.Millisecond) {
+			t.Error("expected cursor to always be visible when blink is disabled")
+		}
+	})
+}
+
+func TestBlankFrame(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 12, 7))
+	img.Set(1, 1, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	blank, err := blankFrame(buf.Bytes())
+	requireNoErr(t, err)
+
+	got, err := png.Decode(bytes.NewReader(blank))
+	requireNoErr(t, err)
+	if got.Bounds() != img.Bounds() {
+		t.Errorf("expected bounds %v, got %v", img.Bounds(), got.Bounds())
+	}
+	if _, _, _, a := got.At(1, 1).RGBA(); a != 0 {
+		t.Error("expected blank frame to be transparent")
+	}
+}
+
+func TestCompositeFrame(t *testing.T) {
+	text := image.NewNRGBA(image.Rect(0, 0, 12, 7))
+	text.Set(1, 1, color.White)
+	text.Set(2, 2, color.White)
+	cursor := image.NewNRGBA(image.Rect(0, 0, 12, 7))
+	cursor.Set(2, 2, color.NRGBA{R: 0xff, A: 0xff})
+
+	frame, err := compositeFrame(encodePNG(t, text), encodePNG(t, cursor), framePNG, 1)
+	requireNoErr(t, err)
+
+	got, err := png.Decode(bytes.NewReader(frame))
+	requireNoErr(t, err)
+	if got.Bounds() != text.Bounds() {
+		t.Errorf("expected bounds %v, got %v", text.Bounds(), got.Bounds())
+	}
+	if r, g, b, _ := got.At(1, 1).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Error("expected text to be kept where there is no cursor")
+	}
+	if r, g, b, _ := got.At(2, 2).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Error("expected cursor to be drawn over the text")
+	}
+
+	// JPEG text frames stay JPEG images once the cursor is drawn.
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, text, nil); err != nil {
+		t.Fatal(err)
+	}
+	frame, err = compositeFrame(buf.Bytes(), encodePNG(t, cursor), frameJPEG, 0.8)
+	requireNoErr(t, err)
+	if _, format, err := image.DecodeConfig(bytes.NewReader(frame)); err != nil || format != "jpeg" {
+		t.Errorf("expected a JPEG frame, got %q (%v)", format, err)
+	}
+}
+
+// BenchmarkSeparateFrames measures writing the text and cursor frames as two
+// files, leaving the overlay to ffmpeg.
+func BenchmarkSeparateFrames(b *testing.B) {
+	text, cursor := benchmarkFrames(b)
+	dir := b.TempDir()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf(textFrameFormat, i)), text, os.ModePerm); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf(cursorFrameFormat, i)), cursor, os.ModePerm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCompositeFrames measures compositing the cursor in Go and writing
+// a single file per frame.
+func BenchmarkCompositeFrames(b *testing.B) {
+	text, cursor := benchmarkFrames(b)
+	dir := b.TempDir()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame, err := compositeFrame(text, cursor, framePNG, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf(textFrameFormat, i)), frame, os.ModePerm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkFrames returns a text and cursor frame the size of the default
+// terminal.
+func benchmarkFrames(tb testing.TB) ([]byte, []byte) {
+	tb.Helper()
+	text := image.NewNRGBA(image.Rect(0, 0, defaultWidth, defaultHeight))
+	for y := 0; y < defaultHeight; y += 2 {
+		for x := 0; x < defaultWidth; x += 3 {
+			text.Set(x, y, color.White)
+		}
+	}
+	cursor := image.NewNRGBA(image.Rect(0, 0, defaultWidth, defaultHeight))
+	for y := 20; y < 40; y++ {
+		for x := 20; x < 30; x++ {
+			cursor.Set(x, y, color.White)
+		}
+	}
+	return encodePNG(tb, text), encodePNG(tb, cursor)
+}
+
+func encodePNG(tb testing.TB, img image.Image) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// duplicateFrames returns the number of frames identical to the frame before
+// them, comparing the bytes of the text and cursor frames, and how many of
+// those end the sequence of frames.
+func duplicateFrames(opts VideoOptions, frames int) (duplicates, last int, err error) {
+	formats := []string{opts.textFrames()}
+	if opts.cursorFrames() {
+		formats = append(formats, cursorFrameFormat)
+	}
+
+	prev := make([][]byte, len(formats))
+	for i := 0; i < frames; i++ {
+		frame := opts.StartingFrame + i
+		same := i > 0
+		for j, format := range formats {
+			bts, err := os.ReadFile(filepath.Join(opts.Input, fmt.Sprintf(format, frame)))
+			if err != nil {
+				return 0, 0, fmt.Errorf("could not read frame %d: %w", frame, err)
+			}
+			same = same && bytes.Equal(bts, prev[j])
+			prev[j] = bts
+		}
+		if same {
+			duplicates++
+			last++
+		} else {
+			last = 0
+		}
+	}
+	return duplicates, last, nil
+}
+
+// dedupFrames finds the identical frames which Dedup collapses, once the
+// frames are in their final order. Dedup is turned off when there are none,
+// so that ffmpeg doesn't compare the frames for nothing.
+func (vhs *VHS) dedupFrames() error {
+	if !vhs.Options.Video.Dedup {
+		return nil
+	}
+	duplicates, last, err := duplicateFrames(vhs.Options.Video, vhs.totalFrames)
+	if err != nil {
+		return fmt.Errorf("could not deduplicate frames: %w", err)
+	}
+	vhs.Options.Video.Dedup = duplicates > 0
+	vhs.Options.Video.lastDuplicates = last
+	return nil
+}
+
+// finalDelay returns the delay of the last frame of a deduplicated GIF, in
+// centiseconds, which is held for as long as it and its duplicates would be.
+func (opts VideoOptions) finalDelay() int {
+	seconds := float64(opts.lastDuplicates+1) / float64(opts.captureFramerate()) / opts.PlaybackSpeed
+	if opts.variableFramerate() {
+		var held time.Duration
+		for _, d := range opts.frameDurations[len(opts.frameDurations)-opts.lastDuplicates-1:] {
+			held += d
+		}
+		seconds = held.Seconds() / opts.PlaybackSpeed
+	}
+	return int(math.Round(seconds * 100)) //nolint:gomnd
+}
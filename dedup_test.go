@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDedupFrames(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.Video.HideCursor = true
+	if err := os.MkdirAll(v.Options.Video.Input, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	bg := color.RGBA{A: 0xff}
+
+	// Frames 2, 3 and 6 repeat the frame before them, and 6 ends the frames.
+	frames := []bool{false, false, false, true, false, false}
+	for i, text := range frames {
+		path := filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, i+1))
+		if err := os.WriteFile(path, testFrame(t, bg, text), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v.totalFrames = len(frames)
+
+	duplicates, last, err := duplicateFrames(v.Options.Video, v.totalFrames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duplicates != 3 || last != 1 {
+		t.Errorf("expected 3 duplicates, 1 of them last, got %d and %d", duplicates, last)
+	}
+
+	v.Options.Video.Dedup = true
+	if err := v.dedupFrames(); err != nil || !v.Options.Video.Dedup {
+		t.Fatalf("expected the frames to be deduplicated, got %v", err)
+	}
+	// The last frame is held for 2 frames at 50 frames per second.
+	if delay := v.Options.Video.finalDelay(); delay != 4 {
+		t.Errorf("expected a final delay of 4cs, got %d", delay)
+	}
+
+	// There is nothing to deduplicate in a single frame.
+	v.totalFrames = 1
+	if err := v.dedupFrames(); err != nil || v.Options.Video.Dedup {
+		t.Errorf("expected Dedup to be turned off without duplicates, got %v", err)
+	}
+}
+
+func TestBuildFFoptsDedup(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.Dedup = true
+	opts.lastDuplicates = 49
+
+	args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+	for _, want := range []string{"mpdecimate=hi=0:lo=0:frac=0[deduped]", "[deduped]split", "-vsync vfr -final_delay 100"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected the GIF to be deduplicated with %q, got: %s", want, args)
+		}
+	}
+	if args := strings.Join(buildFFopts(opts, "out.mp4"), " "); strings.Contains(args, "mpdecimate") {
+		t.Errorf("expected the MP4 not to be deduplicated, got: %s", args)
+	}
+}
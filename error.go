@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os/exec"
 	"strings"
 
 	"github.com/charmbracelet/vhs/parser"
@@ -17,6 +20,96 @@ func (e InvalidSyntaxError) Error() string {
 	return fmt.Sprintf("parser: %d error(s)", len(e.Errors))
 }
 
+// FFmpegError is returned when ffmpeg fails to render an output.
+type FFmpegError struct {
+	// Format is the kind of output, e.g. GIF or MP4.
+	Format string
+	// Output is the file that was being rendered.
+	Output string
+	// ExitCode is the exit code of ffmpeg, or -1 if it couldn't be run.
+	ExitCode int
+	// Log is the combined output of ffmpeg.
+	Log string
+	Err error
+}
+
+func newFFmpegError(format string, cmd *exec.Cmd, out []byte, err error) FFmpegError {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	return FFmpegError{
+		Format:   format,
+		Output:   cmd.Args[len(cmd.Args)-1],
+		ExitCode: exitCode,
+		Log:      string(out),
+		Err:      err,
+	}
+}
+
+func (e FFmpegError) Error() string {
+	return fmt.Sprintf("could not create %s %s: ffmpeg exited with code %d", e.Format, e.Output, e.ExitCode)
+}
+
+func (e FFmpegError) Unwrap() error {
+	return e.Err
+}
+
+// RenderError is returned when ffmpeg fails to render one or more outputs.
+type RenderError struct {
+	Errors []FFmpegError
+}
+
+func (e RenderError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// InterruptedError is returned when the tape is interrupted, e.g. with Ctrl+C,
+// once what was recorded until then is rendered to the Outputs.
+type InterruptedError struct {
+	Outputs []string
+}
+
+func (e InterruptedError) Error() string {
+	if len(e.Outputs) == 0 {
+		return "recording interrupted"
+	}
+	return "recording interrupted, the partial recording was written to " + strings.Join(e.Outputs, ", ")
+}
+
+// Unwrap returns context.Canceled, since the tape is interrupted by cancelling
+// the context.
+func (e InterruptedError) Unwrap() error {
+	return context.Canceled
+}
+
+// multiError collects the errors of a batch of operations which don't stop at
+// the first failure.
+type multiError []error
+
+func (e multiError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches the target.
+func (e multiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // ErrorColumnOffset is the number of columns that an error should be printed
 // to the left to account for the line number.
 const ErrorColumnOffset = 5
@@ -51,6 +144,20 @@ func printErrors(out io.Writer, tape string, errs []error) {
 			}
 			fmt.Fprintln(out, ErrorStyle.Render(err.Error()))
 
+		case RenderError:
+			for _, v := range err.Errors {
+				fmt.Fprintln(out, ErrorStyle.Render(v.Error()))
+			}
+
+		case InterruptedError:
+			fmt.Fprintln(out, ErrorStyle.Render("Recording interrupted."))
+			if len(err.Outputs) > 0 {
+				fmt.Fprintln(out, "The partial recording was written to:")
+				for _, path := range err.Outputs {
+					fmt.Fprintln(out, "  "+StringStyle.Render(path))
+				}
+			}
+
 		default:
 			fmt.Fprintln(out, ErrorStyle.Render(err.Error()))
 		}
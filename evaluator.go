@@ -2,9 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 
 	"github.com/charmbracelet/vhs/lexer"
@@ -12,14 +12,128 @@ import (
 	"github.com/charmbracelet/vhs/token"
 )
 
+// startSettings are the settings which are needed to start the terminal, so
+// they are applied before anything else.
+var startSettings = map[string]bool{
+	"Shell":        true,
+	"Port":         true,
+	"WorkingDir":   true,
+	"Debug":        true,
+	"Headless":     true,
+	"BrowserFlags": true,
+	"ControlURL":   true,
+	"Command":      true,
+	"Tmux":         true,
+}
+
+// liveSettings are the settings which can be changed during the recording.
+// The other settings must come before the first command interacting with the
+// terminal, since changing them once it is set up doesn't render properly.
+var liveSettings = map[string]bool{
+	"TypingSpeed":   true,
+	"FontSize":      true,
+	"LetterSpacing": true,
+	"LineHeight":    true,
+	"Prompt":        true,
+}
+
+// checkLiveSettings returns an error for each setting which comes after the
+// first command interacting with the terminal, but can't be changed during
+// the recording. Start settings are applied first wherever they are.
+func checkLiveSettings(cmds []parser.Command) []error {
+	var errs []error
+	var started bool
+	for _, cmd := range cmds {
+		if !isConfigCommand(cmd) {
+			started = true
+			continue
+		}
+		if !started || cmd.Type != token.SET || liveSettings[cmd.Options] || isStartCommand(cmd) {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("`Set %s` can't be changed during the recording, move it to the top of the tape", cmd.Options))
+	}
+	return errs
+}
+
+// isStartCommand returns whether the command is needed to start the terminal,
+// or checks a requirement of the tape. Such commands are executed before
+// anything else, wherever they are in the tape.
+func isStartCommand(cmd parser.Command) bool {
+	switch cmd.Type {
+	case token.ENV, token.REQUIRE:
+		return true
+	case token.SET:
+		return startSettings[cmd.Options]
+	default:
+		return false
+	}
+}
+
+// isConfigCommand returns whether the command only configures the VHS
+// instance, rather than interacting with the terminal.
+func isConfigCommand(cmd parser.Command) bool {
+	switch cmd.Type {
+	case token.SET, token.OUTPUT, token.REQUIRE, token.ENV:
+		return true
+	default:
+		return false
+	}
+}
+
+// newParser returns the parser for the tape at the given path, which relative
+// Source and TypeFile paths are resolved against. Without a path, e.g. when
+// the tape is read from stdin, they are resolved against the working
+// directory.
+func newParser(tape, path string) *parser.Parser {
+	l := lexer.New(tape)
+	if path == "" {
+		return parser.New(l)
+	}
+	return parser.NewWithPath(l, path)
+}
+
+// Validate parses the tape at the given path and applies its settings,
+// outputs and requirements without starting the terminal or recording
+// anything. It returns the errors that Evaluate would report before recording.
+func Validate(tape, path string) []error {
+	p := newParser(tape, path)
+
+	cmds := p.Parse()
+	if errs := p.Errors(); len(errs) != 0 {
+		return []error{InvalidSyntaxError{errs}}
+	}
+
+	v := New()
+	defer func() { _ = v.Cleanup() }()
+
+	for _, cmd := range cmds {
+		if isConfigCommand(cmd) {
+			CommandFuncs[cmd.Type](cmd, &v)
+		}
+	}
+	if err := v.checkDimensions(); err != nil {
+		v.Errors = append(v.Errors, err)
+	}
+	v.Errors = append(v.Errors, checkLiveSettings(cmds)...)
+	v.Errors = append(v.Errors, v.checkStreaming(cmds)...)
+
+	return v.Errors
+}
+
 // EvaluatorOption is a function that can be used to modify the VHS instance.
 type EvaluatorOption func(*VHS)
 
 // Evaluate takes as input a tape string, an output writer, and an output file
 // and evaluates all the commands within the tape string and produces a GIF.
-func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...EvaluatorOption) []error {
-	l := lexer.New(tape)
-	p := parser.New(l)
+// The path of the tape is used to resolve relative paths in the tape, and is
+// empty when the tape isn't read from a file.
+//
+// If the tape sets Theme.Dark or Theme.Light, it is recorded once for each of
+// these themes, and the outputs are suffixed with the variant, e.g.
+// demo-dark.gif and demo-light.gif.
+func Evaluate(ctx context.Context, tape, path string, out io.Writer, opts ...EvaluatorOption) []error {
+	p := newParser(tape, path)
 
 	cmds := p.Parse()
 	errs := p.Errors()
@@ -27,12 +141,48 @@ func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...Evaluator
 		return []error{InvalidSyntaxError{errs}}
 	}
 
+	variants := tapeThemeVariants(cmds)
+	if len(variants) == 0 {
+		return evaluate(ctx, cmds, out, "", opts...)
+	}
+	for _, variant := range variants {
+		if errs := evaluate(ctx, cmds, out, variant, opts...); len(errs) > 0 {
+			return errs
+		}
+	}
+	return nil
+}
+
+// tapeThemeVariants returns the theme variants set by the tape, in order.
+func tapeThemeVariants(cmds []parser.Command) []string {
+	var variants []string
+	seen := map[string]bool{}
+	for _, cmd := range cmds {
+		variant, ok := themeVariants[cmd.Options]
+		if cmd.Type != token.SET || !ok || seen[variant] {
+			continue
+		}
+		seen[variant] = true
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// evaluate records the commands with the given theme variant, if any.
+func evaluate(ctx context.Context, cmds []parser.Command, out io.Writer, variant string, opts ...EvaluatorOption) []error {
 	v := New()
+	v.themeVariant = variant
+	if r, ok := out.(ProgressReporter); ok {
+		v.progress = r.Progress
+	}
 	for _, cmd := range cmds {
-		if cmd.Type == token.SET && cmd.Options == "Shell" {
+		if isStartCommand(cmd) {
 			Execute(cmd, &v)
 		}
 	}
+	if len(v.Errors) > 0 {
+		return v.Errors
+	}
 
 	// Start things up
 	if err := v.Start(); err != nil {
@@ -43,9 +193,9 @@ func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...Evaluator
 	// Run Output and Set commands as they only modify options on the VHS instance.
 	var offset int
 	for i, cmd := range cmds {
-		if cmd.Type == token.SET || cmd.Type == token.OUTPUT || cmd.Type == token.REQUIRE {
+		if isConfigCommand(cmd) {
 			fmt.Fprintln(out, Highlight(cmd, false))
-			if cmd.Options != "Shell" {
+			if !isStartCommand(cmd) {
 				Execute(cmd, &v)
 			}
 		} else {
@@ -54,21 +204,20 @@ func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...Evaluator
 		}
 	}
 
-	// Make sure image is big enough to fit padding, bar, and margins
-	video := v.Options.Video
-	minWidth := double(video.Style.Padding) + double(video.Style.Margin)
-	minHeight := double(video.Style.Padding) + double(video.Style.Margin)
-	if video.Style.WindowBar != "" {
-		minHeight += video.Style.WindowBarSize
+	if err := v.checkDimensions(); err != nil {
+		v.Errors = append(v.Errors, err)
 	}
-	if video.Style.Height < minHeight || video.Style.Width < minWidth {
-		v.Errors = append(
-			v.Errors,
-			fmt.Errorf(
-				"Dimensions must be at least %d x %d",
-				minWidth, minHeight,
-			),
-		)
+	v.Errors = append(v.Errors, checkLiveSettings(cmds[offset:])...)
+	v.Errors = append(v.Errors, v.checkStreaming(cmds)...)
+
+	v.Options.Test.Output = variantPath(v.Options.Test.Output, variant)
+	v.Options.Video.Output = v.Options.Video.Output.withVariant(variant)
+	v.Options.Video.Poster = variantPath(v.Options.Video.Poster, variant)
+	v.Options.Video.TextDump = variantPath(v.Options.Video.TextDump, variant)
+
+	// Make sure we can render before recording anything
+	if err := checkFFmpeg(v.Options.Video.ffmpeg()); err != nil {
+		v.Errors = append(v.Errors, err)
 	}
 
 	if len(v.Errors) > 0 {
@@ -76,7 +225,9 @@ func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...Evaluator
 	}
 
 	// Setup the terminal session so we can start executing commands.
-	v.Setup()
+	if err := v.Setup(); err != nil {
+		return []error{err}
+	}
 
 	// If the first command (after Settings and Outputs) is a Hide command, we can
 	// begin executing the commands before we start recording to avoid capturing
@@ -92,7 +243,21 @@ func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...Evaluator
 		}
 	}
 
-	// Begin recording frames as we are now in a recording state.
+	// Show the banner after the hidden commands, which may clear the screen.
+	if err := v.showBanner(); err != nil {
+		v.Errors = append(v.Errors, err)
+	}
+
+	// Start ffmpeg before recording when the frames are streamed into it.
+	if err := v.startStream(); err != nil {
+		return []error{err}
+	}
+
+	// Begin recording frames as we are now in a recording state. The
+	// recording stops once the context is cancelled, e.g. on Ctrl+C, which
+	// interrupts the tape.
+	interrupt := ctx
+	v.interrupt = interrupt.Done()
 	ctx, cancel := context.WithCancel(ctx)
 	ch := v.Record(ctx)
 
@@ -106,43 +271,83 @@ func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...Evaluator
 		_ = v.Cleanup()
 	}()
 
+	// Log errors from the recording process, and stop executing commands if
+	// the recording runs for too long, or the program in the terminal exits.
+	var maxDurationErr error
+	var exited bool
+	recorded := make(chan struct{})
+	go func() {
+		defer close(recorded)
+		for err := range ch {
+			if errors.Is(err, ErrMaxDuration) {
+				maxDurationErr = err
+				cancel()
+				continue
+			}
+			if errors.Is(err, ErrTerminalExited) {
+				exited = true
+				cancel()
+				continue
+			}
+			v.logMessage(err.Error())
+		}
+	}()
+
 	teardown := func() {
 		// Stop recording frames.
 		cancel()
-		// Read from channel to ensure recorder is done.
-		<-ch
+		// Wait for the channel to be drained to ensure recorder is done.
+		<-recorded
 	}
 
-	// Log errors from the recording process.
-	go func() {
-		for err := range ch {
-			log.Print(err.Error())
-		}
-	}()
+	// Hold the banner before the first command.
+	if v.Options.Banner != "" && v.Options.BannerDuration > 0 {
+		_ = v.sleep(v.Options.BannerDuration)
+	}
 
+	var interrupted bool
 	for _, cmd := range cmds[offset:] {
 		if ctx.Err() != nil {
+			// The rest of the tape is skipped, and what was recorded
+			// is rendered.
+			if exited && interrupt.Err() == nil {
+				v.logStatus("The program in the terminal exited, stopping the recording")
+				break
+			}
 			teardown()
-			return []error{ctx.Err()}
+			if maxDurationErr != nil {
+				v.Errors = append(v.Errors, maxDurationErr)
+				return v.Errors
+			}
+			if v.totalFrames == 0 {
+				return []error{ctx.Err()}
+			}
+			interrupted = true
+			v.logStatus("Interrupted, rendering what was recorded so far")
+			break
 		}
 
-		// When changing the FontFamily, FontSize, LineHeight, Padding
-		// The xterm.js canvas changes dimensions and causes FFMPEG to not work
-		// correctly (specifically) with palettegen.
-		// It will be possible to change settings on the fly in the future, but
-		// it is currently not as it does not result in a proper render of the
-		// GIF as the frame sequence will change dimensions. This is fixable.
-		//
-		// We should remove if isSetting statement.
-		isSetting := cmd.Type == token.SET && cmd.Options != "TypingSpeed"
-		if isSetting || cmd.Type == token.REQUIRE {
+		// Only live settings are changed during the recording, the others
+		// were either applied before starting or rejected by
+		// checkLiveSettings.
+		isSetting := cmd.Type == token.SET && !liveSettings[cmd.Options]
+		if isSetting || cmd.Type == token.REQUIRE || cmd.Type == token.ENV {
 			fmt.Fprintln(out, Highlight(cmd, true))
 			continue
 		}
-		fmt.Fprintln(out, Highlight(cmd, !v.recording || cmd.Type == token.SHOW || cmd.Type == token.HIDE || isSetting))
+		fmt.Fprintln(out, Highlight(cmd, !v.recording || cmd.Type == token.SHOW || cmd.Type == token.HIDE))
 		Execute(cmd, &v)
 	}
 
+	// Compare the final screen with the expected output, if any. A mismatch
+	// is reported after the outputs are rendered, so they can be looked at.
+	// An interrupted tape has no final screen to compare.
+	if !interrupted {
+		if err := v.checkExpectedOutput(); err != nil {
+			v.Errors = append(v.Errors, err)
+		}
+	}
+
 	// If running as an SSH server, the output file is a temporary file
 	// to use for the output.
 	//
@@ -155,9 +360,56 @@ func Evaluate(ctx context.Context, tape string, out io.Writer, opts ...Evaluator
 		opt(&v)
 	}
 
+	// Read the cast events before the browser is closed. It's already closed
+	// once the tape is interrupted, so there is no cast then.
+	if interrupted {
+		v.Options.Video.Output.Cast = ""
+	}
+	if v.Options.Video.Output.Cast != "" {
+		if err := v.SaveCast(); err != nil {
+			teardown()
+			return []error{err}
+		}
+	}
+
 	teardown()
+	if maxDurationErr != nil {
+		v.Errors = append(v.Errors, maxDurationErr)
+		return v.Errors
+	}
 	if err := v.Render(); err != nil {
 		return []error{err}
 	}
+
+	// The errors of the commands cut short by the interruption are left out.
+	if interrupted {
+		return []error{InterruptedError{Outputs: v.Options.Video.renderedOutputs(v.totalFrames)}}
+	}
+
+	// Report errors of commands executed during the recording.
+	if len(v.Errors) > 0 {
+		return v.Errors
+	}
+	return nil
+}
+
+// checkDimensions makes sure the image is big enough to fit the padding, bar,
+// and margins, and that the crop fits in the terminal.
+func (vhs *VHS) checkDimensions() error {
+	style := vhs.Options.Video.Style
+	minWidth := double(style.Padding) + double(style.Margin)
+	minHeight := double(style.Padding) + double(style.Margin)
+	if style.WindowBar != "" {
+		minHeight += style.WindowBarSize
+	}
+	if style.Height < minHeight || style.Width < minWidth {
+		return fmt.Errorf("Dimensions must be at least %d x %d", minWidth, minHeight)
+	}
+
+	crop := vhs.Options.Video.Crop
+	termWidth, termHeight := calcTermDimensions(*style)
+	if crop.X+crop.Width > termWidth || crop.Y+crop.Height > termHeight {
+		return fmt.Errorf("Crop %d %d %d %d must fit in the terminal of %d x %d", crop.X, crop.Y, crop.Width, crop.Height, termWidth, termHeight)
+	}
 	return nil
 }
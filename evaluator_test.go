@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/vhs/lexer"
+	"github.com/charmbracelet/vhs/parser"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tape := "Output out.gif\nSet FontSize 32\nRequire go\nType \"echo hi\"\nEnter\n"
+		if errs := Validate(tape, ""); len(errs) != 0 {
+			t.Errorf("expected no errors, got %v", errs)
+		}
+	})
+
+	t.Run("syntax", func(t *testing.T) {
+		errs := Validate("Set FontSize\nTpye foo\n", "")
+		if len(errs) != 1 {
+			t.Fatalf("expected a single error, got %v", errs)
+		}
+		var syntaxErr InvalidSyntaxError
+		if !errors.As(errs[0], &syntaxErr) {
+			t.Errorf("expected a syntax error, got %v", errs[0])
+		}
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		tape := "Require vhs-missing-program\nSet Theme \"vhs-missing-theme\"\nSet Padding 400\n"
+		if errs := Validate(tape, ""); len(errs) != 3 {
+			t.Errorf("expected 3 errors, got %v", errs)
+		}
+	})
+
+	t.Run("crop", func(t *testing.T) {
+		if errs := Validate("Set Crop 0 0 400 200\n", ""); len(errs) != 0 {
+			t.Errorf("expected the crop to fit, got %v", errs)
+		}
+		errs := Validate("Set Crop 1000 0 400 200\n", "")
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "must fit in the terminal of 1200 x 600") {
+			t.Errorf("expected the crop not to fit, got %v", errs)
+		}
+	})
+
+	t.Run("live settings", func(t *testing.T) {
+		tape := "Type foo\nSet LineHeight 1.5\nSet FontSize 46\nSet LetterSpacing 2\nSet TypingSpeed 10ms\nSet Padding 10\nSet Shell bash\n"
+		errs := Validate(tape, "")
+		if len(errs) != 1 {
+			t.Fatalf("expected a single error, got %v", errs)
+		}
+		if !strings.Contains(errs[0].Error(), "Set Padding") {
+			t.Errorf("expected Padding not to be changed live, got %v", errs[0])
+		}
+	})
+
+	t.Run("relative paths", func(t *testing.T) {
+		dir := t.TempDir()
+		requireNoErr(t, os.WriteFile(filepath.Join(dir, "setup.tape"), []byte("Set FontSize 32\n"), 0o600))
+		requireNoErr(t, os.WriteFile(filepath.Join(dir, "input.txt"), []byte("echo hi\n"), 0o600))
+		tape := "Source setup.tape\nTypeFile input.txt\n"
+
+		// The paths are resolved against the tape, not the working directory.
+		wd, err := os.Getwd()
+		requireNoErr(t, err)
+		requireNoErr(t, os.Chdir(t.TempDir()))
+		t.Cleanup(func() { _ = os.Chdir(wd) })
+
+		if errs := Validate(tape, filepath.Join(dir, "demo.tape")); len(errs) != 0 {
+			t.Errorf("expected the paths to be resolved against the tape, got %v", errs)
+		}
+		if errs := Validate(tape, ""); len(errs) != 1 {
+			t.Errorf("expected the paths not to be found in the working directory, got %v", errs)
+		}
+	})
+}
+
+func TestTapeThemeVariants(t *testing.T) {
+	tape := "Set Theme.Light \"Catppuccin Latte\"\nSet Theme \"Dracula\"\nSet Theme.Dark \"Catppuccin Mocha\"\nSet Theme.Light \"Catppuccin Frappe\"\n"
+	cmds := parser.New(lexer.New(tape)).Parse()
+	want := []string{"light", "dark"}
+	if got := tapeThemeVariants(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected variants %v, got %v", want, got)
+	}
+}
+
+func TestInterruptedError(t *testing.T) {
+	opts := DefaultVideoOptions()
+	opts.Output.MP4 = "demo.mp4"
+	opts.Poster = "poster.png"
+
+	// The poster isn't rendered without frames.
+	if got, want := opts.renderedOutputs(0), []string{"demo.mp4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected outputs %v, got %v", want, got)
+	}
+	err := error(InterruptedError{Outputs: opts.renderedOutputs(10)})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the interruption to be a cancellation, got %v", err)
+	}
+	if !strings.HasSuffix(err.Error(), "written to demo.mp4, poster.png") {
+		t.Errorf("expected the partial outputs to be listed, got %q", err)
+	}
+
+	var sb strings.Builder
+	printErrors(&sb, "", []error{err})
+	if !strings.Contains(sb.String(), "poster.png") {
+		t.Errorf("expected the partial outputs to be printed, got %q", sb.String())
+	}
+}
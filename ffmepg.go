@@ -14,6 +14,8 @@ type FilterComplexBuilder struct {
 	termWidth     int
 	termHeight    int
 	prevStageName string
+	// audioStageName is the stage of the audio track, if any.
+	audioStageName string
 }
 
 // NewVideoFilterBuilder returns instance of FilterComplexBuilder with video config.
@@ -21,19 +23,33 @@ func NewVideoFilterBuilder(videoOpts *VideoOptions) *FilterComplexBuilder {
 	filterCode := strings.Builder{}
 	termWidth, termHeight := calcTermDimensions(*videoOpts.Style)
 
+	// Without separate cursor frames, the text frames are the only frames.
+	merged := "0"
+	if videoOpts.cursorFrames() {
+		merged = "merged"
+		filterCode.WriteString("[0][1]overlay[merged];")
+	}
+
+	// Downscale the frames of a scaled terminal with a sharper algorithm.
+	var scaleFlags string
+	if videoOpts.Scale > 1 {
+		scaleFlags = ":flags=lanczos"
+	}
+
 	filterCode.WriteString(
 		fmt.Sprintf(`
-		[0][1]overlay[merged];
-		[merged]scale=%d:%d:force_original_aspect_ratio=1[scaled];
-		[scaled]fps=%d,setpts=PTS/%f[speed];
+		[%s]scale=%d:%d:force_original_aspect_ratio=1%s[scaled];
+		[scaled]setpts=PTS/%f,fps=%d[speed];
 		[speed]pad=%d:%d:(ow-iw)/2:(oh-ih)/2:%s[padded];
 		[padded]fillborders=left=%d:right=%d:top=%d:bottom=%d:mode=fixed:color=%s[padded]
 		`,
+			merged,
 			termWidth-double(videoOpts.Style.Padding),
 			termHeight-double(videoOpts.Style.Padding),
+			scaleFlags,
 
-			videoOpts.Framerate,
 			videoOpts.PlaybackSpeed,
+			videoOpts.Framerate,
 
 			termWidth,
 			termHeight,
@@ -108,6 +124,14 @@ func calcTermDimensions(style StyleOptions) (int, int) {
 	return width, height
 }
 
+// calcViewportDimensions computes the dimensions of the browser viewport, that
+// is the terminal dimensions without the padding added during the render.
+// It returns width and height values.
+func calcViewportDimensions(style StyleOptions) (int, int) {
+	width, height := calcTermDimensions(style)
+	return width - double(style.Padding), height - double(style.Padding)
+}
+
 // WithWindowBarW adds window bar options to ffmepg filter_complex.
 func (fb *FilterComplexBuilder) WithWindowBar(barStream int) *FilterComplexBuilder {
 	if fb.style.WindowBar != "" {
@@ -172,15 +196,142 @@ func (fb *FilterComplexBuilder) WithMarginFill(marginStream int) *FilterComplexB
 	return fb
 }
 
+// WithCrop crops the video to the rectangle of the terminal, if any. The
+// rectangle is relative to the terminal, including its padding, so it's moved
+// past the margin and the window bar.
+func (fb *FilterComplexBuilder) WithCrop(crop Crop) *FilterComplexBuilder {
+	if crop.Width == 0 || crop.Height == 0 {
+		return fb
+	}
+	x, y := crop.X, crop.Y
+	if fb.style.MarginFill != "" {
+		x += fb.style.Margin
+		y += fb.style.Margin
+	}
+	if fb.style.WindowBar != "" {
+		y += fb.style.WindowBarSize
+	}
+
+	fb.filterComplex.WriteString(";")
+	fb.filterComplex.WriteString(
+		fmt.Sprintf(`
+			[%s]crop=%d:%d:%d:%d[cropped]
+			`,
+			fb.prevStageName,
+			crop.Width,
+			crop.Height,
+			x,
+			y,
+		),
+	)
+	fb.prevStageName = "cropped"
+
+	return fb
+}
+
+// WithCaption draws the caption of the style over the video, if any, and the
+// timed captions while they are shown. The caption of the style is hidden
+// while a timed caption is shown, so they don't overlap. The texts of the
+// captions are read from the files written to the input directory by
+// writeCaptions.
+func (fb *FilterComplexBuilder) WithCaption(input string, captions []TimedCaption) *FilterComplexBuilder {
+	var filters []string
+	if fb.style.Caption != "" {
+		enable := ""
+		if len(captions) > 0 {
+			shown := make([]string, len(captions))
+			for i, c := range captions {
+				shown[i] = c.enable()
+			}
+			enable = "not(" + strings.Join(shown, "+") + ")"
+		}
+		filters = append(filters, drawtext(fb.style, filepath.Join(input, styleCaptionFile), enable))
+	}
+	for _, c := range captions {
+		filters = append(filters, drawtext(fb.style, filepath.Join(input, fmt.Sprintf(captionFileFormat, c.Index)), c.enable()))
+	}
+	if len(filters) == 0 {
+		return fb
+	}
+
+	fb.filterComplex.WriteString(";")
+	fb.filterComplex.WriteString(
+		fmt.Sprintf(`
+			[%s]%s[captioned]
+			`,
+			fb.prevStageName,
+			strings.Join(filters, ","),
+		),
+	)
+	fb.prevStageName = "captioned"
+
+	return fb
+}
+
+// WithEvenDimensions pads the video of the given dimensions by a pixel on the
+// right or the bottom when its width or height is odd, which yuv420p and H.264
+// don't support. Every output is padded alike, so they all have the same
+// dimensions. The pad has the color of the margin, or the background of the
+// terminal.
+func (fb *FilterComplexBuilder) WithEvenDimensions(width, height int) *FilterComplexBuilder {
+	evenWidth, evenHeight := evenDimensions(width, height)
+	if evenWidth == width && evenHeight == height {
+		return fb
+	}
+	color := fb.style.BackgroundColor
+	if marginFillIsColor(fb.style.MarginFill) && fb.style.Margin > 0 {
+		color = fb.style.MarginFill
+	}
+
+	fb.filterComplex.WriteString(";")
+	fb.filterComplex.WriteString(
+		fmt.Sprintf(`
+			[%s]pad=%d:%d:0:0:%s[even]
+			`,
+			fb.prevStageName,
+			evenWidth,
+			evenHeight,
+			color,
+		),
+	)
+	fb.prevStageName = "even"
+
+	return fb
+}
+
+// WithDedup drops the frames identical to the frame before them, which keep
+// their timestamps so the frames kept are held until the next one.
+func (fb *FilterComplexBuilder) WithDedup() *FilterComplexBuilder {
+	fb.filterComplex.WriteString(";")
+	fb.filterComplex.WriteString(
+		fmt.Sprintf(`
+			[%s]mpdecimate=hi=0:lo=0:frac=0[deduped]
+			`,
+			fb.prevStageName,
+		),
+	)
+	fb.prevStageName = "deduped"
+
+	return fb
+}
+
 // WithGIF adds gif options to ffmepg filter_complex.
-func (fb *FilterComplexBuilder) WithGIF() *FilterComplexBuilder {
+// An empty dither uses the ffmpeg default dithering.
+func (fb *FilterComplexBuilder) WithGIF(maxColors int, dither string) *FilterComplexBuilder {
+	paletteuse := "paletteuse"
+	if dither != "" {
+		paletteuse += "=dither=" + dither
+	}
+
 	fb.filterComplex.WriteString(";")
 	fb.filterComplex.WriteString(
 		fmt.Sprintf(`
 			[%s]split[plt_a][plt_b];
-			[plt_a]palettegen=max_colors=256[plt];
-			[plt_b][plt]paletteuse[palette]`,
+			[plt_a]palettegen=max_colors=%d[plt];
+			[plt_b][plt]%s[palette]`,
 			fb.prevStageName,
+			maxColors,
+			paletteuse,
 		),
 	)
 	fb.prevStageName = "palette"
@@ -188,12 +339,48 @@ func (fb *FilterComplexBuilder) WithGIF() *FilterComplexBuilder {
 	return fb
 }
 
+// WithAudio mixes the audio streams into the audio track, if any. The track
+// is padded with silence, and the output ends with the video, so that audio
+// shorter or longer than the video doesn't change its length.
+func (fb *FilterComplexBuilder) WithAudio(audioStreams []int) *FilterComplexBuilder {
+	if len(audioStreams) == 0 {
+		return fb
+	}
+	var inputs string
+	for _, stream := range audioStreams {
+		inputs += fmt.Sprintf("[%d:a]", stream)
+	}
+	// amix lowers the volume of each input so that the mix doesn't clip,
+	// which is undone since the key sounds are quiet.
+	mix := ""
+	if n := len(audioStreams); n > 1 {
+		mix = fmt.Sprintf("amix=inputs=%d:duration=longest,volume=%d,", n, n)
+	}
+
+	fb.filterComplex.WriteString(";")
+	fb.filterComplex.WriteString(
+		fmt.Sprintf(`
+			%s%sapad[audio]
+			`,
+			inputs,
+			mix,
+		),
+	)
+	fb.audioStageName = "audio"
+
+	return fb
+}
+
 // Build returns filter_complex used in ffmepg.
 func (fb *FilterComplexBuilder) Build() []string {
-	return []string{
+	args := []string{
 		"-filter_complex", fb.filterComplex.String(),
 		"-map", "[" + fb.prevStageName + "]",
 	}
+	if fb.audioStageName != "" {
+		args = append(args, "-map", "["+fb.audioStageName+"]", "-shortest")
+	}
+	return args
 }
 
 // StreamBuilder generates streams used by ffmepg.
@@ -207,6 +394,8 @@ type StreamBuilder struct {
 	barStream    int
 	cornerStream int
 	marginStream int
+	// audioStreams are mixed into the audio track of the output.
+	audioStreams []int
 }
 
 // NewStreamBuilder returns instance of StreamBuilder.
@@ -299,25 +488,112 @@ func (sb *StreamBuilder) WithCorner() *StreamBuilder {
 	return sb
 }
 
+// WithAudio adds an audio stream, which is played over and over with loop.
+func (sb *StreamBuilder) WithAudio(path string, loop bool) *StreamBuilder {
+	if loop {
+		sb.args = append(sb.args, "-stream_loop", "-1")
+	}
+	sb.args = append(sb.args, "-i", path)
+	sb.audioStreams = append(sb.audioStreams, sb.counter)
+	sb.counter++
+	return sb
+}
+
+// audio returns the options encoding the audio track with the codec, or
+// disabling the audio if there's no audio stream.
+func (sb *StreamBuilder) audio(codec string) []string {
+	if len(sb.audioStreams) == 0 {
+		return []string{"-an"}
+	}
+	return []string{"-c:a", codec}
+}
+
 // WithMP4W adds mp4 stream with required config.
-func (sb *StreamBuilder) WithMP4() *StreamBuilder {
+// A zero crf uses the default quality and an empty bitrate leaves it
+// unconstrained.
+func (sb *StreamBuilder) WithMP4(crf int, bitrate, pixelFormat string) *StreamBuilder {
+	if crf == 0 {
+		crf = defaultMP4CRF
+	}
 	sb.args = append(sb.args,
 		"-vcodec", "libx264",
-		"-pix_fmt", "yuv420p",
-		"-an",
-		"-crf", "20",
+		"-pix_fmt", pixelFormat,
+	)
+	sb.args = append(sb.args, sb.audio("aac")...)
+	sb.args = append(sb.args,
+		"-crf", fmt.Sprint(crf),
 	)
+	if bitrate != "" {
+		sb.args = append(sb.args, "-maxrate", bitrate, "-bufsize", bitrate)
+	}
 
 	return sb
 }
 
 // WithWebmW adds webm stream with required config.
-func (sb *StreamBuilder) WithWebm() *StreamBuilder {
+// A zero crf uses the default quality and an empty bitrate leaves it
+// unconstrained.
+func (sb *StreamBuilder) WithWebm(crf int, bitrate, pixelFormat string) *StreamBuilder {
+	if crf == 0 {
+		crf = defaultWebMCRF
+	}
+	if bitrate == "" {
+		bitrate = "0"
+	}
+	sb.args = append(sb.args,
+		"-pix_fmt", pixelFormat,
+	)
+	sb.args = append(sb.args, sb.audio("libopus")...)
+	sb.args = append(sb.args,
+		"-crf", fmt.Sprint(crf),
+		"-b:v", bitrate,
+	)
+	return sb
+}
+
+// WithDedup keeps the timestamps of the deduplicated frames, and holds the
+// last frame for the final delay, in centiseconds, since it has no next frame.
+func (sb *StreamBuilder) WithDedup(finalDelay int) *StreamBuilder {
+	sb.args = append(sb.args,
+		"-vsync", "vfr",
+		"-final_delay", fmt.Sprint(finalDelay),
+	)
+	return sb
+}
+
+// WithGIF adds gif stream with the loop count.
+func (sb *StreamBuilder) WithGIF(loopCount int) *StreamBuilder {
+	sb.args = append(sb.args,
+		"-loop", fmt.Sprint(loopCount),
+	)
+	return sb
+}
+
+// WithAPNG adds apng stream with required config. APNGs count the times they
+// are played rather than looped, so the first play is added to the loop count.
+func (sb *StreamBuilder) WithAPNG(loopCount int) *StreamBuilder {
+	plays := 0
+	switch {
+	case loopCount < 0:
+		plays = 1
+	case loopCount > 0:
+		plays = loopCount + 1
+	}
+	sb.args = append(sb.args,
+		"-f", "apng",
+		"-plays", fmt.Sprint(plays),
+	)
+	return sb
+}
+
+// WithWebP adds webp stream with required config.
+// The alpha channel is kept so transparent corners are preserved.
+func (sb *StreamBuilder) WithWebP() *StreamBuilder {
 	sb.args = append(sb.args,
-		"-pix_fmt", "yuv420p",
+		"-vcodec", "libwebp",
+		"-pix_fmt", "yuva420p",
+		"-loop", "0",
 		"-an",
-		"-crf", "30",
-		"-b:v", "0",
 	)
 	return sb
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCalcDimensions(t *testing.T) {
+	tests := []struct {
+		name           string
+		style          func(*StyleOptions)
+		termWidth      int
+		termHeight     int
+		viewportWidth  int
+		viewportHeight int
+	}{
+		{
+			name:           "default",
+			style:          func(*StyleOptions) {},
+			termWidth:      1200,
+			termHeight:     600,
+			viewportWidth:  1080,
+			viewportHeight: 480,
+		},
+		{
+			name: "margin",
+			style: func(s *StyleOptions) {
+				s.Margin = 20
+				s.MarginFill = "#1a1a2e"
+			},
+			termWidth:      1160,
+			termHeight:     560,
+			viewportWidth:  1040,
+			viewportHeight: 440,
+		},
+		{
+			name: "margin without fill",
+			style: func(s *StyleOptions) {
+				s.Margin = 20
+				s.MarginFill = ""
+			},
+			termWidth:      1200,
+			termHeight:     600,
+			viewportWidth:  1080,
+			viewportHeight: 480,
+		},
+		{
+			name: "margin and window bar",
+			style: func(s *StyleOptions) {
+				s.Margin = 20
+				s.WindowBar = "Colorful"
+			},
+			termWidth:      1160,
+			termHeight:     530,
+			viewportWidth:  1040,
+			viewportHeight: 410,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			style := DefaultStyleOptions()
+			tc.style(style)
+
+			w, h := calcTermDimensions(*style)
+			if w != tc.termWidth || h != tc.termHeight {
+				t.Errorf("terminal: expected %dx%d, got %dx%d", tc.termWidth, tc.termHeight, w, h)
+			}
+
+			w, h = calcViewportDimensions(*style)
+			if w != tc.viewportWidth || h != tc.viewportHeight {
+				t.Errorf("viewport: expected %dx%d, got %dx%d", tc.viewportWidth, tc.viewportHeight, w, h)
+			}
+		})
+	}
+}
+
+func TestWithCrop(t *testing.T) {
+	tests := []struct {
+		name  string
+		style func(*StyleOptions)
+		want  string
+	}{
+		{
+			name:  "default",
+			style: func(*StyleOptions) {},
+			want:  "[withbg]crop=400:200:10:20[cropped]",
+		},
+		{
+			name: "margin and window bar",
+			style: func(s *StyleOptions) {
+				s.Margin = 20
+				s.MarginFill = "#1a1a2e"
+				s.WindowBar = "Colorful"
+				s.WindowBarSize = 30
+			},
+			want: "[withbg]crop=400:200:30:70[cropped]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := testVideoOptions(t)
+			tc.style(opts.Style)
+			fb := NewVideoFilterBuilder(&opts).
+				WithWindowBar(2).
+				WithMarginFill(3).
+				WithCrop(Crop{X: 10, Y: 20, Width: 400, Height: 200})
+			if got := fb.filterComplex.String(); !strings.Contains(got, tc.want) {
+				t.Errorf("expected the filter to contain %q, got: %s", tc.want, got)
+			}
+			if fb.prevStageName != "cropped" {
+				t.Errorf("expected the next stage to use the cropped video, got %s", fb.prevStageName)
+			}
+		})
+	}
+}
+
+func TestBuildFFoptsAudio(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.Audio = "narration.mp3"
+
+	args := strings.Join(buildFFopts(opts, "out.mp4"), " ")
+	for _, want := range []string{"-i narration.mp3", "[3:a]apad[audio]", "-map [audio] -shortest"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected the MP4 to have the audio %q, got: %s", want, args)
+		}
+	}
+	if strings.Contains(args, "-stream_loop") {
+		t.Errorf("expected the audio not to loop, got: %s", args)
+	}
+	if args := strings.Join(buildFFopts(opts, "out.gif"), " "); strings.Contains(args, "narration.mp3") {
+		t.Errorf("expected the GIF not to have audio, got: %s", args)
+	}
+
+	// The audio is looped, and mixed with the key sounds.
+	opts.AudioLoop = true
+	opts.KeySound = true
+	args = strings.Join(buildFFopts(opts, "out.webm"), " ")
+	for _, want := range []string{"-stream_loop -1 -i narration.mp3", "[3:a][4:a]amix=inputs=2:duration=longest,volume=2,apad[audio]"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected the WebM to have the audio %q, got: %s", want, args)
+		}
+	}
+}
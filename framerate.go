@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The concat demuxer files listing the frames with their durations, for
+// VariableFramerate. They are written next to the frames, which they refer to
+// by name.
+const (
+	textConcatFile   = "frame-text.ffconcat"
+	cursorConcatFile = "frame-cursor.ffconcat"
+)
+
+// frameDurations returns the durations of the frames captured at the given
+// times, i.e. the time until the next frame was captured. The last frame lasts
+// as long as a frame at the framerate.
+func frameDurations(times []time.Duration, framerate int) []time.Duration {
+	durations := make([]time.Duration, len(times))
+	for i := range times {
+		if i == len(times)-1 {
+			durations[i] = time.Second / time.Duration(framerate)
+			continue
+		}
+		durations[i] = times[i+1] - times[i]
+	}
+	return durations
+}
+
+// writeFrameDurations writes the concat demuxer files which hold each frame
+// of the output for as long as it was shown while recording, once the frames
+// are in their final order. The frames played backward by Boomerang last as
+// long as they did forward. Nothing is written without a variable framerate,
+// or without the times of the frames, and the frames are played at the
+// framerate then.
+func (vhs *VHS) writeFrameDurations(tl timeline) error {
+	if tl.durations == nil {
+		return nil
+	}
+
+	durations := append([]time.Duration{}, tl.durations...)
+	if vhs.totalFrames > len(tl.durations) {
+		for i := len(tl.durations) - 2; i > 0; i-- {
+			durations = append(durations, tl.durations[i])
+		}
+	}
+
+	files := map[string]string{textConcatFile: vhs.Options.Video.textFrames()}
+	if vhs.Options.Video.cursorFrames() {
+		files[cursorConcatFile] = cursorFrameFormat
+	}
+	for file, format := range files {
+		list := concatList(format, vhs.Options.Video.StartingFrame, durations)
+		if err := os.WriteFile(filepath.Join(vhs.Options.Video.Input, file), []byte(list), os.ModePerm); err != nil {
+			return fmt.Errorf("could not write the frame durations: %w", err)
+		}
+	}
+	vhs.Options.Video.frameDurations = durations
+	return nil
+}
+
+// concatList returns the concat demuxer file listing the frames, numbered from
+// the starting frame, with their durations. The last frame is listed twice,
+// since ffmpeg ignores the duration of the last file.
+func concatList(format string, start int, durations []time.Duration) string {
+	var sb strings.Builder
+	sb.WriteString("ffconcat version 1.0\n")
+	for i, d := range durations {
+		fmt.Fprintf(&sb, "file '%s'\nduration %f\n", fmt.Sprintf(format, start+i), d.Seconds())
+	}
+	fmt.Fprintf(&sb, "file '%s'\n", fmt.Sprintf(format, start+len(durations)-1))
+	return sb.String()
+}
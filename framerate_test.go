@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFrameDurations(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	requireNoErr(t, os.MkdirAll(v.Options.Video.Input, os.ModePerm))
+	v.Options.Video.HideCursor = true
+	v.Options.Video.VariableFramerate = true
+	v.Options.Video.CaptureFramerate = 10
+	v.Options.Video.TrimStart = Trim{Frames: 1}
+	v.Options.Video.Boomerang = true
+	v.Options.LoopOffset = 20
+
+	// The capture of the 4th frame stalled for 300ms.
+	ms := time.Millisecond
+	v.frameTimes = []time.Duration{0, 100 * ms, 200 * ms, 300 * ms, 600 * ms, 700 * ms}
+	v.totalFrames = len(v.frameTimes)
+
+	// Frames 2 to 6 are kept, and frame 2 is moved to the end.
+	tl := v.timeline()
+	want := []time.Duration{100 * ms, 300 * ms, 100 * ms, 100 * ms, 100 * ms}
+	if !reflect.DeepEqual(tl.durations, want) {
+		t.Fatalf("expected durations %v, got %v", want, tl.durations)
+	}
+	if s := tl.seconds(2); s != 0.4 {
+		t.Errorf("expected the 3rd frame to start at 0.4s, got %v", s)
+	}
+
+	// The frames in the middle are played backward with the same durations.
+	v.Options.Video.StartingFrame = 3
+	v.totalFrames = 8
+	requireNoErr(t, v.writeFrameDurations(tl))
+	want = append(want, 100*ms, 100*ms, 300*ms)
+	if !reflect.DeepEqual(v.Options.Video.frameDurations, want) {
+		t.Errorf("expected durations %v, got %v", want, v.Options.Video.frameDurations)
+	}
+
+	bts, err := os.ReadFile(filepath.Join(v.Options.Video.Input, textConcatFile))
+	requireNoErr(t, err)
+	list := string(bts)
+	for _, line := range []string{"file 'frame-text-00000003.png'\nduration 0.100000\n", "file 'frame-text-00000004.png'\nduration 0.300000\n"} {
+		if !strings.Contains(list, line) {
+			t.Errorf("expected the frames to be listed with their durations, got:\n%s", list)
+		}
+	}
+	if !strings.HasSuffix(list, "duration 0.300000\nfile 'frame-text-00000010.png'\n") {
+		t.Errorf("expected the last frame to be listed again, got:\n%s", list)
+	}
+
+	args := strings.Join(buildFFopts(v.Options.Video, "out.gif"), " ")
+	if !strings.Contains(args, "-f concat -i "+filepath.Join(v.Options.Video.Input, textConcatFile)) || strings.Contains(args, "-start_number") {
+		t.Errorf("expected the frames to be read from the concat file, got: %s", args)
+	}
+}
+
+func TestTimelineConstantFramerate(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.Video.CaptureFramerate = 10
+	v.frameTimes = []time.Duration{0, 500 * time.Millisecond}
+	v.totalFrames = len(v.frameTimes)
+
+	// The frame times are ignored without a variable framerate.
+	tl := v.timeline()
+	if tl.durations != nil || tl.seconds(1) != 0.1 {
+		t.Errorf("expected frames of 0.1s, got %v", tl.durations)
+	}
+	requireNoErr(t, v.writeFrameDurations(tl))
+	if v.Options.Video.variableFramerate() {
+		t.Error("expected the frames to be read at the framerate")
+	}
+}
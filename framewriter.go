@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// frameWriters is the number of frames written to disk at once while
+// recording.
+const frameWriters = 4
+
+// frameFile is a file of a frame, i.e. its text or cursor frame.
+type frameFile struct {
+	// format is the pattern of the names of the file for each frame, e.g.
+	// textFrameFormat.
+	format string
+	data   []byte
+}
+
+// capturedFrame holds the files of a frame once it's captured, before they are
+// written to disk.
+type capturedFrame struct {
+	number int
+	files  []frameFile
+}
+
+// path returns the path of the file of the given frame in the directory.
+func (f frameFile) path(dir string, frame int) string {
+	return filepath.Join(dir, fmt.Sprintf(f.format, frame))
+}
+
+// write writes the files of the frame to the directory. It either writes the
+// frame whole, or removes the files it wrote and returns an error.
+func (f capturedFrame) write(dir string) error {
+	var written []string
+	for _, file := range f.files {
+		path := file.path(dir, f.number)
+		written = append(written, path)
+		if err := os.WriteFile(path, file.data, os.ModePerm); err != nil {
+			for _, path := range written {
+				_ = os.Remove(path)
+			}
+			return fmt.Errorf("error writing frame %d: %w", f.number, err)
+		}
+	}
+	return nil
+}
+
+// frameWriter writes the frames captured by Record to disk in the background,
+// so that slow writes don't hold the capture back and the frames are captured
+// on time. Once the buffer of frames waiting to be written is full, capturing
+// a frame blocks until there's room, so the memory used is bounded.
+type frameWriter struct {
+	dir    string
+	frames chan capturedFrame
+	errs   chan<- error
+	wg     sync.WaitGroup
+
+	mutex sync.Mutex
+	// failed are the frames which couldn't be written.
+	failed []capturedFrame
+}
+
+// newFrameWriter starts writing frames to the directory, sending the errors
+// to the channel. Up to buffer frames can wait to be written.
+func newFrameWriter(dir string, buffer int, errs chan<- error) *frameWriter {
+	w := &frameWriter{
+		dir:    dir,
+		frames: make(chan capturedFrame, buffer),
+		errs:   errs,
+	}
+	w.wg.Add(frameWriters)
+	for i := 0; i < frameWriters; i++ {
+		go func() {
+			defer w.wg.Done()
+			for f := range w.frames {
+				if err := f.write(w.dir); err != nil {
+					w.mutex.Lock()
+					w.failed = append(w.failed, f)
+					w.mutex.Unlock()
+					w.errs <- err
+				}
+			}
+		}()
+	}
+	return w
+}
+
+// write queues the frame to be written.
+func (w *frameWriter) write(f capturedFrame) {
+	w.frames <- f
+}
+
+// close waits for the queued frames to be written. The frames which couldn't
+// be written are replaced by the frame before them, or after them for the
+// first frame, so that the frames on disk stay numbered consecutively and
+// the screen is held for as long as it was recorded.
+func (w *frameWriter) close() {
+	close(w.frames)
+	w.wg.Wait()
+
+	sort.Slice(w.failed, func(i, j int) bool { return w.failed[i].number < w.failed[j].number })
+	missing := make(map[int]bool, len(w.failed))
+	for _, f := range w.failed {
+		missing[f.number] = true
+	}
+	for _, f := range w.failed {
+		from := f.number - 1
+		if from < 1 {
+			from = f.number + 1
+			for missing[from] {
+				from++
+			}
+		}
+		for _, file := range f.files {
+			if err := linkFrame(file.path(w.dir, from), file.path(w.dir, f.number)); err != nil {
+				w.errs <- fmt.Errorf("error replacing frame %d: %w", f.number, err)
+			}
+		}
+		delete(missing, f.number)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestFrameWriterReplacesFailedFrames(t *testing.T) {
+	dir := t.TempDir()
+	errs := make(chan error)
+	w := newFrameWriter(dir, 3, errs)
+
+	frame := func(n int, data string) capturedFrame {
+		return capturedFrame{number: n, files: []frameFile{
+			{textFrameFormat, []byte("text " + data)},
+			{cursorFrameFormat, []byte("cursor " + data)},
+		}}
+	}
+
+	// The cursor frame can't be written over a directory.
+	blocked := frameFile{format: cursorFrameFormat}.path(dir, 2)
+	if err := os.Mkdir(blocked, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	w.write(frame(1, "1"))
+	w.write(frame(2, "2"))
+	w.write(frame(3, "3"))
+	if err := <-errs; err == nil {
+		t.Fatal("expected an error writing frame 2")
+	}
+
+	// The failed frame is replaced by the frame before it.
+	_ = os.Remove(blocked)
+	w.close()
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{{1, "1"}, {2, "1"}, {3, "3"}} {
+		for _, file := range frame(tc.n, tc.want).files {
+			got, err := os.ReadFile(file.path(dir, tc.n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, file.data) {
+				t.Errorf("expected frame %d to be %q, got %q", tc.n, file.data, got)
+			}
+		}
+	}
+}
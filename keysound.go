@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// keySoundFile is the audio track of the key sounds, written to the input
+// directory when rendering.
+const keySoundFile = "keys.wav"
+
+const (
+	audioSampleRate = 44100
+	// clickDuration and clickVolume are the length, in milliseconds, and the
+	// volume, from 0 to 1, of the sound of a key press.
+	clickDuration = 15
+	clickVolume   = 0.3
+)
+
+// keystroke records a key press on the next frame captured, so that it can be
+// heard in the output with KeySound. Key presses aren't recorded while the
+// recording is hidden.
+func (vhs *VHS) keystroke() {
+	if !vhs.Options.Video.KeySound {
+		return
+	}
+	vhs.mutex.Lock()
+	defer vhs.mutex.Unlock()
+	if vhs.recording {
+		vhs.keystrokes = append(vhs.keystrokes, vhs.totalFrames+1)
+	}
+}
+
+// keySoundTimes returns the times of the key presses in the output, in
+// seconds. With Boomerang, the keys are only heard while the frames play
+// forward.
+func (vhs *VHS) keySoundTimes(tl timeline) []float64 {
+	var times []float64
+	for _, frame := range vhs.keystrokes {
+		if pos, ok := tl.position(frame); ok {
+			times = append(times, tl.seconds(pos))
+		}
+	}
+	return times
+}
+
+// click returns the samples of the sound of a key press, a short burst of
+// noise which fades out. The noise is seeded, so every click sounds the same.
+func click() []float64 {
+	samples := make([]float64, audioSampleRate*clickDuration/1000) //nolint:gomnd
+	noise := rand.New(rand.NewSource(1))                           //nolint:gosec
+	for i := range samples {
+		fade := math.Exp(-6 * float64(i) / float64(len(samples))) //nolint:gomnd
+		samples[i] = (noise.Float64()*2 - 1) * fade * clickVolume
+	}
+	return samples
+}
+
+// keySoundWAV returns a mono 16-bit WAV of the given duration, in seconds,
+// with a click at each of the times.
+func keySoundWAV(times []float64, duration float64) []byte {
+	track := make([]float64, int(math.Ceil(duration*audioSampleRate)))
+	sound := click()
+	for _, t := range times {
+		start := int(t * audioSampleRate)
+		for i, sample := range sound {
+			if start+i >= 0 && start+i < len(track) {
+				track[start+i] += sample
+			}
+		}
+	}
+
+	pcm := make([]int16, len(track))
+	for i, sample := range track {
+		pcm[i] = int16(math.Max(-1, math.Min(1, sample)) * math.MaxInt16)
+	}
+
+	const bitsPerSample = 16
+	dataSize := uint32(len(pcm) * bitsPerSample / 8) //nolint:gomnd
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, 36+dataSize) //nolint:gomnd
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, struct {
+		Size          uint32
+		Format        uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}{16, 1, 1, audioSampleRate, audioSampleRate * bitsPerSample / 8, bitsPerSample / 8, bitsPerSample})
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, dataSize)
+	_ = binary.Write(&buf, binary.LittleEndian, pcm)
+	return buf.Bytes()
+}
+
+// writeKeySound writes the audio track of the key sounds to the path.
+func writeKeySound(path string, times []float64, duration float64) error {
+	if err := os.WriteFile(path, keySoundWAV(times, duration), os.ModePerm); err != nil {
+		return fmt.Errorf("could not write key sounds: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeySoundTimes(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.Video.Framerate = 10
+	v.Options.Video.KeySound = true
+
+	v.PauseRecording()
+	v.keystroke()
+	if len(v.keystrokes) != 0 {
+		t.Fatalf("expected keys not to be recorded while hidden, got %v", v.keystrokes)
+	}
+
+	v.ResumeRecording()
+	for _, frames := range []int{0, 4, 9, 19} {
+		v.totalFrames = frames
+		v.keystroke()
+	}
+	v.totalFrames = 20
+
+	// The first key is trimmed, and the loop offset moves the second one to
+	// the end.
+	v.Options.Video.TrimStart = Trim{Frames: 2}
+	v.Options.LoopOffset = 25
+	want := []float64{1.5, 0.2, 1.2}
+	if got := v.keySoundTimes(v.timeline()); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the keys at %v, got %v", want, got)
+	}
+}
+
+func TestKeySoundWAV(t *testing.T) {
+	wav := keySoundWAV([]float64{0.5}, 1)
+	if string(wav[:4]) != "RIFF" || string(wav[8:16]) != "WAVEfmt " || string(wav[36:40]) != "data" {
+		t.Fatalf("expected a WAV header, got %q", wav[:44])
+	}
+	if size := binary.LittleEndian.Uint32(wav[40:44]); size != audioSampleRate*2 {
+		t.Errorf("expected a second of 16-bit samples, got %d bytes", size)
+	}
+
+	// The track is silent until the click.
+	sample := func(i int) int16 {
+		return int16(binary.LittleEndian.Uint16(wav[44+2*i:]))
+	}
+	for i := 0; i < audioSampleRate/2; i++ {
+		if sample(i) != 0 {
+			t.Fatalf("expected silence before the click, got %d at sample %d", sample(i), i)
+		}
+	}
+	var loud bool
+	for i := audioSampleRate / 2; i < audioSampleRate/2+100; i++ {
+		loud = loud || sample(i) != 0
+	}
+	if !loud {
+		t.Error("expected a click at 0.5s")
+	}
+}
+
+func TestBuildFFoptsKeySound(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.KeySound = true
+
+	args := strings.Join(buildFFopts(opts, "out.mp4"), " ")
+	for _, want := range []string{"-i " + opts.Input + "/keys.wav", "-c:a aac", "[3:a]apad[audio]", "-map [withbg] -map [audio] -shortest"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected the MP4 to have key sounds %q, got: %s", want, args)
+		}
+	}
+	if args := strings.Join(buildFFopts(opts, "out.webm"), " "); !strings.Contains(args, "-c:a libopus") {
+		t.Errorf("expected the WebM to have key sounds, got: %s", args)
+	}
+	if args := strings.Join(buildFFopts(opts, "out.gif"), " "); strings.Contains(args, "keys.wav") {
+		t.Errorf("expected the GIF not to have key sounds, got: %s", args)
+	}
+
+	opts.KeySound = false
+	if args := strings.Join(buildFFopts(opts, "out.mp4"), " "); !strings.Contains(args, "-an") {
+		t.Errorf("expected the MP4 to have no sound, got: %s", args)
+	}
+}
@@ -10,6 +10,10 @@ type Lexer struct {
 	nextPos int
 	line    int
 	column  int
+
+	// regexAllowed is whether a slash starts a regular expression rather
+	// than a path, i.e. after Wait[@<time>] or Set WaitPattern.
+	regexAllowed bool
 }
 
 // New returns a new lexer for tokenizing the input string.
@@ -68,10 +72,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = l.readString('"')
 		l.readChar()
 	default:
-		if isDigit(l.ch) || (isDot(l.ch) && isDigit(l.peekChar())) {
+		if isSlash(l.ch) && l.regexAllowed {
+			tok.Type = token.REGEX
+			tok.Literal = l.readString('/')
+			l.readChar()
+		} else if isDigit(l.ch) || (isDot(l.ch) && isDigit(l.peekChar())) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.NUMBER
-		} else if isLetter(l.ch) || isDot(l.ch) {
+		} else if isLetter(l.ch) || isDot(l.ch) || isSlash(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdentifier(tok.Literal)
 		} else {
@@ -79,6 +87,16 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 		}
 	}
+
+	switch tok.Type {
+	case token.WAIT, token.WAIT_PATTERN:
+		l.regexAllowed = true
+	case token.AT, token.NUMBER, token.MILLISECONDS, token.SECONDS, token.MINUTES:
+		// Keep allowing a regular expression after Wait@<time>.
+	default:
+		l.regexAllowed = false
+	}
+
 	return tok
 }
 
@@ -93,8 +111,8 @@ func (l *Lexer) newToken(tokenType token.Type, ch byte) token.Token {
 	}
 }
 
-// readComment reads a comment.
-// // Foo => Token(Foo).
+// readComment reads a comment until the end of the line.
+// # Foo => Token( Foo).
 func (l *Lexer) readComment() string {
 	pos := l.pos + 1
 	for {
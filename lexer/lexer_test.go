@@ -24,7 +24,10 @@ Ctrl+C
 Enter
 Sleep .1
 Sleep 100ms
-Sleep 2`
+Sleep 2
+Wait /World/
+Wait@10s /World/
+Source /tmp/setup.tape`
 
 	tests := []struct {
 		expectedType    token.Type
@@ -71,6 +74,86 @@ Sleep 2`
 		{token.MILLISECONDS, "ms"},
 		{token.SLEEP, "Sleep"},
 		{token.NUMBER, "2"},
+		{token.WAIT, "Wait"},
+		{token.REGEX, "World"},
+		{token.WAIT, "Wait"},
+		{token.AT, "@"},
+		{token.NUMBER, "10"},
+		{token.SECONDS, "s"},
+		{token.REGEX, "World"},
+		{token.SOURCE, "Source"},
+		{token.STRING, "/tmp/setup.tape"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenHomeEnd(t *testing.T) {
+	input := `Home
+End@200ms 2`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.HOME, "Home"},
+		{token.END, "End"},
+		{token.AT, "@"},
+		{token.NUMBER, "200"},
+		{token.MILLISECONDS, "ms"},
+		{token.NUMBER, "2"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenComments(t *testing.T) {
+	input := `# Leading comment
+
+Type "echo '# not a comment'" # greets the user
+Set FontSize 14 # bigger
+
+Wait /#$/ # wait for a root prompt`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.COMMENT, " Leading comment"},
+		{token.TYPE, "Type"},
+		{token.STRING, "echo '# not a comment'"},
+		{token.COMMENT, " greets the user"},
+		{token.SET, "Set"},
+		{token.FONT_SIZE, "FontSize"},
+		{token.NUMBER, "14"},
+		{token.COMMENT, " bigger"},
+		{token.WAIT, "Wait"},
+		{token.REGEX, "#$"},
+		{token.COMMENT, " wait for a root prompt"},
 	}
 
 	l := New(input)
@@ -313,3 +396,18 @@ func TestLexTapeFile(t *testing.T) {
 		}
 	}
 }
+
+func TestSlashOutsideWait(t *testing.T) {
+	input := "Type 1/2\nOutput dir/demo.gif\nWait /World/"
+
+	var regexes []string
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.REGEX {
+			regexes = append(regexes, tok.Literal)
+		}
+	}
+	if len(regexes) != 1 || regexes[0] != "World" {
+		t.Errorf("expected only the slashes after Wait to start a regular expression, got %q", regexes)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/charmbracelet/vhs/parser"
+)
+
+// The log formats, set with `Set Logging`.
+const (
+	logText = "text"
+	logJSON = "json"
+)
+
+// logEntry is a line of the JSON logs.
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"msg,omitempty"`
+	Command string    `json:"command,omitempty"`
+	Options string    `json:"options,omitempty"`
+	Args    string    `json:"args,omitempty"`
+	// Duration is how long the command took, in milliseconds.
+	Duration float64 `json:"duration_ms,omitempty"`
+	// FirstFrame and LastFrame are the frames captured while the command
+	// was executed, if any.
+	FirstFrame int `json:"first_frame,omitempty"`
+	LastFrame  int `json:"last_frame,omitempty"`
+}
+
+// jsonLogs reports whether the logs are written as JSON lines.
+func (vhs *VHS) jsonLogs() bool {
+	return vhs.Options.Logging == logJSON
+}
+
+// logMessage logs the message.
+func (vhs *VHS) logMessage(msg string) {
+	if !vhs.jsonLogs() {
+		log.Println(msg)
+		return
+	}
+	writeLogEntry(logEntry{Time: time.Now(), Message: msg})
+}
+
+// logStatus logs a status message, which is faint in text logs.
+func (vhs *VHS) logStatus(msg string) {
+	if !vhs.jsonLogs() {
+		log.Println(GrayStyle.Render(msg))
+		return
+	}
+	writeLogEntry(logEntry{Time: time.Now(), Message: msg})
+}
+
+// logCommand logs the command which started at the given time, and the frames
+// captured since the given frame, when logging as JSON. Text logs don't
+// include commands, since they are printed to the output.
+func (vhs *VHS) logCommand(c parser.Command, start time.Time, firstFrame int) {
+	if !vhs.jsonLogs() {
+		return
+	}
+	entry := logEntry{
+		Time:     start,
+		Command:  c.Type.String(),
+		Options:  c.Options,
+		Args:     c.Args,
+		Duration: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if lastFrame := vhs.frames(); lastFrame > firstFrame {
+		entry.FirstFrame = firstFrame + 1
+		entry.LastFrame = lastFrame
+	}
+	writeLogEntry(entry)
+}
+
+// writeLogEntry writes the entry as a JSON line to the log output.
+func writeLogEntry(entry logEntry) {
+	bts, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_, _ = log.Writer().Write(append(bts, '\n'))
+}
+
+// frames returns the number of frames captured so far.
+func (vhs *VHS) frames() int {
+	vhs.mutex.Lock()
+	defer vhs.mutex.Unlock()
+	return vhs.totalFrames
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/vhs/parser"
+	"github.com/charmbracelet/vhs/token"
+)
+
+func TestLogCommand(t *testing.T) {
+	var buf bytes.Buffer
+	w := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(w) })
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	cmd := parser.Command{Type: token.SET, Options: "TypingSpeed", Args: "100ms"}
+	Execute(cmd, &v)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no command logs in text, got %q", buf.String())
+	}
+
+	ExecuteSetLogging(parser.Command{Args: "json"}, &v)
+	Execute(cmd, &v)
+	v.logStatus("Creating out.gif...")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %q", buf.String())
+	}
+	var entry logEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Command != "Set" || entry.Options != "TypingSpeed" || entry.Args != "100ms" {
+		t.Errorf("expected the Set command to be logged, got %+v", entry)
+	}
+	if entry.FirstFrame != 0 || entry.LastFrame != 0 {
+		t.Errorf("expected no frames to be logged, got %+v", entry)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "Creating out.gif..." {
+		t.Errorf("expected the status to be logged, got %+v", entry)
+	}
+
+	ExecuteSetLogging(parser.Command{Args: "yaml"}, &v)
+	if len(v.Errors) != 1 {
+		t.Errorf("expected an error for an invalid log format, got %v", v.Errors)
+	}
+}
@@ -17,8 +17,6 @@ import (
 	"strings"
 	"syscall"
 
-	"github.com/charmbracelet/vhs/lexer"
-	"github.com/charmbracelet/vhs/parser"
 	version "github.com/hashicorp/go-version"
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
@@ -61,6 +59,7 @@ var (
 			}
 
 			in := cmd.InOrStdin()
+			var path string
 			// Set the input to the file contents if a file is given
 			// otherwise, use stdin
 			if len(args) > 0 && args[0] != "-" {
@@ -68,6 +67,7 @@ var (
 				if err != nil {
 					return err
 				}
+				path = args[0]
 				log.Println(GrayStyle.Render("File: " + args[0]))
 			} else {
 				stat, _ := os.Stdin.Stat()
@@ -95,8 +95,10 @@ var (
 			out := cmd.OutOrStdout()
 			if quietFlag {
 				out = io.Discard
+			} else if isatty.IsTerminal(os.Stdout.Fd()) {
+				out = newProgressWriter(out)
 			}
-			errs := Evaluate(cmd.Context(), string(input), out, func(v *VHS) {
+			errs := Evaluate(cmd.Context(), string(input), path, out, func(v *VHS) {
 				// Output is being overridden, prevent all outputs
 				if len(*outputs) <= 0 {
 					publishFile = v.Options.Video.Output.GIF
@@ -104,12 +106,19 @@ var (
 				}
 
 				for _, output := range *outputs {
+					output = variantPath(output, v.themeVariant)
 					if strings.HasSuffix(output, gif) {
 						v.Options.Video.Output.GIF = output
 					} else if strings.HasSuffix(output, webm) {
 						v.Options.Video.Output.WebM = output
 					} else if strings.HasSuffix(output, mp4) {
 						v.Options.Video.Output.MP4 = output
+					} else if strings.HasSuffix(output, apng) {
+						v.Options.Video.Output.APNG = output
+					} else if strings.HasSuffix(output, webp) {
+						v.Options.Video.Output.WebP = output
+					} else if strings.HasSuffix(output, cast) {
+						v.Options.Video.Output.Cast = output
 					}
 				}
 
@@ -118,6 +127,9 @@ var (
 
 			if len(errs) > 0 {
 				printErrors(os.Stderr, string(input), errs)
+				if errors.As(errs[0], &InterruptedError{}) {
+					return errors.New("recording interrupted")
+				}
 				return errors.New("recording failed")
 			}
 
@@ -159,9 +171,9 @@ var (
 				log.Printf("# Themes\n\n")
 				prefix, suffix = "* `", "`"
 			}
-			themes, err := sortedThemeNames()
-			if err != nil {
-				return err
+			themes := Themes()
+			if len(themes) == 0 {
+				return errors.New("could not load themes")
 			}
 			for _, theme := range themes {
 				log.Printf("%s%s%s\n", prefix, theme, suffix)
@@ -215,18 +227,10 @@ var (
 					continue
 				}
 
-				l := lexer.New(string(b))
-				p := parser.New(l)
-
-				_ = p.Parse()
-				errs := p.Errors()
-
+				errs := Validate(string(b), file)
 				if len(errs) != 0 {
 					log.Println(ErrorFileStyle.Render(file))
-
-					for _, err := range errs {
-						printError(os.Stderr, string(b), err)
-					}
+					printErrors(os.Stderr, string(b), errs)
 					valid = false
 				}
 			}
@@ -247,6 +251,14 @@ func main() {
 	)
 	defer cancel()
 
+	// Stop catching the signals once the first one cancels the context, so
+	// that what was recorded is rendered, while a second Ctrl+C quits right
+	// away.
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -304,12 +316,9 @@ func getVersion(program string) *version.Version {
 }
 
 // ensureDependencies ensures that all dependencies are correctly installed
-// and versioned before continuing
+// and versioned before continuing. ffmpeg is checked once the tape is parsed,
+// since the tape may set its path.
 func ensureDependencies() error {
-	_, ffmpegErr := exec.LookPath("ffmpeg")
-	if ffmpegErr != nil {
-		return fmt.Errorf("ffmpeg is not installed. Install it from: http://ffmpeg.org")
-	}
 	_, ttydErr := exec.LookPath("ttyd")
 	if ttydErr != nil {
 		return fmt.Errorf("ttyd is not installed. Install it from: https://github.com/tsl0922/ttyd")
@@ -24,12 +24,14 @@ A tape file is a script made up of commands describing what actions to perform i
 
 The following is a list of all possible commands in VHS:
 
-* %Output% <path>.(gif|webm|mp4)
+* %Output% <path>.(gif|webm|mp4|apng|webp|cast)
 * %Require% <program>
 * %Set% <setting> <value>
+* %Env% <key> <value>
 * %Sleep% <time>
+* %Wait%[@<time>] [/<regex>/]
 * %Type% "<string>"
-* %Ctrl% [+Alt][+Shift]+<char>
+* %Ctrl% [+Alt][+Shift]+<char> [repeat]
 * %Backspace% [repeat]
 * %Delete% [repeat]
 * %Insert% [repeat]
@@ -41,19 +43,28 @@ The following is a list of all possible commands in VHS:
 * %Up% [repeat]
 * %PageUp% [repeat]
 * %PageDown% [repeat]
+* %Home% [repeat]
+* %End% [repeat]
 * %Hide%
 * %Show%
 * %Escape%
-* %Alt%+<key>
+* %Alt%+<key> [repeat]
+* %Shift%+<key> [repeat]
 * %Space% [repeat]
 * %Source% <path>.tape
 * %Screenshot% <path>.png
 * %Copy% "<string>"
 * %Paste%
+* %Select% [<row> <col> <row> <col>]
+* %Caption% "<text>" [<time>]
+* %Echo% <on|off>
+* %TypeFile%[@<time>] "<path>"
+* %Exec%[@<timeout>] "<command>"
 `
 
 	manOutput = `The Output command instructs VHS where to save the output of the recording.
-File names with the extension %.gif%, %.webm%, %.mp4% will have the respective file types.
+File names with the extension %.gif%, %.webm%, %.mp4%, %.apng%, %.webp% will have the respective file types.
+File names with the extension %.cast% will contain an asciinema recording of the terminal session.
 `
 
 	manSettings = `The Set command allows VHS to adjust settings in the terminal, such as fonts, dimensions, and themes.
@@ -61,17 +72,86 @@ File names with the extension %.gif%, %.webm%, %.mp4% will have the respective f
 The following is a list of all possible setting commands in VHS:
 
 * Set %Shell% <string>
+* Set %Prompt% "<prompt>"
 * Set %FontSize% <number>
 * Set %FontFamily% <string>
+* Set %FontFile% <path|url>
+* Set %FontWeight% <normal|bold|100-900>
+* Set %FontWeightBold% <normal|bold|100-900>
+* Set %MinimumContrastRatio% <float>
 * Set %Height% <number>
 * Set %Width% <number>
 * Set %LetterSpacing% <float>
 * Set %LineHeight% <float>
 * Set %TypingSpeed% <time>
+* Set %TypingVariance% <float>
+* Set %TypingSeed% <number>
 * Set %Theme% <json|string>
+* Set %Theme.Light% <json|string>
+* Set %Theme.Dark% <json|string>
 * Set %Padding% <number>
 * Set %Framerate% <number>
+* Set %CaptureFramerate% <number>
 * Set %PlaybackSpeed% <float>
+* Set %CursorBlink% <boolean>
+* Set %CursorBlinkRate% <time>
+* Set %CursorStyle% <block|bar|underline>
+* Set %CursorColor% "<color>"
+* Set %CursorAccentColor% "<color>"
+* Set %SelectionColor% "<color>"
+* Set %HideCursor% <boolean>
+* Set %WaitTimeout% <time>
+* Set %WaitPattern% /<regex>/
+* Set %MaxDuration% <time>
+* Set %Logging% <text|json>
+* Set %TabSettle% <time>
+* Set %TermRows% <number>
+* Set %TermCols% <number>
+* Set %Scale% <float>
+* Set %TrimStart% <frames|time>
+* Set %TrimEnd% <frames|time>
+* Set %Boomerang% <boolean>
+* Set %Caption% "<text>"
+* Set %CaptionPosition% <top|bottom>
+* Set %CaptionColor% "<color>"
+* Set %CaptionFont% "<font|path>"
+* Set %Port% <number>
+* Set %Debug% <boolean>
+* Set %Headless% <boolean>
+* Set %BrowserFlags% "<flags>"
+* Set %ControlURL% "<url>"
+* Set %WorkingDir% <path>
+* Set %Command% "<command>"
+* Set %Tmux% "<commands>"
+* Set %Poster% <path>.png|.jpg
+* Set %PosterFrame% <percentage>
+* Set %ParallelRender% <boolean>
+* Set %TextDump% <path>
+* Set %ExpectedOutput% <path>
+* Set %Crop% <x> <y> <width> <height>
+* Set %KeySound% <boolean>
+* Set %Audio% <path>
+* Set %AudioLoop% <boolean>
+* Set %SkipBlankFrames% <boolean>
+* Set %Dedup% <boolean>
+* Set %VariableFramerate% <boolean>
+* Set %StreamFrames% <boolean>
+* Set %ExecClear% <boolean>
+* Set %LoopCount% <number>
+* Set %BracketedPaste% <boolean>
+* Set %CleanupWait% <time>
+* Set %Banner% "<text>"
+* Set %BannerFile% <path>
+* Set %BannerDuration% <time>
+* Set %GIFColors% <number>
+* Set %GIFDither% <algorithm>
+* Set %CRF% <number>
+* Set %Bitrate% <bitrate>
+* Set %PixelFormat% <format>
+* Set %CaptureQuality% <number>
+* Set %FrameFormat% png|jpeg
+* Set %FFmpegPath% <path>
+* Set %FFmpegArgs% "<args>"
 `
 	manBugs = "See GitHub Issues: <https://github.com/charmbracelet/vhs/issues>"
 
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/vhs/lexer"
 	"github.com/charmbracelet/vhs/token"
@@ -19,6 +21,38 @@ func NewError(token token.Token, msg string) Error {
 	}
 }
 
+// maxPort is the highest valid TCP port.
+const maxPort = 65535
+
+// The number of colors a GIF palette can hold.
+const (
+	minGIFColors = 2
+	maxGIFColors = 256
+)
+
+// The range of framerates accepted for capturing and rendering frames.
+const (
+	MinFramerate = 1
+	MaxFramerate = 120
+)
+
+// MaxScale is the highest device scale factor accepted.
+const MaxScale = 4
+
+// The range of contrast ratios accepted by xterm.js.
+const (
+	minContrastRatio = 1
+	maxContrastRatio = 21
+)
+
+// maxCRF is the highest constant rate factor accepted by the video encoders.
+const maxCRF = 63
+
+var (
+	bitrateRegex     = regexp.MustCompile(`^[0-9]+[kKM]?$`)
+	bitrateUnitRegex = regexp.MustCompile(`^[kKM]$`)
+)
+
 // CommandType is a type that represents a command.
 type CommandType token.Type
 
@@ -31,7 +65,9 @@ var CommandTypes = []CommandType{ //nolint: deadcode
 	token.ALT,
 	token.DOWN,
 	token.ENTER,
+	token.END,
 	token.ESCAPE,
+	token.HOME,
 	token.ILLEGAL,
 	token.LEFT,
 	token.PAGEUP,
@@ -51,6 +87,13 @@ var CommandTypes = []CommandType{ //nolint: deadcode
 	token.SCREENSHOT,
 	token.COPY,
 	token.PASTE,
+	token.WAIT,
+	token.ENV,
+	token.SELECT,
+	token.CAPTION,
+	token.ECHO,
+	token.TYPE_FILE,
+	token.EXEC,
 }
 
 // String returns the string representation of the command.
@@ -102,6 +145,12 @@ type Parser struct {
 	errors []Error
 	cur    token.Token
 	peek   token.Token
+
+	// dir is the directory relative Source paths are resolved against.
+	dir string
+	// sources are the absolute paths of the tapes being parsed, outermost
+	// first, to detect recursive Source commands.
+	sources []string
 }
 
 // New returns a new Parser.
@@ -115,6 +164,17 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// NewWithPath returns a new Parser for the tape at the given path. Relative
+// Source and TypeFile paths are resolved against the directory of the tape.
+func NewWithPath(l *lexer.Lexer, path string) *Parser {
+	p := New(l)
+	p.dir = filepath.Dir(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		p.sources = []string{abs}
+	}
+	return p
+}
+
 // Parse takes an input string provided by the lexer and parses it into a
 // list of commands.
 func (p *Parser) Parse() []Command {
@@ -147,7 +207,9 @@ func (p *Parser) parseCommand() Command {
 		token.RIGHT,
 		token.UP,
 		token.PAGEUP,
-		token.PAGEDOWN:
+		token.PAGEDOWN,
+		token.HOME,
+		token.END:
 		return p.parseKeypress(p.cur.Type)
 	case token.SET:
 		return p.parseSet()
@@ -177,6 +239,20 @@ func (p *Parser) parseCommand() Command {
 		return p.parseCopy()
 	case token.PASTE:
 		return p.parsePaste()
+	case token.WAIT:
+		return p.parseWait()
+	case token.ENV:
+		return p.parseEnv()
+	case token.SELECT:
+		return p.parseSelect()
+	case token.CAPTION:
+		return p.parseCaption()
+	case token.ECHO:
+		return p.parseEcho()
+	case token.TYPE_FILE:
+		return p.parseTypeFile()
+	case token.EXEC:
+		return p.parseExec()
 	default:
 		p.errors = append(p.errors, NewError(p.cur, "Invalid command: "+p.cur.Literal))
 		return Command{Type: token.ILLEGAL}
@@ -213,37 +289,48 @@ func (p *Parser) parseRepeat() string {
 	return "1"
 }
 
-// parseTime parses a time argument.
+// parseTime parses a time argument. Bare numbers are in seconds.
 //
-// <number>[ms]
+// <number>[ms|s|m]
 func (p *Parser) parseTime() string {
 	var t string
 
+	if p.peek.Type == token.ILLEGAL && p.peek.Literal == "-" {
+		p.errors = append(p.errors, NewError(p.peek, "Time must not be negative"))
+		p.nextToken()
+	}
+
 	if p.peek.Type == token.NUMBER {
 		t = p.peek.Literal
 		p.nextToken()
 	} else {
 		p.errors = append(p.errors, NewError(p.cur, "Expected time after "+p.cur.Literal))
+		return ""
 	}
 
 	// Allow TypingSpeed to have bare units (e.g. 50ms, 100ms)
-	if p.peek.Type == token.MILLISECONDS || p.peek.Type == token.SECONDS {
+	if p.peek.Type == token.MILLISECONDS || p.peek.Type == token.SECONDS || p.peek.Type == token.MINUTES {
 		t += p.peek.Literal
 		p.nextToken()
 	} else {
 		t += "s"
 	}
 
+	if _, err := time.ParseDuration(t); err != nil {
+		p.errors = append(p.errors, NewError(p.cur, "Invalid time: "+t))
+	}
+
 	return t
 }
 
 // parseCtrl parses a control command.
 // A control command takes one or multiples characters and/or modifiers to type while ctrl is held down.
 //
-// Ctrl[+Alt][+Shift]+<char>
+// Ctrl[+Alt][+Shift]+<char> [count]
 // E.g:
 // Ctrl+Shift+O
 // Ctrl+Alt+Shift+P
+// Ctrl+C 3
 func (p *Parser) parseCtrl() Command {
 	var args []string
 
@@ -290,13 +377,24 @@ func (p *Parser) parseCtrl() Command {
 	}
 
 	ctrlArgs := strings.Join(args, " ")
-	return Command{Type: token.CTRL, Args: ctrlArgs}
+	return Command{Type: token.CTRL, Options: p.parseModifierRepeat(), Args: ctrlArgs}
+}
+
+// parseModifierRepeat parses the optional repeat count of a key combination.
+// It is left empty when the key combination is pressed once.
+//
+// i.e. Ctrl+C 3
+func (p *Parser) parseModifierRepeat() string {
+	if p.peek.Type != token.NUMBER {
+		return ""
+	}
+	return p.parseRepeat()
 }
 
 // parseAlt parses an alt command.
 // An alt command takes a character to type while the modifier is held down.
 //
-// Alt+<character>
+// Alt+<character> [count]
 func (p *Parser) parseAlt() Command {
 	if p.peek.Type == token.PLUS {
 		p.nextToken()
@@ -305,7 +403,7 @@ func (p *Parser) parseAlt() Command {
 			p.peek.Type == token.TAB {
 			c := p.peek.Literal
 			p.nextToken()
-			return Command{Type: token.ALT, Args: c}
+			return Command{Type: token.ALT, Options: p.parseModifierRepeat(), Args: c}
 		}
 	}
 
@@ -316,7 +414,7 @@ func (p *Parser) parseAlt() Command {
 // parseShift parses a shift command.
 // A shift command takes one character and types while shift is held down.
 //
-// Shift+<char>
+// Shift+<char> [count]
 // E.g.
 // Shift+A
 // Shift+Tab
@@ -329,7 +427,7 @@ func (p *Parser) parseShift() Command {
 			p.peek.Type == token.TAB {
 			c := p.peek.Literal
 			p.nextToken()
-			return Command{Type: token.SHIFT, Args: c}
+			return Command{Type: token.SHIFT, Options: p.parseModifierRepeat(), Args: c}
 		}
 	}
 
@@ -360,9 +458,12 @@ func (p *Parser) parseOutput() Command {
 		return cmd
 	}
 
-	ext := filepath.Ext(p.peek.Literal)
+	ext := strings.ToLower(filepath.Ext(p.peek.Literal))
 	if ext != "" {
 		cmd.Options = ext
+		if !IsValidOutputFormat(ext) {
+			p.errors = append(p.errors, NewError(p.peek, fmt.Sprintf("Unsupported output format %s, expected one of %s", ext, strings.Join(OutputFormats, ", "))))
+		}
 	} else {
 		cmd.Options = ".png"
 		if !strings.HasSuffix(p.peek.Literal, "/") {
@@ -399,18 +500,138 @@ func (p *Parser) parseSet() Command {
 		if p.peek.Type == token.PERCENT {
 			p.nextToken()
 		}
-	case token.TYPING_SPEED:
+
+		if !IsValidLoopOffset(cmd.Args) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "LoopOffset must be a percentage from 0 up to 100."),
+			)
+		}
+	case token.POSTER_FRAME:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+		// Allow PosterFrame without '%'
+		// Set PosterFrame 50
+		cmd.Args += "%"
+		if p.peek.Type == token.PERCENT {
+			p.nextToken()
+		}
+
+		if !IsValidPosterFrame(cmd.Args) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "PosterFrame must be a percentage from 0 to 100."),
+			)
+		}
+	case token.TYPING_SPEED, token.CURSOR_BLINK_RATE, token.WAIT_TIMEOUT, token.MAX_DURATION,
+		token.TAB_SETTLE, token.CLEANUP_WAIT, token.BANNER_DURATION:
 		cmd.Args = p.peek.Literal
 		p.nextToken()
-		// Allow TypingSpeed to have bare units (e.g. 10ms)
+		// Allow durations to have bare units (e.g. 10ms)
 		// Set TypingSpeed 10ms
 		if p.peek.Type == token.MILLISECONDS ||
-			p.peek.Type == token.SECONDS {
+			p.peek.Type == token.SECONDS ||
+			p.peek.Type == token.MINUTES {
 			cmd.Args += p.peek.Literal
 			p.nextToken()
-		} else if cmd.Options == "TypingSpeed" {
+		} else {
 			cmd.Args += "s"
 		}
+	case token.TRIM_START, token.TRIM_END:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+		// Allow trimming a duration instead of a number of frames
+		// Set TrimEnd 2s
+		if p.peek.Type == token.MILLISECONDS || p.peek.Type == token.SECONDS || p.peek.Type == token.MINUTES {
+			cmd.Args += p.peek.Literal
+			p.nextToken()
+		}
+
+		if !IsValidTrim(cmd.Args) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Options+" must be a number of frames or a duration."),
+			)
+		}
+	case token.CROP:
+		// Set Crop <x> <y> <width> <height>
+		var args []string
+		for p.peek.Type == token.NUMBER {
+			p.nextToken()
+			args = append(args, p.cur.Literal)
+		}
+		cmd.Args = strings.Join(args, " ")
+
+		if len(args) != 4 { //nolint:gomnd
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "Crop expects the x, y, width and height of the crop."),
+			)
+		}
+	case token.FRAMERATE, token.CAPTURE_FRAMERATE:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if !IsValidFramerate(cmd.Args) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, fmt.Sprintf("%s must be a number between %d and %d.", cmd.Options, MinFramerate, MaxFramerate)),
+			)
+		}
+	case token.SCALE:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		scale, err := strconv.ParseFloat(cmd.Args, 64)
+		if err != nil || scale <= 0 || scale > MaxScale {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, fmt.Sprintf("Scale must be a positive number up to %d.", MaxScale)),
+			)
+		}
+	case token.PLAYBACK_SPEED:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		speed, err := strconv.ParseFloat(cmd.Args, 64)
+		if err != nil || speed <= 0 {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "PlaybackSpeed must be a positive number."),
+			)
+		}
+	case token.TYPING_VARIANCE:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		variance, err := strconv.ParseFloat(cmd.Args, 64)
+		if err != nil || variance < 0 || variance > 1 {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "TypingVariance must be a number between 0 and 1."),
+			)
+		}
+	case token.CAPTURE_QUALITY:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		q, err := strconv.ParseFloat(cmd.Args, 64)
+		if err != nil || q <= 0 || q > 1 {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "CaptureQuality must be a number above 0, up to 1."),
+			)
+		}
+	case token.FRAME_FORMAT:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if cmd.Args != "png" && cmd.Args != "jpeg" {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Args+" is not a valid frame format, expected png or jpeg."),
+			)
+		}
 	case token.WINDOW_BAR:
 		cmd.Args = p.peek.Literal
 		p.nextToken()
@@ -442,7 +663,159 @@ func (p *Parser) parseSet() Command {
 				)
 			}
 		}
-	case token.CURSOR_BLINK:
+	case token.FONT_FILE:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		// Like TypeFile, a relative font file is relative to the tape.
+		isURL := strings.HasPrefix(cmd.Args, "http://") || strings.HasPrefix(cmd.Args, "https://")
+		if !isURL && !filepath.IsAbs(cmd.Args) && p.dir != "" {
+			cmd.Args = filepath.Join(p.dir, cmd.Args)
+		}
+	case token.FONT_WEIGHT, token.FONT_WEIGHT_BOLD:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if !IsValidFontWeight(cmd.Args) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Args+" is not a valid font weight."),
+			)
+		}
+	case token.MINIMUM_CONTRAST_RATIO:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		ratio, err := strconv.ParseFloat(cmd.Args, 64)
+		if err != nil || ratio < minContrastRatio || ratio > maxContrastRatio {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, fmt.Sprintf("MinimumContrastRatio must be a number between %d and %d.", minContrastRatio, maxContrastRatio)),
+			)
+		}
+	case token.LOGGING:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if cmd.Args != "text" && cmd.Args != "json" {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Args+" is not a valid log format, expected text or json."),
+			)
+		}
+	case token.CURSOR_STYLE:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if !IsValidCursorStyle(p.cur.Literal) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, p.cur.Literal+" is not a valid cursor style."),
+			)
+		}
+	case token.CRF:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		crf, err := strconv.Atoi(cmd.Args)
+		if err != nil || crf < 0 || crf > maxCRF {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, fmt.Sprintf("CRF must be a number between 0 and %d.", maxCRF)),
+			)
+		}
+	case token.BITRATE:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		// Allow bitrates with units (e.g. 500K, 2M)
+		if p.peek.Type == token.STRING && bitrateUnitRegex.MatchString(p.peek.Literal) {
+			cmd.Args += p.peek.Literal
+			p.nextToken()
+		}
+		if !bitrateRegex.MatchString(cmd.Args) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Args+" is not a valid bitrate."),
+			)
+		}
+	case token.GIF_DITHER:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if !IsValidDither(cmd.Args) {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Args+" is not a valid dithering algorithm."),
+			)
+		}
+	case token.GIF_COLORS:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		colors, err := strconv.Atoi(cmd.Args)
+		if err != nil || colors < minGIFColors || colors > maxGIFColors {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, fmt.Sprintf("GIFColors must be a number between %d and %d.", minGIFColors, maxGIFColors)),
+			)
+		}
+	case token.LOOP_COUNT:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		// Allow -1 to play the output once
+		// Set LoopCount -1
+		if cmd.Args == "-" && p.peek.Type == token.NUMBER {
+			cmd.Args += p.peek.Literal
+			p.nextToken()
+		}
+		if count, err := strconv.Atoi(cmd.Args); err != nil || count < -1 {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "LoopCount must be -1 or more."),
+			)
+		}
+	case token.TERM_ROWS, token.TERM_COLS:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if size, err := strconv.Atoi(cmd.Args); err != nil || size < 1 {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Options+" must be a positive number."),
+			)
+		}
+	case token.PORT:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		port, err := strconv.Atoi(cmd.Args)
+		if err != nil || port < 1 || port > maxPort {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, cmd.Args+" is not a valid port."),
+			)
+		}
+	case token.WAIT_PATTERN:
+		cmd.Args = p.peek.Literal
+		p.nextToken()
+
+		if p.cur.Type != token.REGEX {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "expected regular expression."),
+			)
+		} else if _, err := regexp.Compile(cmd.Args); err != nil {
+			p.errors = append(
+				p.errors,
+				NewError(p.cur, "Invalid regular expression: "+err.Error()),
+			)
+		}
+	case token.CURSOR_BLINK, token.HIDE_CURSOR, token.BOOMERANG, token.DEBUG,
+		token.HEADLESS, token.PARALLEL_RENDER, token.KEY_SOUND,
+		token.AUDIO_LOOP, token.SKIP_BLANK_FRAMES, token.BRACKETED_PASTE, token.DEDUP,
+		token.VARIABLE_FRAMERATE, token.STREAM_FRAMES, token.EXEC_CLEAR:
 		cmd.Args = p.peek.Literal
 		p.nextToken()
 
@@ -539,6 +912,55 @@ func (p *Parser) parseType() Command {
 	return cmd
 }
 
+// parseTypeFile parses a type file command.
+// A type file command types the contents of a file, e.g. a script, at the
+// typing speed. The path is relative to the tape.
+//
+// TypeFile[@<time>] "<path>"
+func (p *Parser) parseTypeFile() Command {
+	cmd := Command{Type: token.TYPE_FILE}
+
+	cmd.Options = p.parseSpeed()
+
+	if p.peek.Type != token.STRING {
+		p.errors = append(p.errors, NewError(p.peek, p.cur.Literal+" expects path"))
+		return cmd
+	}
+	p.nextToken()
+
+	path := p.cur.Literal
+	if !filepath.IsAbs(path) && p.dir != "" {
+		path = filepath.Join(p.dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		p.errors = append(p.errors, NewError(p.cur, fmt.Sprintf("File %s not found", path)))
+		return cmd
+	}
+	cmd.Args = path
+
+	return cmd
+}
+
+// parseExec parses an exec command.
+// An exec command runs a shell command without recording it, e.g. to set up
+// the demo, and waits for the prompt to come back.
+//
+// Exec[@<timeout>] "<command>"
+func (p *Parser) parseExec() Command {
+	cmd := Command{Type: token.EXEC}
+
+	cmd.Options = p.parseSpeed()
+
+	if p.peek.Type != token.STRING {
+		p.errors = append(p.errors, NewError(p.peek, p.cur.Literal+" expects string"))
+		return cmd
+	}
+	p.nextToken()
+	cmd.Args = p.cur.Literal
+
+	return cmd
+}
+
 // parseCopy parses a copy command
 // A copy command takes a string to the clipboard
 //
@@ -577,6 +999,134 @@ func (p *Parser) parsePaste() Command {
 	return cmd
 }
 
+// envKeyRegex matches valid environment variable names.
+var envKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseEnv parses an env command.
+// An env command takes a variable name and the value to set it to in the
+// environment of the shell.
+//
+// Env <key> <value>
+func (p *Parser) parseEnv() Command {
+	cmd := Command{Type: token.ENV}
+
+	if p.peek.Type != token.STRING || !envKeyRegex.MatchString(p.peek.Literal) {
+		p.errors = append(p.errors, NewError(p.peek, p.cur.Literal+" expects a variable name"))
+		return cmd
+	}
+	p.nextToken()
+	cmd.Options = p.cur.Literal
+
+	switch p.peek.Type {
+	case token.STRING, token.NUMBER, token.BOOLEAN:
+		p.nextToken()
+		cmd.Args = p.cur.Literal
+	default:
+		p.errors = append(p.errors, NewError(p.peek, p.cur.Literal+" expects a value"))
+	}
+
+	return cmd
+}
+
+// parseSelect parses a select command.
+// A select command selects the text from the start to the end row and column
+// of the screen, both included. Without any position, it clears the selection.
+//
+// Select [<row> <col> <row> <col>]
+func (p *Parser) parseSelect() Command {
+	cmd := Command{Type: token.SELECT}
+
+	var pos []int
+	for p.peek.Type == token.NUMBER {
+		p.nextToken()
+		n, err := strconv.Atoi(p.cur.Literal)
+		if err != nil {
+			p.errors = append(p.errors, NewError(p.cur, p.cur.Literal+" is not a row or column"))
+		}
+		pos = append(pos, n)
+	}
+
+	switch {
+	case len(pos) == 0:
+		return cmd
+	case len(pos) != 4: //nolint:gomnd
+		p.errors = append(p.errors, NewError(p.cur, "Select expects a start and end row and column"))
+	case pos[2] < pos[0] || pos[2] == pos[0] && pos[3] < pos[1]:
+		p.errors = append(p.errors, NewError(p.cur, "Select expects the end to come after the start"))
+	}
+
+	strs := make([]string, len(pos))
+	for i, n := range pos {
+		strs[i] = strconv.Itoa(n)
+	}
+	cmd.Args = strings.Join(strs, " ")
+	return cmd
+}
+
+// parseCaption parses a caption command.
+// A caption command shows the text as a caption from the current frame, for
+// the given time or until the next caption. An empty text hides the caption.
+//
+// Caption "<text>" [<time>]
+func (p *Parser) parseCaption() Command {
+	cmd := Command{Type: token.CAPTION}
+
+	if p.peek.Type != token.STRING {
+		p.errors = append(p.errors, NewError(p.peek, p.cur.Literal+" expects string"))
+		return cmd
+	}
+	p.nextToken()
+	cmd.Args = p.cur.Literal
+
+	if p.peek.Type == token.NUMBER || (p.peek.Type == token.ILLEGAL && p.peek.Literal == "-") {
+		cmd.Options = p.parseTime()
+	}
+
+	return cmd
+}
+
+// parseEcho parses an echo command.
+// An echo command turns the echo of the terminal on or off, to type text
+// without showing it, e.g. a password.
+//
+// Echo <on|off>
+func (p *Parser) parseEcho() Command {
+	cmd := Command{Type: token.ECHO}
+
+	if p.peek.Literal != "on" && p.peek.Literal != "off" {
+		p.errors = append(p.errors, NewError(p.peek, p.cur.Literal+" expects on or off"))
+		return cmd
+	}
+	p.nextToken()
+	cmd.Args = p.cur.Literal
+
+	return cmd
+}
+
+// parseWait parses a wait command.
+// A wait command blocks until the terminal matches the given regular
+// expression or the timeout elapses. Without a regular expression, it waits
+// for the WaitPattern (by default, the shell prompt).
+//
+// Wait[@<timeout>] [/<regex>/]
+func (p *Parser) parseWait() Command {
+	cmd := Command{Type: token.WAIT}
+
+	cmd.Options = p.parseSpeed()
+
+	if p.peek.Type != token.REGEX {
+		return cmd
+	}
+
+	p.nextToken()
+	cmd.Args = p.cur.Literal
+	if _, err := regexp.Compile(cmd.Args); err != nil {
+		p.errors = append(p.errors, NewError(p.cur, "Invalid regular expression: "+err.Error()))
+	}
+
+	return cmd
+}
+
 // parseSource parses source command.
 // Source command takes a tape path to include in current tape.
 //
@@ -600,6 +1150,11 @@ func (p *Parser) parseSource() Command {
 		return cmd
 	}
 
+	// Resolve relative paths against the directory of the including tape.
+	if !filepath.IsAbs(srcPath) && p.dir != "" {
+		srcPath = filepath.Join(p.dir, srcPath)
+	}
+
 	// Check if tape exist
 	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
 		notFoundErr := fmt.Sprintf("File %s not found", srcPath)
@@ -608,7 +1163,21 @@ func (p *Parser) parseSource() Command {
 		return cmd
 	}
 
-	// Check if source tape contains nested Source command
+	// Check the tape isn't already being sourced
+	absPath, err := filepath.Abs(srcPath)
+	if err != nil {
+		p.errors = append(p.errors, NewError(p.peek, fmt.Sprintf("Unable to resolve path: %s", srcPath)))
+		p.nextToken()
+		return cmd
+	}
+	for _, source := range p.sources {
+		if source == absPath {
+			p.errors = append(p.errors, NewError(p.peek, fmt.Sprintf("Recursive Source of %s detected", srcPath)))
+			p.nextToken()
+			return cmd
+		}
+	}
+
 	d, err := os.ReadFile(srcPath)
 	if err != nil {
 		readErr := fmt.Sprintf("Unable to read file: %s", srcPath)
@@ -628,16 +1197,9 @@ func (p *Parser) parseSource() Command {
 
 	srcLexer := lexer.New(srcTape)
 	srcParser := New(srcLexer)
-
-	// Check not nested source
-	srcCmds := srcParser.Parse()
-	for _, cmd := range srcCmds {
-		if cmd.Type == token.SOURCE {
-			p.errors = append(p.errors, NewError(p.peek, "Nested Source detected"))
-			p.nextToken()
-			return cmd
-		}
-	}
+	srcParser.dir = filepath.Dir(srcPath)
+	srcParser.sources = append(append([]string{}, p.sources...), absPath)
+	_ = srcParser.Parse()
 
 	// Check src errors
 	srcErrors := srcParser.Errors()
@@ -647,7 +1209,7 @@ func (p *Parser) parseSource() Command {
 		return cmd
 	}
 
-	cmd.Args = p.peek.Literal
+	cmd.Args = srcPath
 	p.nextToken()
 	return cmd
 }
@@ -693,9 +1255,83 @@ func (p *Parser) nextToken() {
 	p.peek = p.l.NextToken()
 }
 
+// IsValidDither returns whether the given string is a dithering algorithm
+// supported for GIFs.
+func IsValidDither(s string) bool {
+	switch s {
+	case "none", "bayer", "heckbert", "floyd_steinberg", "sierra2", "sierra2_4a", "sierra3", "burkes", "atkinson":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsValidCursorStyle returns whether the given cursor style is supported by
+// xterm.js.
+func IsValidCursorStyle(s string) bool {
+	return s == "block" || s == "bar" || s == "underline"
+}
+
+// IsValidFramerate returns whether the framerate is a whole number of frames
+// per second within the accepted range.
+func IsValidFramerate(s string) bool {
+	framerate, err := strconv.Atoi(s)
+	return err == nil && framerate >= MinFramerate && framerate <= MaxFramerate
+}
+
+// IsValidLoopOffset returns whether the loop offset is a percentage of the
+// frames from 0 up to, but excluding, 100. The percent sign is optional.
+func IsValidLoopOffset(s string) bool {
+	offset, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	return err == nil && offset >= 0 && offset < 100
+}
+
+// OutputFormats are the extensions of the files supported by the Output
+// command. A path without an extension is a directory of frames.
+var OutputFormats = []string{".gif", ".mp4", ".webm", ".apng", ".webp", ".cast", ".png", ".txt", ".ascii", ".test"}
+
+// IsValidOutputFormat returns whether the extension is a supported output
+// format.
+func IsValidOutputFormat(ext string) bool {
+	for _, format := range OutputFormats {
+		if ext == format {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidPosterFrame returns whether the poster frame is a percentage of the
+// frames from 0 to 100. The percent sign is optional.
+func IsValidPosterFrame(s string) bool {
+	frame, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	return err == nil && frame >= 0 && frame <= 100
+}
+
+// IsValidTrim returns whether the trim is a whole number of frames, or a
+// duration, which isn't negative.
+func IsValidTrim(s string) bool {
+	if frames, err := strconv.Atoi(s); err == nil {
+		return frames >= 0
+	}
+	d, err := time.ParseDuration(s)
+	return err == nil && d >= 0
+}
+
+// IsValidFontWeight returns whether the given font weight is supported by
+// xterm.js, i.e. normal, bold, or a multiple of 100 from 100 to 900.
+func IsValidFontWeight(s string) bool {
+	switch s {
+	case "normal", "bold", "100", "200", "300", "400", "500", "600", "700", "800", "900":
+		return true
+	default:
+		return false
+	}
+}
+
 // Check if a given windowbar type is valid
 func isValidWindowBar(w string) bool {
 	return w == "" ||
 		w == "Colorful" || w == "ColorfulRight" ||
-		w == "Rings" || w == "RightsRight"
+		w == "Rings" || w == "RingsRight"
 }
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -26,7 +27,12 @@ Up@50ms
 Down 2
 Ctrl+C
 Ctrl+L
+Ctrl+D 2
+Home
+End@200ms 2
 Alt+.
+Alt+b 3
+Shift+Tab 2
 Sleep 100ms
 Sleep 3`
 
@@ -46,7 +52,12 @@ Sleep 3`
 		{Type: token.DOWN, Options: "", Args: "2"},
 		{Type: token.CTRL, Options: "", Args: "C"},
 		{Type: token.CTRL, Options: "", Args: "L"},
+		{Type: token.CTRL, Options: "2", Args: "D"},
+		{Type: token.HOME, Options: "", Args: "1"},
+		{Type: token.END, Options: "200ms", Args: "2"},
 		{Type: token.ALT, Options: "", Args: "."},
+		{Type: token.ALT, Options: "3", Args: "b"},
+		{Type: token.SHIFT, Options: "2", Args: "Tab"},
 		{Type: token.SLEEP, Args: "100ms"},
 		{Type: token.SLEEP, Args: "3s"},
 	}
@@ -214,6 +225,7 @@ func TestParseCtrl(t *testing.T) {
 		name     string
 		tape     string
 		wantArgs []string
+		wantOpts string
 		wantErr  bool
 	}{
 		{
@@ -248,6 +260,18 @@ func TestParseCtrl(t *testing.T) {
 			wantArgs: []string{"Space"},
 			wantErr:  false,
 		},
+		{
+			name:     "should parse repeat count",
+			tape:     "Ctrl+C 3",
+			wantArgs: []string{"C"},
+			wantOpts: "3",
+		},
+		{
+			name:     "should parse repeat count with modifiers",
+			tape:     "Ctrl+Shift+K 2",
+			wantArgs: []string{"Shift", "K"},
+			wantOpts: "2",
+		},
 	}
 
 	for _, tc := range tests {
@@ -267,6 +291,10 @@ func TestParseCtrl(t *testing.T) {
 				t.Errorf("Expected to parse with no errors but was failure")
 			}
 
+			if cmd.Options != tc.wantOpts {
+				t.Errorf("Expected repeat count %q, got %q", tc.wantOpts, cmd.Options)
+			}
+
 			args := strings.Split(cmd.Args, " ")
 			if len(tc.wantArgs) != len(args) {
 				t.Fatalf("Unable to parse args, expected args %d, got %d", len(tc.wantArgs), len(args))
@@ -358,19 +386,130 @@ func TestParseSource(t *testing.T) {
 		test.run(t)
 	})
 
-	t.Run("should return error when find nested Source commands", func(t *testing.T) {
+	t.Run("should return error when Source is recursive", func(t *testing.T) {
 		test := &parseSourceTest{
-			tape: "Source source.tape",
-			srcTape: `Type "echo 'Welcome to VHS!'"
-	Source magic.tape
-	Type "goodbye"
-	`,
-			errors:    []string{"Nested Source detected"},
+			tape:      "Source source.tape",
+			srcTape:   `Source source.tape`,
+			errors:    []string{"source.tape has 1 errors"},
 			writeFile: true,
 		}
 
 		test.run(t)
 	})
+
+	t.Run("should resolve nested Source relative to the including tape", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(dir, "setup"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			"demo.tape":        "Source setup/setup.tape",
+			"setup/setup.tape": "Source env.tape",
+			"setup/env.tape":   `Type "export FOO=bar"`,
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		path := filepath.Join(dir, "demo.tape")
+		p := NewWithPath(lexer.New(files["demo.tape"]), path)
+		cmds := p.Parse()
+		if len(p.errors) > 0 {
+			t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+		}
+		if want := filepath.Join(dir, "setup", "setup.tape"); cmds[0].Args != want {
+			t.Errorf("Expected Source path %s, got %s", want, cmds[0].Args)
+		}
+	})
+}
+
+func TestParseExec(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: `Exec "cd /tmp"`,
+			want: Command{Type: token.EXEC, Args: "cd /tmp"},
+		},
+		{
+			tape: `Exec@1m "npm install"`,
+			want: Command{Type: token.EXEC, Options: "1m", Args: "npm install"},
+		},
+		{
+			tape:    "Exec",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			p := New(lexer.New(tc.tape))
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 || cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds)
+			}
+		})
+	}
+}
+
+func TestParseTypeFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "script.sh"), []byte("echo hi\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewWithPath(lexer.New(`TypeFile@10ms "script.sh"`), filepath.Join(dir, "demo.tape"))
+	cmds := p.Parse()
+	if len(p.errors) > 0 {
+		t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+	}
+	want := Command{Type: token.TYPE_FILE, Options: "10ms", Args: filepath.Join(dir, "script.sh")}
+	if len(cmds) != 1 || cmds[0] != want {
+		t.Errorf("Expected %v, got %v", want, cmds)
+	}
+
+	for _, tape := range []string{`TypeFile "missing.sh"`, "TypeFile"} {
+		p := NewWithPath(lexer.New(tape), filepath.Join(dir, "demo.tape"))
+		_ = p.Parse()
+		if len(p.errors) == 0 {
+			t.Errorf("Expected %q to parse with errors but was success", tape)
+		}
+	}
+}
+
+func TestParseFontFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		tape string
+		want string
+	}{
+		{"Set FontFile fonts/mono.ttf", filepath.Join(dir, "fonts/mono.ttf")},
+		{`Set FontFile "/usr/share/fonts/mono.ttf"`, "/usr/share/fonts/mono.ttf"},
+		{`Set FontFile "https://example.com/mono.woff2"`, "https://example.com/mono.woff2"},
+	}
+	for _, tc := range tests {
+		p := NewWithPath(lexer.New(tc.tape), filepath.Join(dir, "demo.tape"))
+		cmds := p.Parse()
+		if len(p.errors) > 0 {
+			t.Fatalf("Expected %q to parse with no errors, got %v", tc.tape, p.errors)
+		}
+		if len(cmds) != 1 || cmds[0].Args != tc.want {
+			t.Errorf("Expected %q to set the font file %s, got %v", tc.tape, tc.want, cmds)
+		}
+	}
 }
 
 type parseScreenshotTest struct {
@@ -417,3 +556,898 @@ func TestParseScreeenshot(t *testing.T) {
 		test.run(t)
 	})
 }
+
+func TestParseSet(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: "Set CursorBlinkRate 500ms",
+			want: Command{Type: token.SET, Options: "CursorBlinkRate", Args: "500ms"},
+		},
+		{
+			tape: "Set CursorBlinkRate 1",
+			want: Command{Type: token.SET, Options: "CursorBlinkRate", Args: "1s"},
+		},
+		{
+			tape: "Set CursorStyle bar",
+			want: Command{Type: token.SET, Options: "CursorStyle", Args: "bar"},
+		},
+		{
+			tape: "Set CursorColor \"#FF5FD2\"",
+			want: Command{Type: token.SET, Options: "CursorColor", Args: "#FF5FD2"},
+		},
+		{
+			tape:    "Set CursorStyle beam",
+			wantErr: true,
+		},
+		{
+			tape: "Set WindowBar RingsRight",
+			want: Command{Type: token.SET, Options: "WindowBar", Args: "RingsRight"},
+		},
+		{
+			tape: "Set CaptureFramerate 60",
+			want: Command{Type: token.SET, Options: "CaptureFramerate", Args: "60"},
+		},
+		{
+			tape: "Set TypingVariance 0.3",
+			want: Command{Type: token.SET, Options: "TypingVariance", Args: "0.3"},
+		},
+		{
+			tape:    "Set TypingVariance 1.5",
+			wantErr: true,
+		},
+		{
+			tape: "Set TypingSeed 42",
+			want: Command{Type: token.SET, Options: "TypingSeed", Args: "42"},
+		},
+		{
+			tape: "Set WaitTimeout 10s",
+			want: Command{Type: token.SET, Options: "WaitTimeout", Args: "10s"},
+		},
+		{
+			tape: "Set WaitTimeout 1m",
+			want: Command{Type: token.SET, Options: "WaitTimeout", Args: "1m"},
+		},
+		{
+			tape: "Set PlaybackSpeed 0.5",
+			want: Command{Type: token.SET, Options: "PlaybackSpeed", Args: "0.5"},
+		},
+		{
+			tape:    "Set PlaybackSpeed 0",
+			wantErr: true,
+		},
+		{
+			tape: "Set Framerate 120",
+			want: Command{Type: token.SET, Options: "Framerate", Args: "120"},
+		},
+		{
+			tape:    "Set Framerate 0",
+			wantErr: true,
+		},
+		{
+			tape:    "Set Framerate 240",
+			wantErr: true,
+		},
+		{
+			tape:    "Set CaptureFramerate 0.5",
+			wantErr: true,
+		},
+		{
+			tape: "Set Debug true",
+			want: Command{Type: token.SET, Options: "Debug", Args: "true"},
+		},
+		{
+			tape: "Set BrowserFlags \"--no-sandbox --disable-gpu\"",
+			want: Command{Type: token.SET, Options: "BrowserFlags", Args: "--no-sandbox --disable-gpu"},
+		},
+		{
+			tape: "Set ControlURL \"ws://127.0.0.1:9222/devtools/browser/abc\"",
+			want: Command{Type: token.SET, Options: "ControlURL", Args: "ws://127.0.0.1:9222/devtools/browser/abc"},
+		},
+		{
+			tape: "Set Command \"python3 -q\"",
+			want: Command{Type: token.SET, Options: "Command", Args: "python3 -q"},
+		},
+		{
+			tape: "Set Headless false",
+			want: Command{Type: token.SET, Options: "Headless", Args: "false"},
+		},
+		{
+			tape: "Set LoopOffset 25",
+			want: Command{Type: token.SET, Options: "LoopOffset", Args: "25%"},
+		},
+		{
+			tape:    "Set LoopOffset 100%",
+			wantErr: true,
+		},
+		{
+			tape:    "Set LoopOffset -5%",
+			wantErr: true,
+		},
+		{
+			tape: "Set Scale 2",
+			want: Command{Type: token.SET, Options: "Scale", Args: "2"},
+		},
+		{
+			tape:    "Set Scale 8",
+			wantErr: true,
+		},
+		{
+			tape: "Set Logging json",
+			want: Command{Type: token.SET, Options: "Logging", Args: "json"},
+		},
+		{
+			tape:    "Set Logging yaml",
+			wantErr: true,
+		},
+		{
+			tape: "Set FontFile fonts/mono.ttf",
+			want: Command{Type: token.SET, Options: "FontFile", Args: "fonts/mono.ttf"},
+		},
+		{
+			tape: "Set FontWeight 300",
+			want: Command{Type: token.SET, Options: "FontWeight", Args: "300"},
+		},
+		{
+			tape: "Set FontWeightBold bold",
+			want: Command{Type: token.SET, Options: "FontWeightBold", Args: "bold"},
+		},
+		{
+			tape:    "Set FontWeight 350",
+			wantErr: true,
+		},
+		{
+			tape: "Set MinimumContrastRatio 4.5",
+			want: Command{Type: token.SET, Options: "MinimumContrastRatio", Args: "4.5"},
+		},
+		{
+			tape:    "Set MinimumContrastRatio 0.5",
+			wantErr: true,
+		},
+		{
+			tape: "Set TrimStart 10",
+			want: Command{Type: token.SET, Options: "TrimStart", Args: "10"},
+		},
+		{
+			tape: "Set TrimEnd 1.5s",
+			want: Command{Type: token.SET, Options: "TrimEnd", Args: "1.5s"},
+		},
+		{
+			tape:    "Set TrimEnd 1.5",
+			wantErr: true,
+		},
+		{
+			tape: "Set Poster \"poster.png\"",
+			want: Command{Type: token.SET, Options: "Poster", Args: "poster.png"},
+		},
+		{
+			tape: "Set PosterFrame 100",
+			want: Command{Type: token.SET, Options: "PosterFrame", Args: "100%"},
+		},
+		{
+			tape:    "Set PosterFrame 150%",
+			wantErr: true,
+		},
+		{
+			tape: "Set TextDump \"frames/\"",
+			want: Command{Type: token.SET, Options: "TextDump", Args: "frames/"},
+		},
+		{
+			tape: "Set ExpectedOutput \"expected.txt\"",
+			want: Command{Type: token.SET, Options: "ExpectedOutput", Args: "expected.txt"},
+		},
+		{
+			tape: "Set Crop 0 0 400 200",
+			want: Command{Type: token.SET, Options: "Crop", Args: "0 0 400 200"},
+		},
+		{
+			tape:    "Set Crop 0 0 400",
+			wantErr: true,
+		},
+		{
+			tape: "Set KeySound true",
+			want: Command{Type: token.SET, Options: "KeySound", Args: "true"},
+		},
+		{
+			tape: "Set Audio \"narration.mp3\"",
+			want: Command{Type: token.SET, Options: "Audio", Args: "narration.mp3"},
+		},
+		{
+			tape: "Set AudioLoop true",
+			want: Command{Type: token.SET, Options: "AudioLoop", Args: "true"},
+		},
+		{
+			tape: "Set SkipBlankFrames true",
+			want: Command{Type: token.SET, Options: "SkipBlankFrames", Args: "true"},
+		},
+		{
+			tape: "Set BracketedPaste false",
+			want: Command{Type: token.SET, Options: "BracketedPaste", Args: "false"},
+		},
+		{
+			tape: "Set CleanupWait 0",
+			want: Command{Type: token.SET, Options: "CleanupWait", Args: "0s"},
+		},
+		{
+			tape: "Set CleanupWait 2s",
+			want: Command{Type: token.SET, Options: "CleanupWait", Args: "2s"},
+		},
+		{
+			tape: `Set Tmux "split-window -h ; select-pane -L"`,
+			want: Command{Type: token.SET, Options: "Tmux", Args: "split-window -h ; select-pane -L"},
+		},
+		{
+			tape: `Set Banner "Welcome"`,
+			want: Command{Type: token.SET, Options: "Banner", Args: "Welcome"},
+		},
+		{
+			tape: "Set BannerFile banner.txt",
+			want: Command{Type: token.SET, Options: "BannerFile", Args: "banner.txt"},
+		},
+		{
+			tape: "Set BannerDuration 2",
+			want: Command{Type: token.SET, Options: "BannerDuration", Args: "2s"},
+		},
+		{
+			tape: `Set Prompt "$ "`,
+			want: Command{Type: token.SET, Options: "Prompt", Args: "$ "},
+		},
+		{
+			tape: "Set Dedup true",
+			want: Command{Type: token.SET, Options: "Dedup", Args: "true"},
+		},
+		{
+			tape: "Set VariableFramerate true",
+			want: Command{Type: token.SET, Options: "VariableFramerate", Args: "true"},
+		},
+		{
+			tape: "Set StreamFrames true",
+			want: Command{Type: token.SET, Options: "StreamFrames", Args: "true"},
+		},
+		{
+			tape: "Set ExecClear true",
+			want: Command{Type: token.SET, Options: "ExecClear", Args: "true"},
+		},
+		{
+			tape: "Set LoopCount 3",
+			want: Command{Type: token.SET, Options: "LoopCount", Args: "3"},
+		},
+		{
+			tape: "Set LoopCount -1",
+			want: Command{Type: token.SET, Options: "LoopCount", Args: "-1"},
+		},
+		{
+			tape:    "Set LoopCount 1.5",
+			wantErr: true,
+		},
+		{
+			tape: "Set PixelFormat yuv444p",
+			want: Command{Type: token.SET, Options: "PixelFormat", Args: "yuv444p"},
+		},
+		{
+			tape: "Set CaptureQuality 0.8",
+			want: Command{Type: token.SET, Options: "CaptureQuality", Args: "0.8"},
+		},
+		{
+			tape:    "Set CaptureQuality 2",
+			wantErr: true,
+		},
+		{
+			tape: "Set FrameFormat jpeg",
+			want: Command{Type: token.SET, Options: "FrameFormat", Args: "jpeg"},
+		},
+		{
+			tape:    "Set FrameFormat webp",
+			wantErr: true,
+		},
+		{
+			tape: "Set ParallelRender true",
+			want: Command{Type: token.SET, Options: "ParallelRender", Args: "true"},
+		},
+		{
+			tape:    "Set ParallelRender 2",
+			wantErr: true,
+		},
+		{
+			tape: "Set Caption \"Building the project\"",
+			want: Command{Type: token.SET, Options: "Caption", Args: "Building the project"},
+		},
+		{
+			tape: "Set CaptionPosition top",
+			want: Command{Type: token.SET, Options: "CaptionPosition", Args: "top"},
+		},
+		{
+			tape: "Set Boomerang true",
+			want: Command{Type: token.SET, Options: "Boomerang", Args: "true"},
+		},
+		{
+			tape: "Set Theme.Dark \"Catppuccin Mocha\"",
+			want: Command{Type: token.SET, Options: "Theme.Dark", Args: "Catppuccin Mocha"},
+		},
+		{
+			tape: "Set Theme.Light { \"background\": \"#ffffff\" }",
+			want: Command{Type: token.SET, Options: "Theme.Light", Args: "{ \"background\": \"#ffffff\" }"},
+		},
+		{
+			tape: "Set TermRows 24",
+			want: Command{Type: token.SET, Options: "TermRows", Args: "24"},
+		},
+		{
+			tape:    "Set TermCols 0",
+			wantErr: true,
+		},
+		{
+			tape: "Set TabSettle 300ms",
+			want: Command{Type: token.SET, Options: "TabSettle", Args: "300ms"},
+		},
+		{
+			tape: "Set MaxDuration 5m",
+			want: Command{Type: token.SET, Options: "MaxDuration", Args: "5m"},
+		},
+		{
+			tape: "Set WaitPattern /\\$ $/",
+			want: Command{Type: token.SET, Options: "WaitPattern", Args: "\\$ $"},
+		},
+		{
+			tape:    "Set WaitPattern prompt",
+			wantErr: true,
+		},
+		{
+			tape: "Set Port 7681",
+			want: Command{Type: token.SET, Options: "Port", Args: "7681"},
+		},
+		{
+			tape:    "Set Port 70000",
+			wantErr: true,
+		},
+		{
+			tape: "Set WorkingDir /path/to/project",
+			want: Command{Type: token.SET, Options: "WorkingDir", Args: "/path/to/project"},
+		},
+		{
+			tape: "Set FFmpegPath /opt/ffmpeg/bin/ffmpeg",
+			want: Command{Type: token.SET, Options: "FFmpegPath", Args: "/opt/ffmpeg/bin/ffmpeg"},
+		},
+		{
+			tape: `Set FFmpegArgs "-tune animation"`,
+			want: Command{Type: token.SET, Options: "FFmpegArgs", Args: "-tune animation"},
+		},
+		{
+			tape: "Set CRF 28",
+			want: Command{Type: token.SET, Options: "CRF", Args: "28"},
+		},
+		{
+			tape:    "Set CRF 99",
+			wantErr: true,
+		},
+		{
+			tape: "Set Bitrate 2M",
+			want: Command{Type: token.SET, Options: "Bitrate", Args: "2M"},
+		},
+		{
+			tape: "Set Bitrate 500000",
+			want: Command{Type: token.SET, Options: "Bitrate", Args: "500000"},
+		},
+		{
+			tape:    "Set Bitrate fast",
+			wantErr: true,
+		},
+		{
+			tape: "Set GIFDither bayer",
+			want: Command{Type: token.SET, Options: "GIFDither", Args: "bayer"},
+		},
+		{
+			tape:    "Set GIFDither noise",
+			wantErr: true,
+		},
+		{
+			tape: "Set GIFColors 64",
+			want: Command{Type: token.SET, Options: "GIFColors", Args: "64"},
+		},
+		{
+			tape:    "Set GIFColors 512",
+			wantErr: true,
+		},
+		{
+			tape: "Set HideCursor true",
+			want: Command{Type: token.SET, Options: "HideCursor", Args: "true"},
+		},
+		{
+			tape:    "Set HideCursor yes",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(cmds))
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %+v, got %+v", tc.want, cmds[0])
+			}
+		})
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		tape string
+		want Command
+	}{
+		{
+			tape: `Type "default speed"`,
+			want: Command{Type: token.TYPE, Args: "default speed"},
+		},
+		{
+			tape: `Type@100ms "slow text"`,
+			want: Command{Type: token.TYPE, Options: "100ms", Args: "slow text"},
+		},
+		{
+			tape: `Type@.5 "slower text"`,
+			want: Command{Type: token.TYPE, Options: ".5s", Args: "slower text"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(cmds))
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds[0])
+			}
+		})
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	tape := `# Leading comment
+
+Type "hello" # greets the user
+Type 'echo "# not a comment"' # comment with a "quote"
+  # Indented comment
+Type hello world # unquoted
+Enter 2 # twice
+
+`
+	want := []Command{
+		{Type: token.TYPE, Args: "hello"},
+		{Type: token.TYPE, Args: `echo "# not a comment"`},
+		{Type: token.TYPE, Args: "hello world"},
+		{Type: token.ENTER, Args: "2"},
+	}
+
+	l := lexer.New(tape)
+	p := New(l)
+
+	cmds := p.Parse()
+	if len(p.errors) > 0 {
+		t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+	}
+	if len(cmds) != len(want) {
+		t.Fatalf("Expected %d commands, got %d: %v", len(want), len(cmds), cmds)
+	}
+	for i, cmd := range cmds {
+		if cmd != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], cmd)
+		}
+	}
+}
+
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: "Wait /World/",
+			want: Command{Type: token.WAIT, Args: "World"},
+		},
+		{
+			tape: "Wait@10s /^done$/",
+			want: Command{Type: token.WAIT, Options: "10s", Args: "^done$"},
+		},
+		{
+			tape: "Wait",
+			want: Command{Type: token.WAIT},
+		},
+		{
+			tape: "Wait@5s",
+			want: Command{Type: token.WAIT, Options: "5s"},
+		},
+		{
+			tape:    "Wait /[/",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(cmds))
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds[0])
+			}
+		})
+	}
+}
+
+func TestParseSleep(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: "Sleep 500ms",
+			want: Command{Type: token.SLEEP, Args: "500ms"},
+		},
+		{
+			tape: "Sleep 2s",
+			want: Command{Type: token.SLEEP, Args: "2s"},
+		},
+		{
+			tape: "Sleep 1.5s",
+			want: Command{Type: token.SLEEP, Args: "1.5s"},
+		},
+		{
+			tape: "Sleep 1m",
+			want: Command{Type: token.SLEEP, Args: "1m"},
+		},
+		{
+			tape: "Sleep 2",
+			want: Command{Type: token.SLEEP, Args: "2s"},
+		},
+		{
+			tape: "Sleep 0.5",
+			want: Command{Type: token.SLEEP, Args: "0.5s"},
+		},
+		{
+			tape:    "Sleep -1s",
+			wantErr: true,
+		},
+		{
+			tape:    "Sleep 1.2.3s",
+			wantErr: true,
+		},
+		{
+			tape:    "Sleep",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(cmds))
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds[0])
+			}
+		})
+	}
+}
+
+func TestParseSelect(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: "Select 0 0 1 4",
+			want: Command{Type: token.SELECT, Args: "0 0 1 4"},
+		},
+		{
+			tape: "Select 2 3 2 3",
+			want: Command{Type: token.SELECT, Args: "2 3 2 3"},
+		},
+		{
+			tape: "Select",
+			want: Command{Type: token.SELECT},
+		},
+		{
+			tape:    "Select 0 0 1",
+			wantErr: true,
+		},
+		{
+			tape:    "Select 1 0 0 4",
+			wantErr: true,
+		},
+		{
+			tape:    "Select 0 1.5 1 4",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(cmds))
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds[0])
+			}
+		})
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: "Output demo.gif",
+			want: Command{Type: token.OUTPUT, Options: ".gif", Args: "demo.gif"},
+		},
+		{
+			tape: "Output demo.MP4",
+			want: Command{Type: token.OUTPUT, Options: ".mp4", Args: "demo.MP4"},
+		},
+		{
+			tape: "Output frames/",
+			want: Command{Type: token.OUTPUT, Options: ".png", Args: "frames/"},
+		},
+		{
+			tape:    "Output demo.mov",
+			wantErr: true,
+		},
+		{
+			tape:    "Output frames",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds[0])
+			}
+		})
+	}
+}
+
+func TestParseEcho(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: "Echo off",
+			want: Command{Type: token.ECHO, Args: "off"},
+		},
+		{
+			tape: "Echo on",
+			want: Command{Type: token.ECHO, Args: "on"},
+		},
+		{
+			tape:    "Echo",
+			wantErr: true,
+		},
+		{
+			tape:    "Echo true",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			p := New(lexer.New(tc.tape))
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 || cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds)
+			}
+		})
+	}
+}
+
+func TestParseCaption(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: `Caption "Installing the tools" 2s`,
+			want: Command{Type: token.CAPTION, Options: "2s", Args: "Installing the tools"},
+		},
+		{
+			tape: `Caption "Done" 500ms`,
+			want: Command{Type: token.CAPTION, Options: "500ms", Args: "Done"},
+		},
+		{
+			tape: `Caption "Until the next caption"`,
+			want: Command{Type: token.CAPTION, Args: "Until the next caption"},
+		},
+		{
+			tape: `Caption ""`,
+			want: Command{Type: token.CAPTION},
+		},
+		{
+			tape:    "Caption 2s",
+			wantErr: true,
+		},
+		{
+			tape:    `Caption "Negative" -1s`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(cmds))
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds[0])
+			}
+		})
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	tests := []struct {
+		tape    string
+		want    Command
+		wantErr bool
+	}{
+		{
+			tape: "Env GREETING hello",
+			want: Command{Type: token.ENV, Options: "GREETING", Args: "hello"},
+		},
+		{
+			tape: `Env GREETING "Hello, 'world'"`,
+			want: Command{Type: token.ENV, Options: "GREETING", Args: "Hello, 'world'"},
+		},
+		{
+			tape: "Env PORT 8080",
+			want: Command{Type: token.ENV, Options: "PORT", Args: "8080"},
+		},
+		{
+			tape:    "Env",
+			wantErr: true,
+		},
+		{
+			tape:    "Env GREETING",
+			wantErr: true,
+		},
+		{
+			tape:    `Env "NOT VALID" value`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tape, func(t *testing.T) {
+			l := lexer.New(tc.tape)
+			p := New(l)
+
+			cmds := p.Parse()
+			if tc.wantErr {
+				if len(p.errors) == 0 {
+					t.Errorf("Expected to parse with errors but was success")
+				}
+				return
+			}
+
+			if len(p.errors) > 0 {
+				t.Fatalf("Expected to parse with no errors, got %v", p.errors)
+			}
+			if len(cmds) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(cmds))
+			}
+			if cmds[0] != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, cmds[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// The stages of a recording, as reported by Progress.
+const (
+	StageRecording = "recording"
+	StageRendering = "rendering"
+)
+
+// Progress is the progress of a recording, reported while frames are captured
+// and after each output is rendered.
+type Progress struct {
+	// Stage is either StageRecording or StageRendering.
+	Stage string
+	// Frames is the number of frames captured.
+	Frames int
+	// Elapsed is the time since the stage started.
+	Elapsed time.Duration
+	// Output is the format of the last rendered output, and Rendered and
+	// Outputs are the number of outputs rendered so far and in total.
+	Output   string
+	Rendered int
+	Outputs  int
+}
+
+// Percent returns the percentage of the outputs which are rendered. The length
+// of a recording isn't known in advance, so it is zero while recording.
+func (p Progress) Percent() float64 {
+	if p.Stage != StageRendering || p.Outputs == 0 {
+		return 0
+	}
+	return float64(p.Rendered) / float64(p.Outputs) * 100 //nolint:gomnd
+}
+
+// ProgressReporter is implemented by output writers which want to be notified
+// of the progress of the recording. Reporting progress is opt-in: Evaluate
+// only reports it when its output writer is a ProgressReporter.
+type ProgressReporter interface {
+	Progress(p Progress)
+}
+
+// reportProgress reports the progress, if anything is listening for it.
+func (vhs *VHS) reportProgress(p Progress) {
+	if vhs.progress != nil {
+		vhs.progress(p)
+	}
+}
+
+// progressWriter is an output writer which keeps the recording progress on the
+// last line of the terminal, below the commands written to it.
+type progressWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+	// line is whether the progress line is currently displayed.
+	line bool
+}
+
+func newProgressWriter(out io.Writer) *progressWriter {
+	return &progressWriter{out: out}
+}
+
+// Write writes the output above the progress line.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.clearLine()
+	return w.out.Write(p)
+}
+
+// Progress implements ProgressReporter.
+func (w *progressWriter) Progress(p Progress) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.clearLine()
+	switch p.Stage {
+	case StageRecording:
+		fmt.Fprint(w.out, GrayStyle.Render(fmt.Sprintf("Recording... %d frames (%s)", p.Frames, p.Elapsed.Round(time.Second/10))))
+		w.line = true
+	case StageRendering:
+		if p.Rendered == 0 {
+			fmt.Fprintln(w.out, GrayStyle.Render(fmt.Sprintf("Rendering %d frames...", p.Frames)))
+			return
+		}
+		fmt.Fprintln(w.out, GrayStyle.Render(fmt.Sprintf("Rendered %s (%d/%d, %.0f%%) in %s", p.Output, p.Rendered, p.Outputs, p.Percent(), p.Elapsed.Round(time.Second/10))))
+	}
+}
+
+// clearLine clears the progress line, if it is displayed.
+func (w *progressWriter) clearLine() {
+	if w.line {
+		fmt.Fprint(w.out, "\r\x1b[K")
+		w.line = false
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newProgressWriter(&buf)
+
+	w.Progress(Progress{Stage: StageRecording, Frames: 10, Elapsed: time.Second})
+	if _, err := w.Write([]byte("Type hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.Progress(Progress{Stage: StageRecording, Frames: 20, Elapsed: 2 * time.Second})
+	w.Progress(Progress{Stage: StageRendering, Frames: 20, Outputs: 2})
+	w.Progress(Progress{Stage: StageRendering, Frames: 20, Output: "GIF", Rendered: 1, Outputs: 2, Elapsed: time.Second})
+
+	lines := strings.Split(buf.String(), "\n")
+	for i, want := range []string{
+		"Recording... 10 frames (1s)\r\x1b[KType hello",
+		"Recording... 20 frames (2s)\r\x1b[KRendering 20 frames...",
+		"Rendered GIF (1/2, 50%) in 1s",
+	} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("expected line %d to contain %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
+func TestRenderProgress(t *testing.T) {
+	ffmpeg, err := exec.LookPath("false")
+	if err != nil {
+		t.Skip("false is not available")
+	}
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	dir := t.TempDir()
+	v.totalFrames = 1
+	v.Options.Video.FFmpegPath = ffmpeg
+	v.Options.Video.Output.GIF = filepath.Join(dir, "out.gif")
+	v.Options.Video.Output.MP4 = filepath.Join(dir, "out.mp4")
+
+	var progress []Progress
+	v.progress = func(p Progress) { progress = append(progress, p) }
+	_ = v.Render()
+
+	if len(progress) != 3 {
+		t.Fatalf("expected the start and each output to be reported, got %+v", progress)
+	}
+	if p := progress[0]; p.Rendered != 0 || p.Outputs != 2 || p.Frames != 1 {
+		t.Errorf("expected the start of the rendering, got %+v", p)
+	}
+	if p := progress[2]; p.Output != "MP4" || p.Rendered != 2 || p.Percent() != 100 {
+		t.Errorf("expected the MP4 to be rendered last, got %+v", p)
+	}
+}
@@ -2,71 +2,118 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 )
 
+const (
+	textScreenshotFormat   = "screenshot-text-%05d.png"
+	cursorScreenshotFormat = "screenshot-cursor-%05d.png"
+)
+
 // ScreenshotOptions holds options related with screenshots.
 type ScreenshotOptions struct {
-	// frameCapture holds a flag indicating if screenshots must be taken.
-	frameCapture bool
-
-	// nextScreenshotPath holds the path of new screenshot.
-	nextScreenshotPath string
+	// counter holds the number of screenshots taken so far. It is used to name
+	// the captured layers so they never clash with the recorded frames.
+	counter int
 
-	// screenshots represents a map holding screenshot path as key and frame as value.
-	screenshots map[string]int
-
-	// Input represents location of cursor and text frames png files.
+	// Input represents location of cursor and text layers png files.
 	input string
 
 	style *StyleOptions
+
+	// ffmpegPath is the ffmpeg binary used to compose screenshots.
+	ffmpegPath string
+
+	// pending are the screenshots whose layers are captured, which are
+	// composed when the recording is rendered.
+	pending []screenshot
+}
+
+// screenshot is a screenshot whose text and cursor layers are captured, but
+// not yet composed into the image at path.
+type screenshot struct {
+	path, text, cursor string
 }
 
 // NewScreenshotOptions returns ScreenshotOptions by given input.
 func NewScreenshotOptions(input string, style *StyleOptions) ScreenshotOptions {
 	return ScreenshotOptions{
-		frameCapture:       false,
-		nextScreenshotPath: "",
-		screenshots:        make(map[string]int),
-		input:              input,
-		style:              style,
+		counter: 0,
+		input:   input,
+		style:   style,
 	}
 }
 
-// makeScreenshot stores in screenshots map the target frame of the screenshot.
-// After storing frame it disables frame capture.
-func (opts *ScreenshotOptions) makeScreenshot(frame int) {
-	opts.screenshots[opts.nextScreenshotPath] = frame
-
-	opts.frameCapture = false
-	opts.nextScreenshotPath = ""
-}
-
-// captureNextFrame prepares capture of next frame by given path.
-func (opts *ScreenshotOptions) enableFrameCapture(path string) {
-	opts.frameCapture = true
-	opts.nextScreenshotPath = path
+// nextLayerPaths returns the paths to store the text and cursor layers of the
+// next screenshot.
+func (opts *ScreenshotOptions) nextLayerPaths() (string, string) {
+	opts.counter++
+	return filepath.Join(opts.input, fmt.Sprintf(textScreenshotFormat, opts.counter)),
+		filepath.Join(opts.input, fmt.Sprintf(cursorScreenshotFormat, opts.counter))
 }
 
-// MakeScreenshots generates screenshots by given ScreenshotOptions.
-func MakeScreenshots(opts ScreenshotOptions) []*exec.Cmd {
-	cmds := []*exec.Cmd{}
+// Screenshot captures the current state of the terminal, which is written to
+// the given path once the recording is rendered. Composing the screenshot with
+// ffmpeg then, rather than now, keeps ffmpeg from holding up the tape.
+//
+// Screenshots are taken independently from the Record loop: they are captured
+// even while recording is paused and never affect the frame counter. The
+// layers are captured like the frames, under the same lock, so that they are
+// from the same moment, keep the size of the frames and leave out a hidden
+// cursor, but they are always lossless PNG images.
+func (vhs *VHS) Screenshot(path string) error {
+	vhs.mutex.Lock()
+	text, cursor, err := vhs.captureCanvases("image/png", quality)
+	vhs.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not capture screenshot: %w", err)
+	}
+	// A hidden cursor leaves a blank cursor layer.
+	if cursor == nil {
+		cursor, err = blankFrame(text)
+		if err != nil {
+			return fmt.Errorf("error blanking cursor layer: %w", err)
+		}
+	}
 
-	for path, frame := range opts.screenshots {
-		cursorStream := filepath.Join(opts.input, fmt.Sprintf(cursorFrameFormat, frame))
-		textStream := filepath.Join(opts.input, fmt.Sprintf(textFrameFormat, frame))
+	textPath, cursorPath := vhs.Options.Screenshot.nextLayerPaths()
+	if err := os.WriteFile(textPath, text, os.ModePerm); err != nil {
+		return fmt.Errorf("error writing text layer: %w", err)
+	}
+	if err := os.WriteFile(cursorPath, cursor, os.ModePerm); err != nil {
+		return fmt.Errorf("error writing cursor layer: %w", err)
+	}
 
-		args := opts.buildFFopts(path, textStream, cursorStream)
+	vhs.Options.Screenshot.pending = append(vhs.Options.Screenshot.pending, screenshot{path, textPath, cursorPath})
+	return nil
+}
 
-		//nolint:gosec
-		cmds = append(cmds, exec.Command(
-			"ffmpeg",
-			args...,
-		))
+// renderScreenshots composes the screenshots taken during the tape, and
+// returns the errors of those ffmpeg failed to create.
+func (vhs *VHS) renderScreenshots() []FFmpegError {
+	var errs []FFmpegError
+	for _, s := range vhs.Options.Screenshot.pending {
+		vhs.logStatus("Creating " + s.path + "...")
+		ensureDir(s.path)
+		cmd := MakeScreenshot(vhs.Options.Screenshot, s.path, s.text, s.cursor)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			vhs.logMessage(string(out))
+			errs = append(errs, newFFmpegError("Screenshot", cmd, out, err))
+		}
 	}
+	return errs
+}
 
-	return cmds
+// MakeScreenshot returns the command to compose the given text and cursor
+// layers into a screenshot.
+func MakeScreenshot(opts ScreenshotOptions, path, textStream, cursorStream string) *exec.Cmd {
+	//nolint:gosec
+	return exec.Command(
+		ffmpegBin(opts.ffmpegPath),
+		opts.buildFFopts(path, textStream, cursorStream)...,
+	)
 }
 
 // buildFFopts assembles an ffmpeg command from some VideoOptions.
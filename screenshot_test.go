@@ -1,48 +1,100 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestScreenshot(t *testing.T) {
-	t.Run("makeScreenshot should add screenshot to map and disable capture", func(t *testing.T) {
-		path := "sample.png"
-		targetFrame := 60
-		opts := ScreenshotOptions{
-			nextScreenshotPath: path,
-			frameCapture:       true,
-			screenshots:        make(map[string]int),
-		}
-
-		opts.makeScreenshot(targetFrame)
+	t.Run("nextLayerPaths should not clash with frames", func(t *testing.T) {
+		opts := NewScreenshotOptions("input", DefaultStyleOptions())
 
-		frame, ok := opts.screenshots[path]
-		if !ok {
-			t.Errorf("Unable to create screenshot: %s", path)
+		text, cursor := opts.nextLayerPaths()
+		if text != filepath.Join("input", "screenshot-text-00001.png") {
+			t.Errorf("unexpected text layer path: %s", text)
 		}
-
-		if frame != targetFrame {
-			t.Errorf("Unable to create screenshot: %s", path)
+		if cursor != filepath.Join("input", "screenshot-cursor-00001.png") {
+			t.Errorf("unexpected cursor layer path: %s", cursor)
 		}
 
-		if opts.frameCapture {
-			t.Error("frameCapture should be false after invoking makeScreenshot")
+		text, _ = opts.nextLayerPaths()
+		if text != filepath.Join("input", "screenshot-text-00002.png") {
+			t.Errorf("expected counter to be incremented, got: %s", text)
 		}
 	})
 
-	t.Run("enableFrameCapture", func(t *testing.T) {
-		path := "sample.png"
+	t.Run("MakeScreenshot should compose the given layers", func(t *testing.T) {
+		opts := NewScreenshotOptions(t.TempDir(), DefaultStyleOptions())
 
-		opts := ScreenshotOptions{
-			frameCapture: false,
-		}
+		cmd := MakeScreenshot(opts, "out.png", "text.png", "cursor.png")
+		args := strings.Join(cmd.Args, " ")
 
-		opts.enableFrameCapture(path)
+		if !strings.Contains(args, "-i text.png -i cursor.png") {
+			t.Errorf("expected text and cursor layers as inputs, got: %s", args)
+		}
+		if !strings.HasSuffix(args, "out.png") {
+			t.Errorf("expected screenshot path to be last argument, got: %s", args)
+		}
+	})
 
-		if !opts.frameCapture {
-			t.Error("frameCapture should be true after invoking enableFrameCapture")
+	t.Run("renderScreenshots should compose the pending screenshots", func(t *testing.T) {
+		ffmpeg, err := exec.LookPath("false")
+		if err != nil {
+			t.Skip("false is not available")
 		}
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+		v.Options.Screenshot.ffmpegPath = ffmpeg
+		v.Options.Screenshot.pending = []screenshot{{filepath.Join(t.TempDir(), "out.png"), "text.png", "cursor.png"}}
 
-		if opts.nextScreenshotPath != path {
-			t.Errorf("nextScreenshotPath: %s, expected: %s", opts.nextScreenshotPath, path)
+		errs := v.renderScreenshots()
+		if len(errs) != 1 || errs[0].Format != "Screenshot" || !strings.HasSuffix(errs[0].Output, "out.png") {
+			t.Errorf("expected the screenshot to fail to render, got %v", errs)
 		}
 	})
 }
+
+func TestScreenshotHideCursor(t *testing.T) {
+	page := testPage(t, `<canvas id="text" width="8" height="8"></canvas>
+		<canvas id="cursor" width="8" height="8"></canvas>
+		<script>
+		for (const id of ["text", "cursor"]) {
+			const ctx = document.getElementById(id).getContext("2d");
+			ctx.fillStyle = "red";
+			ctx.fillRect(0, 0, 8, 8);
+		}
+		</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+	v.TextCanvas = page.MustElement("#text")
+	v.CursorCanvas = page.MustElement("#cursor")
+	v.Options.Video.HideCursor = true
+	v.Options.Video.FrameFormat = frameJPEG
+	requireNoErr(t, os.MkdirAll(v.Options.Screenshot.input, os.ModePerm))
+
+	requireNoErr(t, v.Screenshot("out.png"))
+	s := v.Options.Screenshot.pending[0]
+	layer := func(path string) uint32 {
+		data, err := os.ReadFile(path)
+		requireNoErr(t, err)
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("expected %s to be a PNG image: %v", path, err)
+		}
+		_, _, _, a := img.At(0, 0).RGBA()
+		return a
+	}
+	if layer(s.text) == 0 {
+		t.Error("expected the text layer to be captured")
+	}
+	if layer(s.cursor) != 0 {
+		t.Error("expected the hidden cursor to leave a blank cursor layer")
+	}
+}
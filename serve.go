@@ -92,8 +92,8 @@ var serveCmd = &cobra.Command{
 						rand := rand.Int63n(maxNumber)
 						tempFile := filepath.Join(os.TempDir(), fmt.Sprintf("vhs-%d", rand))
 						defer func() { _ = os.Remove(tempFile) }()
-						errs := Evaluate(s.Context(), b.String(), s.Stderr(), func(v *VHS) {
-							var gif, mp4, webm string
+						errs := Evaluate(s.Context(), b.String(), "", s.Stderr(), func(v *VHS) {
+							var gif, mp4, webm, png, wp string
 							switch {
 							case v.Options.Video.Output.MP4 != "":
 								tempFile += mp4
@@ -101,6 +101,12 @@ var serveCmd = &cobra.Command{
 							case v.Options.Video.Output.WebM != "":
 								tempFile += webm
 								webm = tempFile
+							case v.Options.Video.Output.APNG != "":
+								tempFile += apng
+								png = tempFile
+							case v.Options.Video.Output.WebP != "":
+								tempFile += webp
+								wp = tempFile
 							default:
 								tempFile += gif
 								gif = tempFile
@@ -108,6 +114,8 @@ var serveCmd = &cobra.Command{
 							v.Options.Video.Output.GIF = gif
 							v.Options.Video.Output.MP4 = mp4
 							v.Options.Video.Output.WebM = webm
+							v.Options.Video.Output.APNG = png
+							v.Options.Video.Output.WebP = wp
 						})
 
 						if len(errs) > 0 {
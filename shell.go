@@ -1,5 +1,14 @@
 package main
 
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-rod/rod/lib/input"
+)
+
 // Supported shells of VHS
 const (
 	bash       = "bash"
@@ -11,6 +20,9 @@ const (
 	zsh        = "zsh"
 )
 
+// defaultPrompt is the prompt VHS configures for the supported shells.
+const defaultPrompt = "> "
+
 // Shell is a type that contains a prompt and the command to set up the shell.
 type Shell struct {
 	Command []string
@@ -20,11 +32,11 @@ type Shell struct {
 // Shells contains a mapping from shell names to their Shell struct.
 var Shells = map[string]Shell{
 	bash: {
-		Env:     []string{"PS1=\\[\\e[38;2;90;86;224m\\]> \\[\\e[0m\\]", "BASH_SILENCE_DEPRECATION_WARNING=1"},
+		Env:     []string{promptEnv(bash, defaultPrompt), "BASH_SILENCE_DEPRECATION_WARNING=1"},
 		Command: []string{"bash", "--noprofile", "--norc", "--login", "+o", "history"},
 	},
 	zsh: {
-		Env:     []string{`PROMPT=%F{#5B56E0}> %F{reset_color}`},
+		Env:     []string{promptEnv(zsh, defaultPrompt)},
 		Command: []string{"zsh", "--histnostore", "--no-rcs"},
 	},
 	fish: {
@@ -34,7 +46,7 @@ var Shells = map[string]Shell{
 			"--no-config",
 			"--private",
 			"-C", "function fish_greeting; end",
-			"-C", `function fish_prompt; set_color 5B56E0; echo -n "> "; set_color normal; end`,
+			"-C", configurePrompt(fish, defaultPrompt),
 		},
 	},
 	powershell: {
@@ -44,7 +56,7 @@ var Shells = map[string]Shell{
 			"-NoExit",
 			"-NoProfile",
 			"-Command",
-			`Set-PSReadLineOption -HistorySaveStyle SaveNothing; function prompt { Write-Host '>' -NoNewLine -ForegroundColor Blue; return ' ' }`,
+			`Set-PSReadLineOption -HistorySaveStyle SaveNothing; ` + configurePrompt(powershell, defaultPrompt),
 		},
 	},
 	pwsh: {
@@ -55,13 +67,115 @@ var Shells = map[string]Shell{
 			"-NoExit",
 			"-NoProfile",
 			"-Command",
-			`Set-PSReadLineOption -HistorySaveStyle SaveNothing; Function prompt { Write-Host -ForegroundColor Blue -NoNewLine '>'; return ' ' }`,
+			`Set-PSReadLineOption -HistorySaveStyle SaveNothing; ` + configurePrompt(pwsh, defaultPrompt),
 		},
 	},
 	cmdexe: {
-		Command: []string{"cmd.exe", "/k", "prompt=^> "},
+		Command: []string{"cmd.exe", "/k", configurePrompt(cmdexe, defaultPrompt)},
 	},
 	nushell: {
-		Command: []string{"nu", "--execute", "$env.PROMPT_COMMAND = {''}"},
+		Command: []string{"nu", "--execute", configurePrompt(nushell, defaultPrompt)},
 	},
 }
+
+// configurePrompt returns the shell specific command which sets the prompt
+// of the given shell to the given prompt, in the VHS accent color. Unknown
+// shells are assumed to be POSIX compatible.
+func configurePrompt(shell, prompt string) string {
+	switch shell {
+	case bash, zsh:
+		name, value, _ := strings.Cut(promptEnv(shell, prompt), "=")
+		return name + "=" + posixQuote(value)
+	case fish:
+		return fmt.Sprintf(`function fish_prompt; set_color 5B56E0; echo -n '%s'; set_color normal; end`,
+			strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(prompt))
+	case powershell, pwsh:
+		return fmt.Sprintf(`function prompt { Write-Host '%s' -NoNewLine -ForegroundColor Blue; return ' ' }`,
+			strings.ReplaceAll(strings.TrimSuffix(prompt, " "), "'", "''"))
+	case cmdexe:
+		return "prompt=" + strings.NewReplacer(">", "^>", "<", "^<", "|", "^|", "&", "^&").Replace(prompt)
+	case nushell:
+		return fmt.Sprintf(`$env.PROMPT_COMMAND = {''}; $env.PROMPT_INDICATOR = {%s}`, nuQuote(prompt))
+	default:
+		return "PS1=" + posixQuote(prompt)
+	}
+}
+
+// promptEnv returns the environment variable which sets the prompt of the
+// given shell (bash or zsh) to the given prompt, in the VHS accent color.
+func promptEnv(shell, prompt string) string {
+	if shell == zsh {
+		return fmt.Sprintf(`PROMPT=%%F{#5B56E0}%s%%F{reset_color}`, prompt)
+	}
+	return fmt.Sprintf(`PS1=\[\e[38;2;90;86;224m\]%s\[\e[0m\]`, prompt)
+}
+
+// posixQuote wraps the given string in single quotes, escaping any single
+// quotes within it.
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// nuQuote wraps the given string in nushell quotes. Single quoted strings
+// can't contain single quotes, so backticks are used in that case.
+func nuQuote(s string) string {
+	if strings.Contains(s, "'") {
+		return "`" + s + "`"
+	}
+	return "'" + s + "'"
+}
+
+// shellName returns the name of the given shell, e.g. bash, from its command.
+func shellName(s Shell) string {
+	if len(s.Command) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.Base(s.Command[0]), ".exe")
+}
+
+// cursorLineJS returns the line of the cursor in the buffer of the terminal,
+// which doesn't change as the lines scroll.
+const cursorLineJS = `() => term.buffer.active.baseY + term.buffer.active.cursorY`
+
+// removeLinesJS removes the lines of the screen from the given line of the
+// buffer up to the line of the cursor, moving the cursor's line and the ones
+// below it up in their place. Lines which scrolled off the screen are kept.
+const removeLinesJS = `(start) => {
+	const buffer = term.buffer.active;
+	const top = start - buffer.baseY;
+	const lines = buffer.baseY + buffer.cursorY - start;
+	if (top < 0 || lines <= 0) {
+		return;
+	}
+	term.write("\x1b7\x1b[" + (top + 1) + ";1H\x1b[" + lines + "M\x1b8\x1b[" + lines + "A");
+}`
+
+// setPrompt changes the prompt of the running shell to the Prompt, by typing
+// the command which sets it, and waits for the shell to show it. The command
+// is then removed from the screen, so that the new prompt takes the place of
+// the previous one. A WaitPattern matching the previous prompt is changed to
+// match the new one.
+func (vhs *VHS) setPrompt() error {
+	prompt := vhs.Options.Prompt
+	res, err := vhs.Page.Eval(cursorLineJS)
+	if err != nil {
+		return fmt.Errorf("could not set prompt: %w", err)
+	}
+	start := res.Value.Int()
+	typeLines(vhs, configurePrompt(shellName(vhs.Options.Shell), prompt), 0)
+	if err := vhs.Page.Keyboard.Type(input.Enter); err != nil {
+		return fmt.Errorf("could not set prompt: %w", err)
+	}
+	pattern := promptPattern(prompt)
+	if err := vhs.WaitPattern(regexp.MustCompile(pattern), vhs.Options.WaitTimeout); err != nil {
+		return fmt.Errorf("could not set prompt: %w", err)
+	}
+	if _, err := vhs.Page.Eval(removeLinesJS, start); err != nil {
+		return fmt.Errorf("could not set prompt: %w", err)
+	}
+	if vhs.prompt != "" && vhs.Options.WaitPattern == promptPattern(vhs.prompt) {
+		vhs.Options.WaitPattern = pattern
+	}
+	vhs.prompt = prompt
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestConfigurePrompt(t *testing.T) {
+	tests := []struct {
+		shell  string
+		prompt string
+		want   string
+	}{
+		{bash, defaultPrompt, `PS1='\[\e[38;2;90;86;224m\]> \[\e[0m\]'`},
+		{zsh, defaultPrompt, `PROMPT='%F{#5B56E0}> %F{reset_color}'`},
+		{fish, defaultPrompt, `function fish_prompt; set_color 5B56E0; echo -n '> '; set_color normal; end`},
+		{fish, `it's $ `, `function fish_prompt; set_color 5B56E0; echo -n 'it\'s $ '; set_color normal; end`},
+		{pwsh, defaultPrompt, `function prompt { Write-Host '>' -NoNewLine -ForegroundColor Blue; return ' ' }`},
+		{powershell, "it's>", `function prompt { Write-Host 'it''s>' -NoNewLine -ForegroundColor Blue; return ' ' }`},
+		{cmdexe, defaultPrompt, `prompt=^> `},
+		{nushell, defaultPrompt, `$env.PROMPT_COMMAND = {''}; $env.PROMPT_INDICATOR = {'> '}`},
+		{"ksh", "it's $ ", `PS1='it'\''s $ '`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.shell, func(t *testing.T) {
+			if got := configurePrompt(tc.shell, tc.prompt); got != tc.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPromptEnv(t *testing.T) {
+	if got, want := promptEnv(bash, defaultPrompt), "PS1=\\[\\e[38;2;90;86;224m\\]> \\[\\e[0m\\]"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := promptEnv(zsh, defaultPrompt), `PROMPT=%F{#5B56E0}> %F{reset_color}`; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestShellName(t *testing.T) {
+	tests := map[string]Shell{
+		bash:   Shells[bash],
+		cmdexe: Shells[cmdexe],
+		"ksh":  {Command: []string{"/bin/ksh"}},
+		"":     {},
+	}
+	for want, shell := range tests {
+		if got := shellName(shell); got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+}
+
+func TestRemoveLinesJS(t *testing.T) {
+	// A terminal which scrolled by 5 lines, with the cursor on the 8th line
+	// of the screen, and which records what's written to it.
+	page := testPage(t, `<script>
+		window.term = {
+			buffer: { active: { baseY: 5, cursorY: 7 } },
+			written: "",
+			write(data) { this.written += data },
+		};
+		</script>`)
+
+	if line := page.MustEval(cursorLineJS).Int(); line != 12 {
+		t.Errorf("expected the cursor on line 12 of the buffer, got %d", line)
+	}
+
+	// The 6th and 7th lines of the screen are removed.
+	page.MustEval(removeLinesJS, 10)
+	if written := page.MustEval("() => term.written").Str(); written != "\x1b7\x1b[6;1H\x1b[2M\x1b8\x1b[2A" {
+		t.Errorf("expected the lines to be removed, got %q", written)
+	}
+
+	// Lines which scrolled off the screen are kept.
+	page.MustEval("() => { term.written = '' }")
+	page.MustEval(removeLinesJS, 4)
+	if written := page.MustEval("() => term.written").Str(); written != "" {
+		t.Errorf("expected nothing to be removed, got %q", written)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// errUnterminatedQuote is returned by shellWords for a quote which isn't
+// closed.
+var errUnterminatedQuote = errors.New("unterminated quote")
+
+// shellWords splits the arguments into words like a POSIX shell does, so that
+// an argument with spaces can be quoted, e.g. `-vf "scale=320:-1, fps=10"`.
+// Single quotes keep everything up to the closing quote as is, while a
+// backslash escapes the next character outside of quotes, and a backslash, a
+// dollar sign, a backtick or a double quote inside double quotes.
+func shellWords(s string) ([]string, error) {
+	commands, err := splitShell(s, false)
+	if err != nil || len(commands) == 0 {
+		return nil, err
+	}
+	return commands[0], nil
+}
+
+// shellCommands splits the commands separated by semicolons into words, like
+// shellWords. Quoted or escaped semicolons are kept in the words, and empty
+// commands are left out.
+func shellCommands(s string) ([][]string, error) {
+	return splitShell(s, true)
+}
+
+// splitShell splits the string into words, and into commands on the
+// semicolons outside of quotes if semicolons is set.
+func splitShell(s string, semicolons bool) ([][]string, error) {
+	var (
+		commands [][]string
+		words    []string
+		word     strings.Builder
+		// inWord is set once a word starts, since "" is an empty word.
+		inWord bool
+		quote  rune
+		escape bool
+	)
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+	for _, r := range s {
+		switch {
+		case escape:
+			if quote == '"' && !strings.ContainsRune("\\$`\"", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escape = false
+		case r == '\\' && quote != '\'':
+			escape = true
+			inWord = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			endWord()
+		case r == ';' && semicolons:
+			endCommand()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escape {
+		return nil, errUnterminatedQuote
+	}
+	endCommand()
+	return commands, nil
+}
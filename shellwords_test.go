@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestShellWords(t *testing.T) {
+	for _, tc := range []struct {
+		args string
+		want []string
+	}{
+		{"", nil},
+		{"  -tune   animation ", []string{"-tune", "animation"}},
+		{`-vf "scale=320:-1, fps=10"`, []string{"-vf", "scale=320:-1, fps=10"}},
+		{`-metadata 'title=It\'s'`, nil},
+		{`-metadata 'title=a "b"' -c\ d`, []string{"-metadata", `title=a "b"`, "-c d"}},
+		{`"a \"b\" \c" '' x""y`, []string{`a "b" \c`, "", "xy"}},
+	} {
+		got, err := shellWords(tc.args)
+		if tc.want == nil && tc.args != "" {
+			if !errors.Is(err, errUnterminatedQuote) {
+				t.Errorf("expected %q to have an unterminated quote, got %q", tc.args, got)
+			}
+			continue
+		}
+		requireNoErr(t, err)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("expected %q to be split into %q, got %q", tc.args, tc.want, got)
+		}
+	}
+}
+
+func TestShellCommands(t *testing.T) {
+	got, err := shellCommands(`split-window -h; ; send-keys 'echo a; echo b' Enter\; ;`)
+	requireNoErr(t, err)
+	want := [][]string{{"split-window", "-h"}, {"send-keys", "echo a; echo b", "Enter;"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the commands %q, got %q", want, got)
+	}
+
+	// Semicolons are kept in the words of shellWords.
+	words, err := shellWords("a;b")
+	requireNoErr(t, err)
+	if !reflect.DeepEqual(words, []string{"a;b"}) {
+		t.Errorf("expected a single word, got %q", words)
+	}
+
+	if _, err := shellCommands(`send-keys "echo`); !errors.Is(err, errUnterminatedQuote) {
+		t.Errorf("expected an unterminated quote, got %v", err)
+	}
+}
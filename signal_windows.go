@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "os/exec"
+
+// detachInterrupt does nothing on Windows, where the command gets the Ctrl+C
+// of the console along with VHS.
+func detachInterrupt(_ *exec.Cmd) {}
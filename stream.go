@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/vhs/parser"
+	"github.com/charmbracelet/vhs/token"
+)
+
+// streamFile is the name of the outputs in the input directory while the
+// frames are streamed into ffmpeg. They are moved to the outputs once the
+// recording is rendered, since the outputs can change until then.
+const streamFile = "stream"
+
+// streamOutput is an ffmpeg process rendering an output from the frames piped
+// into its stdin.
+type streamOutput struct {
+	target videoTarget
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	log    bytes.Buffer
+	frames chan []byte
+	// writeErr is the error piping the frames, once ffmpeg stops reading
+	// them.
+	writeErr error
+	// err is the error of ffmpeg, once the stream is closed.
+	err *FFmpegError
+}
+
+// frameStream pipes the frames captured by Record into ffmpeg, for each of the
+// video outputs, rather than writing them to disk, so that long recordings
+// don't fill up the disk. Once the buffer of frames waiting to be piped is
+// full, capturing a frame blocks until there's room, like the frameWriter.
+type frameStream struct {
+	outputs []*streamOutput
+	wg      sync.WaitGroup
+}
+
+// newFrameStream starts ffmpeg for each video output.
+func newFrameStream(opts VideoOptions) (*frameStream, error) {
+	s := &frameStream{}
+	for _, target := range videoTargets(opts.Output) {
+		//nolint:gosec
+		cmd := exec.Command(opts.ffmpeg(), buildFFopts(opts, filepath.Join(opts.Input, streamFile+target.ext))...)
+		out := &streamOutput{
+			target: target,
+			cmd:    cmd,
+			frames: make(chan []byte, opts.captureFramerate()),
+		}
+		cmd.Stdout = &out.log
+		cmd.Stderr = &out.log
+		// ffmpeg finishes the outputs once the stream is closed, even when
+		// the recording is interrupted.
+		detachInterrupt(cmd)
+		stdin, err := cmd.StdinPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			s.kill()
+			return nil, fmt.Errorf("could not start ffmpeg to stream the %s: %w", target.format, err)
+		}
+		out.stdin = stdin
+		s.outputs = append(s.outputs, out)
+	}
+
+	s.wg.Add(len(s.outputs))
+	for _, out := range s.outputs {
+		out := out
+		go func() {
+			defer s.wg.Done()
+			for frame := range out.frames {
+				// Once ffmpeg stops reading, the frames are dropped
+				// so that the capture isn't held back.
+				if out.writeErr != nil {
+					continue
+				}
+				if _, err := out.stdin.Write(frame); err != nil {
+					out.writeErr = fmt.Errorf("error streaming frames to the %s: %w", out.target.format, err)
+				}
+			}
+		}()
+	}
+	return s, nil
+}
+
+// write queues the frame to be piped into each ffmpeg.
+func (s *frameStream) write(frame []byte) {
+	for _, out := range s.outputs {
+		out.frames <- frame
+	}
+}
+
+// close waits for the queued frames to be piped, and for ffmpeg to finish
+// rendering the outputs. It returns the errors piping the frames, while the
+// errors of ffmpeg are reported by renderStream.
+func (s *frameStream) close() []error {
+	for _, out := range s.outputs {
+		close(out.frames)
+	}
+	s.wg.Wait()
+
+	var errs []error
+	for _, out := range s.outputs {
+		_ = out.stdin.Close()
+		if err := out.cmd.Wait(); err != nil {
+			ffmpegErr := newFFmpegError(out.target.format, out.cmd, out.log.Bytes(), err)
+			out.err = &ffmpegErr
+		}
+		if out.writeErr != nil {
+			errs = append(errs, out.writeErr)
+		}
+	}
+	return errs
+}
+
+// kill stops the ffmpeg processes started so far.
+func (s *frameStream) kill() {
+	for _, out := range s.outputs {
+		_ = out.cmd.Process.Kill()
+		_ = out.cmd.Wait()
+	}
+}
+
+// startStream starts ffmpeg before recording, when the frames are streamed
+// into it.
+func (vhs *VHS) startStream() error {
+	if !vhs.Options.Video.StreamFrames {
+		return nil
+	}
+	if err := vhs.writeCaptions(); err != nil {
+		return err
+	}
+	stream, err := newFrameStream(vhs.Options.Video)
+	if err != nil {
+		return err
+	}
+	vhs.frameStream = stream
+	return nil
+}
+
+// renderStream moves the outputs rendered from the streamed frames to their
+// paths, and reports the outputs ffmpeg failed to render.
+func (vhs *VHS) renderStream() error {
+	paths := map[string]string{}
+	for _, target := range videoTargets(vhs.Options.Video.Output) {
+		paths[target.format] = target.path
+	}
+
+	progress := Progress{
+		Stage:   StageRendering,
+		Frames:  vhs.totalFrames,
+		Outputs: len(vhs.frameStream.outputs),
+	}
+	var renderErr RenderError
+	for _, out := range vhs.frameStream.outputs {
+		progress.Output = out.target.format
+		progress.Rendered++
+		vhs.reportProgress(progress)
+		if out.err != nil {
+			vhs.logMessage(out.err.Log)
+			renderErr.Errors = append(renderErr.Errors, *out.err)
+			continue
+		}
+		path, ok := paths[out.target.format]
+		if !ok {
+			continue
+		}
+		vhs.logStatus("Creating " + path + "...")
+		ensureDir(path)
+		if err := moveFile(filepath.Join(vhs.Options.Video.Input, streamFile+out.target.ext), path); err != nil {
+			return fmt.Errorf("could not create %s: %w", path, err)
+		}
+	}
+
+	renderErr.Errors = append(renderErr.Errors, vhs.renderScreenshots()...)
+
+	if vhs.Options.Video.Output.Cast != "" {
+		vhs.logStatus("Creating " + vhs.Options.Video.Output.Cast + "...")
+	}
+	if err := MakeCast(vhs.Options.Video); err != nil {
+		return err
+	}
+	if len(renderErr.Errors) > 0 {
+		return renderErr
+	}
+	return nil
+}
+
+// moveFile moves the file, copying it when it can't be renamed, e.g. from
+// the temporary directory to another file system.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// errStreamConflict is returned for the settings which need all the frames
+// once they are recorded, which streamed frames aren't kept for.
+var errStreamConflict = errors.New("can't be used with `Set StreamFrames`, since the frames aren't kept once they are streamed into ffmpeg")
+
+// checkStreaming returns an error for each setting, or command, which can't
+// be used with the frames streamed into ffmpeg as they are captured.
+func (vhs *VHS) checkStreaming(cmds []parser.Command) []error {
+	opts := vhs.Options.Video
+	if !opts.StreamFrames {
+		return nil
+	}
+
+	var errs []error
+	if vhs.Options.LoopOffset != 0 {
+		errs = append(errs, fmt.Errorf("`Set LoopOffset` moves the first frames to the end once they are all recorded, which %w", errStreamConflict))
+	}
+	for _, c := range []struct {
+		set     bool
+		setting string
+	}{
+		{opts.Boomerang, "Boomerang"},
+		{opts.TrimStart != Trim{}, "TrimStart"},
+		{opts.TrimEnd != Trim{}, "TrimEnd"},
+		{opts.SkipBlankFrames, "SkipBlankFrames"},
+		{opts.Dedup, "Dedup"},
+		{opts.VariableFramerate, "VariableFramerate"},
+		{opts.KeySound, "KeySound"},
+		{opts.Poster != "", "Poster"},
+	} {
+		if c.set {
+			errs = append(errs, fmt.Errorf("`Set %s` %w", c.setting, errStreamConflict))
+		}
+	}
+	if opts.Output.Frames != "" {
+		errs = append(errs, fmt.Errorf("`Output %s` %w", opts.Output.Frames, errStreamConflict))
+	}
+	for _, cmd := range cmds {
+		if cmd.Type == token.CAPTION {
+			errs = append(errs, fmt.Errorf("`Caption` %w", errStreamConflict))
+			break
+		}
+	}
+	return errs
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/vhs/parser"
+	"github.com/charmbracelet/vhs/token"
+)
+
+func TestBuildFFoptsStreamFrames(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.StreamFrames = true
+
+	args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+	if !strings.Contains(args, "-f image2pipe -r 50 -i - ") {
+		t.Errorf("expected the frames to be read from stdin, got: %s", args)
+	}
+	if strings.Contains(args, cursorFrameFormat) {
+		t.Errorf("expected the cursor to be drawn onto the frames, got: %s", args)
+	}
+}
+
+func TestFrameStream(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	// The fake ffmpeg writes the frames it reads to the output, which is
+	// its last argument.
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	requireNoErr(t, os.WriteFile(ffmpeg, []byte("#!/bin/sh\nfor out; do :; done\ncat > \"$out\"\n"), 0o755)) //nolint:gosec
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	dir := t.TempDir()
+	v.Options.Video.FFmpegPath = ffmpeg
+	v.Options.Video.StreamFrames = true
+	v.Options.Video.Output.MP4 = filepath.Join(dir, "out.mp4")
+	requireNoErr(t, v.startStream())
+
+	v.frameStream.write([]byte("frame 1\n"))
+	v.frameStream.write([]byte("frame 2\n"))
+	if errs := v.frameStream.close(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	// The output can change until it's rendered.
+	v.Options.Video.Output.MP4 = filepath.Join(dir, "final.mp4")
+	requireNoErr(t, v.Render())
+	bts, err := os.ReadFile(v.Options.Video.Output.MP4)
+	requireNoErr(t, err)
+	if string(bts) != "frame 1\nframe 2\n" {
+		t.Errorf("expected the frames to be streamed into ffmpeg, got %q", bts)
+	}
+}
+
+func TestFrameStreamFFmpegError(t *testing.T) {
+	ffmpeg, err := exec.LookPath("false")
+	if err != nil {
+		t.Skip("false is not available")
+	}
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.Video.FFmpegPath = ffmpeg
+	v.Options.Video.StreamFrames = true
+	v.Options.Video.Output.GIF = filepath.Join(t.TempDir(), "out.gif")
+	requireNoErr(t, v.startStream())
+	_ = v.frameStream.close()
+
+	var renderErr RenderError
+	if err := v.Render(); !errors.As(err, &renderErr) || renderErr.Errors[0].Format != "GIF" {
+		t.Errorf("expected the GIF to fail to render, got %v", err)
+	}
+}
+
+func TestCheckStreaming(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.LoopOffset = 50
+	v.Options.Video.Boomerang = true
+	caption := []parser.Command{{Type: token.CAPTION, Args: "Hello"}}
+
+	if errs := v.checkStreaming(caption); len(errs) > 0 {
+		t.Errorf("expected no errors without streaming, got %v", errs)
+	}
+
+	v.Options.Video.StreamFrames = true
+	errs := v.checkStreaming(caption)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "`Set LoopOffset`") || !errors.Is(errs[0], errStreamConflict) {
+		t.Errorf("expected LoopOffset to be rejected, got %v", errs[0])
+	}
+}
@@ -70,6 +70,17 @@ type StyleOptions struct {
 	WindowBarSize   int
 	WindowBarColor  string
 	BorderRadius    int
+	// Caption is drawn over the output while rendering, at the
+	// CaptionPosition (top or bottom) and in the CaptionColor. CaptionFont
+	// is a font family, or a font file.
+	Caption         string
+	CaptionPosition string
+	CaptionColor    string
+	CaptionFont     string
+
+	// windowBarSizeSet indicates whether the window bar size was explicitly
+	// set, in which case it no longer scales with the font size.
+	windowBarSizeSet bool
 }
 
 // DefaultStyleOptions returns default Style config.
@@ -85,5 +96,13 @@ func DefaultStyleOptions() *StyleOptions {
 		WindowBarColor:  DefaultTheme.Background,
 		BorderRadius:    0,
 		BackgroundColor: DefaultTheme.Background,
+		CaptionPosition: defaultCaptionPosition,
+		CaptionColor:    defaultCaptionColor,
 	}
 }
+
+// scaledWindowBarSize returns the window bar size for the given font size so
+// that the window bar keeps its proportions to the text.
+func scaledWindowBarSize(fontSize int) int {
+	return defaultWindowBarSize * fontSize / defaultFontSize
+}
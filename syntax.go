@@ -38,13 +38,30 @@ func Highlight(c parser.Command, faint bool) string {
 	case token.OUTPUT:
 		optionsStyle = NoneStyle
 		argsStyle = StringStyle
-	case token.CTRL:
-		argsStyle = CommandStyle
+	case token.ENV:
+		optionsStyle = NoneStyle
+		argsStyle = StringStyle
+	case token.CTRL, token.ALT, token.SHIFT:
+		if c.Type == token.CTRL {
+			argsStyle = CommandStyle
+		}
+		// The repeat count comes after the keys, i.e. Ctrl+C 3
+		if c.Options != "" {
+			return CommandStyle.Render(c.Type.String()) + " " + argsStyle.Render(c.Args) + " " + NumberStyle.Render(c.Options)
+		}
 	case token.SLEEP:
 		argsStyle = TimeStyle
-	case token.TYPE:
+	case token.TYPE, token.TYPE_FILE, token.WAIT, token.EXEC:
 		optionsStyle = TimeStyle
 		argsStyle = StringStyle
+	case token.CAPTION:
+		// The time comes after the text, i.e. Caption "text" 2s
+		if c.Options != "" {
+			return CommandStyle.Render(c.Type.String()) + " " + StringStyle.Render(c.Args) + " " + TimeStyle.Render(c.Options)
+		}
+		argsStyle = StringStyle
+	case token.ECHO:
+		argsStyle = KeywordStyle
 	case token.HIDE, token.SHOW:
 		return FaintStyle.Render(c.Type.String())
 	}
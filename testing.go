@@ -1,15 +1,23 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
 // TestOptions is the set of options for the testing functionality.
 type TestOptions struct {
 	Output string
+	// Golden is the file the ExpectedOutput is read from.
 	Golden string
+	// ExpectedOutput is the text the screen must show after the last
+	// command, or the tape fails. Trailing spaces and blank lines are
+	// ignored.
+	ExpectedOutput string
 }
 
 // DefaultTestOptions returns the default set of options for the testing functionality.
@@ -52,3 +60,60 @@ func (v *VHS) SaveOutput() {
 
 	_, _ = file.WriteString(separator + "\n")
 }
+
+// ErrUnexpectedOutput is returned when the screen doesn't show the expected
+// output after the last command.
+var ErrUnexpectedOutput = errors.New("the screen doesn't match the expected output")
+
+// checkExpectedOutput compares the screen with the expected output, if any.
+func (v *VHS) checkExpectedOutput() error {
+	if v.Options.Test.Golden == "" && v.Options.Test.ExpectedOutput == "" {
+		return nil
+	}
+	got, err := v.Buffer()
+	if err != nil {
+		return err
+	}
+	diff := diffScreens(strings.Split(v.Options.Test.ExpectedOutput, "\n"), got)
+	if diff == "" {
+		return nil
+	}
+	if v.Options.Test.Golden != "" {
+		return fmt.Errorf("%w in %s:\n%s", ErrUnexpectedOutput, v.Options.Test.Golden, diff)
+	}
+	return fmt.Errorf("%w:\n%s", ErrUnexpectedOutput, diff)
+}
+
+// diffScreens returns the lines which differ between the expected and actual
+// screens, ignoring trailing spaces and blank lines, or an empty string if
+// they match.
+func diffScreens(want, got []string) string {
+	want, got = trimScreen(want), trimScreen(got)
+	var diff strings.Builder
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w != g {
+			fmt.Fprintf(&diff, "line %d:\n  - %s\n  + %s\n", i+1, w, g)
+		}
+	}
+	return diff.String()
+}
+
+// trimScreen removes the trailing spaces of the lines, and the blank lines at
+// the end of the screen.
+func trimScreen(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(trimmed) > 0 && trimmed[len(trimmed)-1] == "" {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	return trimmed
+}
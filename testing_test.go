@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffScreens(t *testing.T) {
+	want := []string{"$ echo hello", "hello", ""}
+	if diff := diffScreens(want, []string{"$ echo hello  ", "hello", "", "", ""}); diff != "" {
+		t.Errorf("expected trailing spaces and blank lines to be ignored, got:\n%s", diff)
+	}
+
+	diff := diffScreens(want, []string{"$ echo hello", "goodbye", "$"})
+	for _, line := range []string{"line 2:\n  - hello\n  + goodbye", "line 3:\n  - \n  + $"} {
+		if !strings.Contains(diff, line) {
+			t.Errorf("expected the diff to contain %q, got:\n%s", line, diff)
+		}
+	}
+	if strings.Contains(diff, "line 1:") {
+		t.Errorf("expected the matching line not to be in the diff, got:\n%s", diff)
+	}
+}
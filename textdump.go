@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// textDumpFrameFormat is the name of the text of each frame, when the text is
+// dumped to a directory.
+const textDumpFrameFormat = "frame-%08d.txt"
+
+// textDump writes the text of the terminal for each frame captured, so that
+// the contents of the terminal can be compared without comparing pixels.
+//
+// A path ending with a slash, or without an extension, is a directory with a
+// file per frame. Otherwise, the frames are written one after the other to
+// the file, each followed by a separator like the test output.
+type textDump struct {
+	path string
+	file *os.File
+}
+
+// isDir returns whether the frames are written to a directory.
+func (d *textDump) isDir() bool {
+	return strings.HasSuffix(d.path, "/") || filepath.Ext(d.path) == ""
+}
+
+// write writes the lines of the terminal for the frame.
+func (d *textDump) write(frame int, lines []string) error {
+	text := strings.Join(lines, "\n") + "\n"
+
+	if d.isDir() {
+		if err := os.MkdirAll(d.path, os.ModePerm); err != nil {
+			return fmt.Errorf("could not create text dump directory: %w", err)
+		}
+		path := filepath.Join(d.path, fmt.Sprintf(textDumpFrameFormat, frame))
+		if err := os.WriteFile(path, []byte(text), os.ModePerm); err != nil {
+			return fmt.Errorf("could not write text dump: %w", err)
+		}
+		return nil
+	}
+
+	if d.file == nil {
+		ensureDir(d.path)
+		f, err := os.Create(d.path)
+		if err != nil {
+			return fmt.Errorf("could not create text dump: %w", err)
+		}
+		d.file = f
+	}
+	if _, err := d.file.WriteString(text + separator + "\n"); err != nil {
+		return fmt.Errorf("could not write text dump: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file the frames are written to, if any.
+func (d *textDump) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	return d.file.Close()
+}
+
+// closeTextDump closes the TextDump once the recording is over.
+func (vhs *VHS) closeTextDump() {
+	vhs.mutex.Lock()
+	defer vhs.mutex.Unlock()
+	if vhs.textDump != nil {
+		_ = vhs.textDump.Close()
+	}
+}
+
+// dumpText writes the text of the terminal for the frame to the TextDump, if
+// any.
+func (vhs *VHS) dumpText(frame int) error {
+	if vhs.Options.Video.TextDump == "" {
+		return nil
+	}
+	if vhs.textDump == nil {
+		vhs.textDump = &textDump{path: vhs.Options.Video.TextDump}
+	}
+	lines, err := vhs.Buffer()
+	if err != nil {
+		return err
+	}
+	return vhs.textDump.write(frame, lines)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTextDump(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dump", "frames.txt")
+		d := &textDump{path: path}
+		requireNoErr(t, d.write(1, []string{"$ echo hi", ""}))
+		requireNoErr(t, d.write(2, []string{"$ echo hi", "hi"}))
+		requireNoErr(t, d.Close())
+
+		bts, err := os.ReadFile(path)
+		requireNoErr(t, err)
+		want := "$ echo hi\n\n" + separator + "\n$ echo hi\nhi\n" + separator + "\n"
+		if string(bts) != want {
+			t.Errorf("expected the frames to be separated, got:\n%s", bts)
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "frames") + "/"
+		d := &textDump{path: dir}
+		requireNoErr(t, d.write(1, []string{"$ echo hi"}))
+		requireNoErr(t, d.write(2, []string{"$ echo hi", "hi"}))
+		requireNoErr(t, d.Close())
+
+		for frame, want := range map[int]string{1: "$ echo hi\n", 2: "$ echo hi\nhi\n"} {
+			bts, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf(textDumpFrameFormat, frame)))
+			requireNoErr(t, err)
+			if string(bts) != want {
+				t.Errorf("expected frame %d to be %q, got %q", frame, want, bts)
+			}
+		}
+	})
+}
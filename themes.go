@@ -6,6 +6,7 @@
 //
 // Set Theme {"background": "#171717"}
 // Set Theme "Catppuccin Mocha"
+// Set Theme "theme.json"
 //
 //go:generate make all
 package main
@@ -16,6 +17,8 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -27,28 +30,31 @@ import (
 // valid go struct.
 // https://xtermjs.org/docs/api/terminal/interfaces/itheme/
 type Theme struct {
-	Name          string `json:"name"`
-	Background    string `json:"background"`
-	Foreground    string `json:"foreground"`
-	Selection     string `json:"selection"`
-	Cursor        string `json:"cursor"`
-	CursorAccent  string `json:"cursorAccent"`
-	Black         string `json:"black"`
-	BrightBlack   string `json:"brightBlack"`
-	Red           string `json:"red"`
-	BrightRed     string `json:"brightRed"`
-	Green         string `json:"green"`
-	BrightGreen   string `json:"brightGreen"`
-	Yellow        string `json:"yellow"`
-	BrightYellow  string `json:"brightYellow"`
-	Blue          string `json:"blue"`
-	BrightBlue    string `json:"brightBlue"`
-	Magenta       string `json:"magenta"`
-	BrightMagenta string `json:"brightMagenta"`
-	Cyan          string `json:"cyan"`
-	BrightCyan    string `json:"brightCyan"`
-	White         string `json:"white"`
-	BrightWhite   string `json:"brightWhite"`
+	Name       string `json:"name"`
+	Background string `json:"background"`
+	Foreground string `json:"foreground"`
+	Selection  string `json:"selection"`
+	// SelectionBackground is the name of the selection color since xterm.js
+	// 5, when it is set it takes precedence over Selection.
+	SelectionBackground string `json:"selectionBackground,omitempty"`
+	Cursor              string `json:"cursor"`
+	CursorAccent        string `json:"cursorAccent"`
+	Black               string `json:"black"`
+	BrightBlack         string `json:"brightBlack"`
+	Red                 string `json:"red"`
+	BrightRed           string `json:"brightRed"`
+	Green               string `json:"green"`
+	BrightGreen         string `json:"brightGreen"`
+	Yellow              string `json:"yellow"`
+	BrightYellow        string `json:"brightYellow"`
+	Blue                string `json:"blue"`
+	BrightBlue          string `json:"brightBlue"`
+	Magenta             string `json:"magenta"`
+	BrightMagenta       string `json:"brightMagenta"`
+	Cyan                string `json:"cyan"`
+	BrightCyan          string `json:"brightCyan"`
+	White               string `json:"white"`
+	BrightWhite         string `json:"brightWhite"`
 }
 
 func (t Theme) String() string {
@@ -131,7 +137,7 @@ type ThemeNotFoundError struct {
 
 func (e ThemeNotFoundError) Error() string {
 	if len(e.Suggestions) == 0 {
-		return fmt.Sprintf("invalid `Set Theme %q`: theme does not exist", e.Theme)
+		return fmt.Sprintf("invalid `Set Theme %q`: theme does not exist, run `vhs themes` to list the available themes", e.Theme)
 	}
 
 	return fmt.Sprintf("invalid `Set Theme %q`: did you mean %q",
@@ -159,9 +165,20 @@ func sortedThemeNames() ([]string, error) {
 	return keys, nil
 }
 
+// Themes returns the names of the built-in themes, sorted case-insensitively.
+// Any of them can be used with `Set Theme`.
+func Themes() []string {
+	themes, err := sortedThemeNames()
+	if err != nil {
+		return nil
+	}
+	return themes
+}
+
 const distance = 2
 
-// findTheme return the given theme, if it exists.
+// findTheme return the given theme, if it exists. An exact match is preferred,
+// otherwise the name is matched case-insensitively.
 func findTheme(name string) (Theme, error) {
 	for _, bts := range [][]byte{themesBts} {
 		themes, err := parseThemes(bts)
@@ -174,6 +191,11 @@ func findTheme(name string) (Theme, error) {
 				return theme, nil
 			}
 		}
+		for _, theme := range themes {
+			if strings.EqualFold(theme.Name, name) {
+				return theme, nil
+			}
+		}
 	}
 
 	// not found, lets find similar themes!
@@ -196,6 +218,105 @@ func findTheme(name string) (Theme, error) {
 	return DefaultTheme, ThemeNotFoundError{name, suggestions}
 }
 
+// themeColor is a named color of a theme.
+type themeColor struct {
+	name  string
+	value string
+}
+
+// ansiColors returns the 16 ANSI colors of the theme, named as in JSON.
+func (t Theme) ansiColors() []themeColor {
+	return []themeColor{
+		{"black", t.Black},
+		{"red", t.Red},
+		{"green", t.Green},
+		{"yellow", t.Yellow},
+		{"blue", t.Blue},
+		{"magenta", t.Magenta},
+		{"cyan", t.Cyan},
+		{"white", t.White},
+		{"brightBlack", t.BrightBlack},
+		{"brightRed", t.BrightRed},
+		{"brightGreen", t.BrightGreen},
+		{"brightYellow", t.BrightYellow},
+		{"brightBlue", t.BrightBlue},
+		{"brightMagenta", t.BrightMagenta},
+		{"brightCyan", t.BrightCyan},
+		{"brightWhite", t.BrightWhite},
+	}
+}
+
+// colors returns all of the colors of the theme, named as in JSON.
+func (t Theme) colors() []themeColor {
+	return append([]themeColor{
+		{"background", t.Background},
+		{"foreground", t.Foreground},
+		{"selection", t.Selection},
+		{"selectionBackground", t.SelectionBackground},
+		{"cursor", t.Cursor},
+		{"cursorAccent", t.CursorAccent},
+	}, t.ansiColors()...)
+}
+
+// isHexColor returns whether the color is in the #RGB or #RRGGBB format.
+func isHexColor(s string) bool {
+	if len(s) != 4 && len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate makes sure that all of the colors set on the theme are valid hex
+// colors. Unset colors are left to xterm.js defaults.
+func (t Theme) Validate() error {
+	var invalid []string
+	for _, c := range t.colors() {
+		if c.value != "" && !isHexColor(c.value) {
+			invalid = append(invalid, fmt.Sprintf("%s %q", c.name, c.value))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid colors, expected #RGB or #RRGGBB: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// isThemeFile returns whether the theme refers to a JSON theme file, rather
+// than a theme name.
+func isThemeFile(s string) bool {
+	return strings.EqualFold(filepath.Ext(s), ".json")
+}
+
+// LoadTheme loads a theme from a JSON file in the same format as themes.json,
+// i.e. the xterm.js theme format. All of the 16 ANSI colors must be set.
+func LoadTheme(path string) (Theme, error) {
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return DefaultTheme, fmt.Errorf("could not read theme: %w", err)
+	}
+
+	var t Theme
+	if err := json.Unmarshal(bts, &t); err != nil {
+		return DefaultTheme, fmt.Errorf("could not parse theme %s: %w", path, err)
+	}
+
+	var missing []string
+	for _, c := range t.ansiColors() {
+		if c.value == "" {
+			missing = append(missing, c.name)
+		}
+	}
+	if len(missing) > 0 {
+		return DefaultTheme, fmt.Errorf("theme %s is missing colors: %s", path, strings.Join(missing, ", "))
+	}
+	return t, nil
+}
+
 func parseThemes(bts []byte) ([]Theme, error) {
 	var themes []Theme
 	if err := json.Unmarshal(bts, &themes); err != nil {
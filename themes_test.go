@@ -2,8 +2,11 @@ package main
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +21,23 @@ func TestFindAllThemes(t *testing.T) {
 	}
 }
 
+func TestThemes(t *testing.T) {
+	themes := Themes()
+	if len(themes) == 0 {
+		t.Fatal("expected built-in themes")
+	}
+	if !sort.SliceIsSorted(themes, func(i, j int) bool {
+		return strings.ToLower(themes[i]) < strings.ToLower(themes[j])
+	}) {
+		t.Error("expected themes to be sorted")
+	}
+	for _, name := range themes {
+		if _, err := findTheme(name); err != nil {
+			t.Errorf("expected theme %q to be found: %v", name, err)
+		}
+	}
+}
+
 func TestFindTheme(t *testing.T) {
 	tests := []struct {
 		tname string
@@ -29,6 +49,11 @@ func TestFindTheme(t *testing.T) {
 			theme: "Catppuccin Latte",
 			err:   nil,
 		},
+		{
+			tname: "case insensitive match",
+			theme: "dracula",
+			err:   nil,
+		},
 		{
 			tname: "match found",
 			theme: "caTppuccin ltt",
@@ -82,3 +107,61 @@ func TestFindTheme(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+	writeTheme := func(t *testing.T, theme Theme) string {
+		t.Helper()
+		path := filepath.Join(dir, t.Name()+".json")
+		requireNoErr(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		requireNoErr(t, os.WriteFile(path, []byte(theme.String()), os.ModePerm))
+		return path
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		theme := DefaultTheme
+		theme.Name = "Custom"
+		theme.Background = "#29283b"
+		path := writeTheme(t, theme)
+
+		got, err := LoadTheme(path)
+		requireNoErr(t, err)
+		if !reflect.DeepEqual(got, theme) {
+			t.Errorf("expected %+v, got %+v", theme, got)
+		}
+
+		got, err = getTheme(path)
+		requireNoErr(t, err)
+		if got.Name != "Custom" {
+			t.Errorf("expected Set Theme to load the file, got %+v", got)
+		}
+	})
+
+	t.Run("missing colors", func(t *testing.T) {
+		theme := DefaultTheme
+		theme.Red = ""
+		theme.BrightWhite = ""
+		_, err := LoadTheme(writeTheme(t, theme))
+		requireErr(t, err)
+		if !strings.HasSuffix(err.Error(), "is missing colors: red, brightWhite") {
+			t.Errorf("expected missing colors to be listed, got %q", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		theme, err := getTheme(filepath.Join(dir, "missing.json"))
+		requireErr(t, err)
+		requireDefaultTheme(t, theme)
+	})
+}
+
+func TestThemeValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		theme := Theme{Background: "#29283b", Red: "#ABC"}
+		requireNoErr(t, theme.Validate())
+	})
+	t.Run("invalid", func(t *testing.T) {
+		theme := Theme{Background: "#29283", Red: "red", Blue: "#abc", Green: "#12345g"}
+		requireEqualErr(t, theme.Validate(), `invalid colors, expected #RGB or #RRGGBB: background "#29283", red "red", green "#12345g"`)
+	})
+}
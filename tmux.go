@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tmuxSocket returns the name of the tmux server of the recording on the
+// port, so that the recording never shares the server of the user's own
+// sessions.
+func tmuxSocket(port int) string {
+	return fmt.Sprintf("vhs-%d", port)
+}
+
+// shellQuote quotes the arguments for a POSIX shell.
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// tmuxShell returns the shell run in a new tmux session, which the commands,
+// split by shellCommands, set up, e.g. "split-window -h ; select-pane -L".
+// The panes the commands open run the shell too.
+//
+// tmux doesn't read the user's configuration, so that the recording looks
+// the same everywhere, unless the commands source it. The session is
+// destroyed once ttyd, its only client, is gone.
+func tmuxShell(shell Shell, commands [][]string, socket string) Shell {
+	program := shellQuote(shell.Command)
+	args := []string{
+		"tmux", "-L", socket, "-f", os.DevNull,
+		"start-server", ";",
+		// The panes run the program with sh, whatever the user's shell is.
+		"set-option", "-g", "default-shell", "/bin/sh", ";",
+		"set-option", "-g", "default-command", program, ";",
+		"new-session", program, ";",
+		"set-option", "destroy-unattached", "on",
+	}
+	for _, command := range commands {
+		args = append(args, ";")
+		args = append(args, command...)
+	}
+	return Shell{Command: args, Env: shell.Env}
+}
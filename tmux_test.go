@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/vhs/parser"
+	"github.com/charmbracelet/vhs/token"
+)
+
+func TestTmuxIsStartSetting(t *testing.T) {
+	cmd := parser.Command{Type: token.SET, Options: "Tmux", Args: "split-window"}
+	if !isStartCommand(cmd) {
+		t.Error("expected Tmux to be applied before the terminal is started")
+	}
+}
+
+func TestTmuxShell(t *testing.T) {
+	shell := Shell{Command: []string{"bash", "--norc", "it's"}, Env: []string{"PS1=> "}}
+	commands, err := shellCommands(` split-window -h ;select-pane -L; display-message "a; b" `)
+	requireNoErr(t, err)
+	got := tmuxShell(shell, commands, "vhs-1234")
+
+	program := `'bash' '--norc' 'it'\''s'`
+	want := []string{
+		"tmux", "-L", "vhs-1234", "-f", os.DevNull,
+		"start-server", ";",
+		"set-option", "-g", "default-shell", "/bin/sh", ";",
+		"set-option", "-g", "default-command", program, ";",
+		"new-session", program, ";",
+		"set-option", "destroy-unattached", "on", ";",
+		"split-window", "-h", ";",
+		"select-pane", "-L", ";",
+		"display-message", "a; b",
+	}
+	if !reflect.DeepEqual(got.Command, want) {
+		t.Errorf("expected tmux command:\n%q\ngot:\n%q", want, got.Command)
+	}
+	if !reflect.DeepEqual(got.Env, shell.Env) {
+		t.Errorf("expected the environment of the shell, got %q", got.Env)
+	}
+}
@@ -55,99 +55,249 @@ const (
 	STRING  = "STRING"
 	JSON    = "JSON"
 	BOOLEAN = "BOOLEAN"
+	REGEX   = "REGEX"
 
 	DOWN  = "DOWN"
 	LEFT  = "LEFT"
 	RIGHT = "RIGHT"
 	UP    = "UP"
 
-	HIDE            = "HIDE"
-	OUTPUT          = "OUTPUT"
-	REQUIRE         = "REQUIRE"
-	SET             = "SET"
-	SHOW            = "SHOW"
-	SOURCE          = "SOURCE"
-	TYPE            = "TYPE"
-	SCREENSHOT      = "SCREENSHOT"
-	COPY            = "COPY"
-	PASTE           = "PASTE"
-	SHELL           = "SHELL"
-	FONT_FAMILY     = "FONT_FAMILY" //nolint:revive
-	FONT_SIZE       = "FONT_SIZE"   //nolint:revive
-	FRAMERATE       = "FRAMERATE"
-	PLAYBACK_SPEED  = "PLAYBACK_SPEED" //nolint:revive
-	HEIGHT          = "HEIGHT"
-	WIDTH           = "WIDTH"
-	LETTER_SPACING  = "LETTER_SPACING" //nolint:revive
-	LINE_HEIGHT     = "LINE_HEIGHT"    //nolint:revive
-	TYPING_SPEED    = "TYPING_SPEED"   //nolint:revive
-	PADDING         = "PADDING"
-	THEME           = "THEME"
-	LOOP_OFFSET     = "LOOP_OFFSET"     //nolint:revive
-	MARGIN_FILL     = "MARGIN_FILL"     //nolint:revive
-	MARGIN          = "MARGIN"          //nolint:revive
-	WINDOW_BAR      = "WINDOW_BAR"      //nolint:revive
-	WINDOW_BAR_SIZE = "WINDOW_BAR_SIZE" //nolint:revive
-	BORDER_RADIUS   = "CORNER_RADIUS"   //nolint:revive
-	CURSOR_BLINK    = "CURSOR_BLINK"    //nolint:revive
+	HIDE                   = "HIDE"
+	OUTPUT                 = "OUTPUT"
+	REQUIRE                = "REQUIRE"
+	SET                    = "SET"
+	SHOW                   = "SHOW"
+	SOURCE                 = "SOURCE"
+	TYPE                   = "TYPE"
+	SCREENSHOT             = "SCREENSHOT"
+	COPY                   = "COPY"
+	PASTE                  = "PASTE"
+	WAIT                   = "WAIT"
+	ENV                    = "ENV"
+	SELECT                 = "SELECT"
+	ECHO                   = "ECHO"
+	TYPE_FILE              = "TYPE_FILE" //nolint:revive
+	EXEC                   = "EXEC"
+	SHELL                  = "SHELL"
+	FONT_FAMILY            = "FONT_FAMILY" //nolint:revive
+	FONT_SIZE              = "FONT_SIZE"   //nolint:revive
+	FRAMERATE              = "FRAMERATE"
+	PLAYBACK_SPEED         = "PLAYBACK_SPEED" //nolint:revive
+	HEIGHT                 = "HEIGHT"
+	WIDTH                  = "WIDTH"
+	LETTER_SPACING         = "LETTER_SPACING" //nolint:revive
+	LINE_HEIGHT            = "LINE_HEIGHT"    //nolint:revive
+	TYPING_SPEED           = "TYPING_SPEED"   //nolint:revive
+	PADDING                = "PADDING"
+	THEME                  = "THEME"
+	LOOP_OFFSET            = "LOOP_OFFSET"         //nolint:revive
+	MARGIN_FILL            = "MARGIN_FILL"         //nolint:revive
+	MARGIN                 = "MARGIN"              //nolint:revive
+	WINDOW_BAR             = "WINDOW_BAR"          //nolint:revive
+	WINDOW_BAR_SIZE        = "WINDOW_BAR_SIZE"     //nolint:revive
+	BORDER_RADIUS          = "CORNER_RADIUS"       //nolint:revive
+	CURSOR_BLINK           = "CURSOR_BLINK"        //nolint:revive
+	CURSOR_BLINK_RATE      = "CURSOR_BLINK_RATE"   //nolint:revive
+	CURSOR_STYLE           = "CURSOR_STYLE"        //nolint:revive
+	CURSOR_COLOR           = "CURSOR_COLOR"        //nolint:revive
+	CURSOR_ACCENT_COLOR    = "CURSOR_ACCENT_COLOR" //nolint:revive
+	SELECTION_COLOR        = "SELECTION_COLOR"     //nolint:revive
+	CAPTION                = "CAPTION"
+	CAPTION_POSITION       = "CAPTION_POSITION"  //nolint:revive
+	CAPTION_COLOR          = "CAPTION_COLOR"     //nolint:revive
+	CAPTION_FONT           = "CAPTION_FONT"      //nolint:revive
+	CAPTURE_FRAMERATE      = "CAPTURE_FRAMERATE" //nolint:revive
+	TYPING_VARIANCE        = "TYPING_VARIANCE"   //nolint:revive
+	TYPING_SEED            = "TYPING_SEED"       //nolint:revive
+	WAIT_TIMEOUT           = "WAIT_TIMEOUT"      //nolint:revive
+	WAIT_PATTERN           = "WAIT_PATTERN"      //nolint:revive
+	PORT                   = "PORT"
+	WORKING_DIR            = "WORKING_DIR" //nolint:revive
+	FFMPEG_PATH            = "FFMPEG_PATH" //nolint:revive
+	FFMPEG_ARGS            = "FFMPEG_ARGS" //nolint:revive
+	CRF                    = "CRF"
+	BITRATE                = "BITRATE"
+	GIF_DITHER             = "GIF_DITHER"   //nolint:revive
+	GIF_COLORS             = "GIF_COLORS"   //nolint:revive
+	HIDE_CURSOR            = "HIDE_CURSOR"  //nolint:revive
+	MAX_DURATION           = "MAX_DURATION" //nolint:revive
+	TAB_SETTLE             = "TAB_SETTLE"   //nolint:revive
+	TERM_ROWS              = "TERM_ROWS"    //nolint:revive
+	TERM_COLS              = "TERM_COLS"    //nolint:revive
+	BOOMERANG              = "BOOMERANG"
+	THEME_DARK             = "THEME_DARK"  //nolint:revive
+	THEME_LIGHT            = "THEME_LIGHT" //nolint:revive
+	LOGGING                = "LOGGING"
+	SCALE                  = "SCALE"
+	DEBUG                  = "DEBUG"
+	HEADLESS               = "HEADLESS"
+	BROWSER_FLAGS          = "BROWSER_FLAGS" //nolint:revive
+	CONTROL_URL            = "CONTROL_URL"   //nolint:revive
+	TRIM_START             = "TRIM_START"    //nolint:revive
+	TRIM_END               = "TRIM_END"      //nolint:revive
+	COMMAND                = "COMMAND"
+	POSTER                 = "POSTER"
+	POSTER_FRAME           = "POSTER_FRAME"    //nolint:revive
+	PARALLEL_RENDER        = "PARALLEL_RENDER" //nolint:revive
+	TEXT_DUMP              = "TEXT_DUMP"       //nolint:revive
+	EXPECTED_OUTPUT        = "EXPECTED_OUTPUT" //nolint:revive
+	CROP                   = "CROP"
+	KEY_SOUND              = "KEY_SOUND" //nolint:revive
+	AUDIO                  = "AUDIO"
+	AUDIO_LOOP             = "AUDIO_LOOP"        //nolint:revive
+	SKIP_BLANK_FRAMES      = "SKIP_BLANK_FRAMES" //nolint:revive
+	BRACKETED_PASTE        = "BRACKETED_PASTE"   //nolint:revive
+	CLEANUP_WAIT           = "CLEANUP_WAIT"      //nolint:revive
+	TMUX                   = "TMUX"
+	BANNER                 = "BANNER"
+	BANNER_FILE            = "BANNER_FILE"     //nolint:revive
+	BANNER_DURATION        = "BANNER_DURATION" //nolint:revive
+	PROMPT                 = "PROMPT"
+	DEDUP                  = "DEDUP"
+	LOOP_COUNT             = "LOOP_COUNT"             //nolint:revive
+	PIXEL_FORMAT           = "PIXEL_FORMAT"           //nolint:revive
+	CAPTURE_QUALITY        = "CAPTURE_QUALITY"        //nolint:revive
+	FRAME_FORMAT           = "FRAME_FORMAT"           //nolint:revive
+	VARIABLE_FRAMERATE     = "VARIABLE_FRAMERATE"     //nolint:revive
+	STREAM_FRAMES          = "STREAM_FRAMES"          //nolint:revive
+	EXEC_CLEAR             = "EXEC_CLEAR"             //nolint:revive
+	FONT_FILE              = "FONT_FILE"              //nolint:revive
+	FONT_WEIGHT            = "FONT_WEIGHT"            //nolint:revive
+	FONT_WEIGHT_BOLD       = "FONT_WEIGHT_BOLD"       //nolint:revive
+	MINIMUM_CONTRAST_RATIO = "MINIMUM_CONTRAST_RATIO" //nolint:revive
 )
 
 // Keywords maps keyword strings to tokens.
 var Keywords = map[string]Type{
-	"em":            EM,
-	"px":            PX,
-	"ms":            MILLISECONDS,
-	"s":             SECONDS,
-	"m":             MINUTES,
-	"Set":           SET,
-	"Sleep":         SLEEP,
-	"Type":          TYPE,
-	"Enter":         ENTER,
-	"Space":         SPACE,
-	"Backspace":     BACKSPACE,
-	"Delete":        DELETE,
-	"Insert":        INSERT,
-	"Ctrl":          CTRL,
-	"Alt":           ALT,
-	"Shift":         SHIFT,
-	"Down":          DOWN,
-	"Left":          LEFT,
-	"Right":         RIGHT,
-	"Up":            UP,
-	"PageUp":        PAGEUP,
-	"PageDown":      PAGEDOWN,
-	"Tab":           TAB,
-	"Escape":        ESCAPE,
-	"End":           END,
-	"Hide":          HIDE,
-	"Require":       REQUIRE,
-	"Show":          SHOW,
-	"Output":        OUTPUT,
-	"Shell":         SHELL,
-	"FontFamily":    FONT_FAMILY,
-	"MarginFill":    MARGIN_FILL,
-	"Margin":        MARGIN,
-	"WindowBar":     WINDOW_BAR,
-	"WindowBarSize": WINDOW_BAR_SIZE,
-	"BorderRadius":  BORDER_RADIUS,
-	"FontSize":      FONT_SIZE,
-	"Framerate":     FRAMERATE,
-	"Height":        HEIGHT,
-	"LetterSpacing": LETTER_SPACING,
-	"LineHeight":    LINE_HEIGHT,
-	"PlaybackSpeed": PLAYBACK_SPEED,
-	"TypingSpeed":   TYPING_SPEED,
-	"Padding":       PADDING,
-	"Theme":         THEME,
-	"Width":         WIDTH,
-	"LoopOffset":    LOOP_OFFSET,
-	"Source":        SOURCE,
-	"CursorBlink":   CURSOR_BLINK,
-	"true":          BOOLEAN,
-	"false":         BOOLEAN,
-	"Screenshot":    SCREENSHOT,
-	"Copy":          COPY,
-	"Paste":         PASTE,
+	"em":                   EM,
+	"px":                   PX,
+	"ms":                   MILLISECONDS,
+	"s":                    SECONDS,
+	"m":                    MINUTES,
+	"Set":                  SET,
+	"Sleep":                SLEEP,
+	"Type":                 TYPE,
+	"Enter":                ENTER,
+	"Space":                SPACE,
+	"Backspace":            BACKSPACE,
+	"Delete":               DELETE,
+	"Insert":               INSERT,
+	"Ctrl":                 CTRL,
+	"Alt":                  ALT,
+	"Shift":                SHIFT,
+	"Down":                 DOWN,
+	"Left":                 LEFT,
+	"Right":                RIGHT,
+	"Up":                   UP,
+	"PageUp":               PAGEUP,
+	"PageDown":             PAGEDOWN,
+	"Tab":                  TAB,
+	"Escape":               ESCAPE,
+	"End":                  END,
+	"Home":                 HOME,
+	"Hide":                 HIDE,
+	"Require":              REQUIRE,
+	"Show":                 SHOW,
+	"Wait":                 WAIT,
+	"Env":                  ENV,
+	"Output":               OUTPUT,
+	"Shell":                SHELL,
+	"FontFamily":           FONT_FAMILY,
+	"MarginFill":           MARGIN_FILL,
+	"Margin":               MARGIN,
+	"WindowBar":            WINDOW_BAR,
+	"WindowBarSize":        WINDOW_BAR_SIZE,
+	"BorderRadius":         BORDER_RADIUS,
+	"FontSize":             FONT_SIZE,
+	"Logging":              LOGGING,
+	"Scale":                SCALE,
+	"Debug":                DEBUG,
+	"Headless":             HEADLESS,
+	"BrowserFlags":         BROWSER_FLAGS,
+	"ControlURL":           CONTROL_URL,
+	"TrimStart":            TRIM_START,
+	"TrimEnd":              TRIM_END,
+	"Command":              COMMAND,
+	"Poster":               POSTER,
+	"PosterFrame":          POSTER_FRAME,
+	"ParallelRender":       PARALLEL_RENDER,
+	"TextDump":             TEXT_DUMP,
+	"ExpectedOutput":       EXPECTED_OUTPUT,
+	"Crop":                 CROP,
+	"KeySound":             KEY_SOUND,
+	"Audio":                AUDIO,
+	"AudioLoop":            AUDIO_LOOP,
+	"SkipBlankFrames":      SKIP_BLANK_FRAMES,
+	"BracketedPaste":       BRACKETED_PASTE,
+	"CleanupWait":          CLEANUP_WAIT,
+	"Tmux":                 TMUX,
+	"Banner":               BANNER,
+	"BannerFile":           BANNER_FILE,
+	"BannerDuration":       BANNER_DURATION,
+	"Prompt":               PROMPT,
+	"Dedup":                DEDUP,
+	"LoopCount":            LOOP_COUNT,
+	"PixelFormat":          PIXEL_FORMAT,
+	"CaptureQuality":       CAPTURE_QUALITY,
+	"FrameFormat":          FRAME_FORMAT,
+	"VariableFramerate":    VARIABLE_FRAMERATE,
+	"StreamFrames":         STREAM_FRAMES,
+	"ExecClear":            EXEC_CLEAR,
+	"FontFile":             FONT_FILE,
+	"FontWeight":           FONT_WEIGHT,
+	"FontWeightBold":       FONT_WEIGHT_BOLD,
+	"MinimumContrastRatio": MINIMUM_CONTRAST_RATIO,
+	"Framerate":            FRAMERATE,
+	"Height":               HEIGHT,
+	"LetterSpacing":        LETTER_SPACING,
+	"LineHeight":           LINE_HEIGHT,
+	"PlaybackSpeed":        PLAYBACK_SPEED,
+	"TypingSpeed":          TYPING_SPEED,
+	"Padding":              PADDING,
+	"Theme":                THEME,
+	"Theme.Dark":           THEME_DARK,
+	"Theme.Light":          THEME_LIGHT,
+	"Width":                WIDTH,
+	"LoopOffset":           LOOP_OFFSET,
+	"Source":               SOURCE,
+	"CursorBlink":          CURSOR_BLINK,
+	"CursorBlinkRate":      CURSOR_BLINK_RATE,
+	"CursorStyle":          CURSOR_STYLE,
+	"CursorColor":          CURSOR_COLOR,
+	"CursorAccentColor":    CURSOR_ACCENT_COLOR,
+	"SelectionColor":       SELECTION_COLOR,
+	"Caption":              CAPTION,
+	"CaptionPosition":      CAPTION_POSITION,
+	"CaptionColor":         CAPTION_COLOR,
+	"CaptionFont":          CAPTION_FONT,
+	"CaptureFramerate":     CAPTURE_FRAMERATE,
+	"TypingVariance":       TYPING_VARIANCE,
+	"TypingSeed":           TYPING_SEED,
+	"WaitTimeout":          WAIT_TIMEOUT,
+	"WaitPattern":          WAIT_PATTERN,
+	"Port":                 PORT,
+	"WorkingDir":           WORKING_DIR,
+	"FFmpegPath":           FFMPEG_PATH,
+	"FFmpegArgs":           FFMPEG_ARGS,
+	"CRF":                  CRF,
+	"Bitrate":              BITRATE,
+	"GIFDither":            GIF_DITHER,
+	"GIFColors":            GIF_COLORS,
+	"HideCursor":           HIDE_CURSOR,
+	"MaxDuration":          MAX_DURATION,
+	"TabSettle":            TAB_SETTLE,
+	"TermRows":             TERM_ROWS,
+	"TermCols":             TERM_COLS,
+	"Boomerang":            BOOMERANG,
+	"true":                 BOOLEAN,
+	"false":                BOOLEAN,
+	"Screenshot":           SCREENSHOT,
+	"Copy":                 COPY,
+	"Select":               SELECT,
+	"Echo":                 ECHO,
+	"TypeFile":             TYPE_FILE,
+	"Exec":                 EXEC,
+	"Paste":                PASTE,
 }
 
 // IsSetting returns whether a token is a setting.
@@ -156,7 +306,21 @@ func IsSetting(t Type) bool {
 	case SHELL, FONT_FAMILY, FONT_SIZE, LETTER_SPACING, LINE_HEIGHT,
 		FRAMERATE, TYPING_SPEED, THEME, PLAYBACK_SPEED, HEIGHT, WIDTH,
 		PADDING, LOOP_OFFSET, MARGIN_FILL, MARGIN, WINDOW_BAR,
-		WINDOW_BAR_SIZE, BORDER_RADIUS, CURSOR_BLINK:
+		WINDOW_BAR_SIZE, BORDER_RADIUS, CURSOR_BLINK, CURSOR_BLINK_RATE,
+		CURSOR_STYLE, CURSOR_COLOR, CURSOR_ACCENT_COLOR, SELECTION_COLOR,
+		CAPTION, CAPTION_POSITION, CAPTION_COLOR, CAPTION_FONT, CAPTURE_FRAMERATE, TYPING_VARIANCE, TYPING_SEED,
+		WAIT_TIMEOUT, WAIT_PATTERN, PORT, WORKING_DIR, FFMPEG_PATH,
+		FFMPEG_ARGS, CRF, BITRATE, GIF_DITHER, GIF_COLORS, HIDE_CURSOR,
+		MAX_DURATION, TAB_SETTLE, TERM_ROWS, TERM_COLS,
+		BOOMERANG, THEME_DARK, THEME_LIGHT, FONT_FILE, FONT_WEIGHT, FONT_WEIGHT_BOLD,
+		MINIMUM_CONTRAST_RATIO, LOGGING, SCALE, DEBUG, HEADLESS, BROWSER_FLAGS,
+		CONTROL_URL, TRIM_START, TRIM_END, COMMAND, POSTER, POSTER_FRAME,
+		PARALLEL_RENDER, TEXT_DUMP, EXPECTED_OUTPUT, CROP, KEY_SOUND,
+		AUDIO, AUDIO_LOOP, SKIP_BLANK_FRAMES, BRACKETED_PASTE,
+		CLEANUP_WAIT, TMUX, BANNER, BANNER_FILE, BANNER_DURATION,
+		PROMPT, DEDUP, LOOP_COUNT, PIXEL_FORMAT,
+		CAPTURE_QUALITY, FRAME_FORMAT, VARIABLE_FRAMERATE, STREAM_FRAMES,
+		EXEC_CLEAR:
 		return true
 	default:
 		return false
@@ -169,7 +333,8 @@ func IsCommand(t Type) bool {
 	case TYPE, SLEEP,
 		UP, DOWN, RIGHT, LEFT, PAGEUP, PAGEDOWN,
 		ENTER, BACKSPACE, DELETE, TAB,
-		ESCAPE, HOME, INSERT, END, CTRL, SOURCE, SCREENSHOT, COPY, PASTE:
+		ESCAPE, HOME, INSERT, END, CTRL, SOURCE, SCREENSHOT, COPY, PASTE,
+		WAIT, ENV, SELECT, CAPTION, ECHO, TYPE_FILE, EXEC:
 		return true
 	default:
 		return false
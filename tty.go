@@ -10,12 +10,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"sort"
 )
 
+var errTtydNotFound = errors.New("ttyd not found in PATH, install it from: https://github.com/tsl0922/ttyd")
+
 // randomPort returns a random port number that is not in use.
 func randomPort() int {
 	addr, _ := net.Listen("tcp", ":0") //nolint:gosec
@@ -23,8 +27,18 @@ func randomPort() int {
 	return addr.Addr().(*net.TCPAddr).Port
 }
 
-// buildTtyCmd builds the ttyd exec.Command on the given port.
-func buildTtyCmd(port int, shell Shell) *exec.Cmd {
+// checkPort returns an error if the given port is already in use.
+func checkPort(port int) error {
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return fmt.Errorf("port %d is already in use: %w", port, err)
+	}
+	return l.Close()
+}
+
+// buildTtyCmd builds the ttyd exec.Command on the given port. The env
+// variables are added to the environment of the shell.
+func buildTtyCmd(port int, shell Shell, env map[string]string) *exec.Cmd {
 	args := []string{
 		fmt.Sprintf("--port=%d", port),
 		"--interface", "127.0.0.1",
@@ -40,8 +54,19 @@ func buildTtyCmd(port int, shell Shell) *exec.Cmd {
 
 	//nolint:gosec
 	cmd := exec.Command("ttyd", args...)
-	if shell.Env != nil {
-		cmd.Env = append(shell.Env, os.Environ()...)
+	if shell.Env != nil || len(env) > 0 {
+		cmd.Env = append(append([]string{}, shell.Env...), os.Environ()...)
+
+		// Later entries take precedence, so the tape's variables override
+		// the ones inherited from the environment.
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+env[k])
+		}
 	}
 	return cmd
 }
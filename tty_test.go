@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCheckPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+
+	if err := checkPort(port); err == nil {
+		t.Errorf("expected an error for port %d which is in use", port)
+	}
+
+	_ = l.Close()
+	if err := checkPort(port); err != nil {
+		t.Errorf("expected port %d to be free, got %v", port, err)
+	}
+}
+
+func TestStartWithoutTtyd(t *testing.T) {
+	t.Setenv("PATH", "")
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+
+	if err := v.Start(); !errors.Is(err, errTtydNotFound) {
+		t.Fatalf("expected errTtydNotFound, got %v", err)
+	}
+}
+
+func TestBuildTtyCmdEnv(t *testing.T) {
+	cmd := buildTtyCmd(7681, Shells[bash], map[string]string{
+		"GREETING": `Hello, "world" it's me`,
+	})
+
+	want := `GREETING=Hello, "world" it's me`
+	if got := cmd.Env[len(cmd.Env)-1]; got != want {
+		t.Errorf("expected %q to be the last env entry, got %q", want, got)
+	}
+	if cmd.Env[0] != Shells[bash].Env[0] {
+		t.Errorf("expected the shell env to be kept, got %q", cmd.Env[0])
+	}
+}
+
+func TestRecordStopsWhenShellExits(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.exited = make(chan struct{})
+	close(v.exited)
+
+	ch := v.Record(context.Background())
+	if err := <-ch; !errors.Is(err, ErrTerminalExited) {
+		t.Fatalf("expected ErrTerminalExited, got %v", err)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed")
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "time"
+
+// typingDelay returns how long to wait after a keystroke typed at the given
+// speed. When TypingVariance is set, the delay is randomly varied around the
+// speed to mimic human typing.
+func (vhs *VHS) typingDelay(speed time.Duration) time.Duration {
+	if vhs.Options.TypingVariance <= 0 || vhs.typingRand == nil {
+		return speed
+	}
+
+	jitter := (vhs.typingRand.Float64()*2 - 1) * vhs.Options.TypingVariance
+	return time.Duration(float64(speed) * (1 + jitter))
+}
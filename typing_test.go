@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/vhs/parser"
+)
+
+func TestTypingDelay(t *testing.T) {
+	speed := 100 * time.Millisecond
+
+	t.Run("no variance", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+		for i := 0; i < 10; i++ {
+			if d := v.typingDelay(speed); d != speed {
+				t.Fatalf("expected %s, got %s", speed, d)
+			}
+		}
+	})
+
+	t.Run("bounded variance", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+		ExecuteSetTypingVariance(parser.Command{Args: "0.3"}, &v)
+		if len(v.Errors) != 0 {
+			t.Fatalf("expected no errors, got %v", v.Errors)
+		}
+		for i := 0; i < 100; i++ {
+			d := v.typingDelay(speed)
+			if d < 70*time.Millisecond || d > 130*time.Millisecond {
+				t.Fatalf("delay %s out of bounds", d)
+			}
+		}
+	})
+
+	t.Run("seeded", func(t *testing.T) {
+		delays := func() []time.Duration {
+			v := New()
+			t.Cleanup(func() { _ = v.Cleanup() })
+			ExecuteSetTypingVariance(parser.Command{Args: "0.5"}, &v)
+			ExecuteSetTypingSeed(parser.Command{Args: "42"}, &v)
+			if len(v.Errors) != 0 {
+				t.Fatalf("expected no errors, got %v", v.Errors)
+			}
+			var ds []time.Duration
+			for i := 0; i < 10; i++ {
+				ds = append(ds, v.typingDelay(speed))
+			}
+			return ds
+		}
+		a, b := delays(), delays()
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("expected seeded delays to match: %v != %v", a, b)
+			}
+		}
+	})
+
+	t.Run("invalid variance", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+		ExecuteSetTypingVariance(parser.Command{Args: "2"}, &v)
+		if len(v.Errors) != 1 {
+			t.Fatalf("expected an error for an invalid variance, got %v", v.Errors)
+		}
+	})
+}
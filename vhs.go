@@ -2,19 +2,23 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"log"
 	"math"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
 	"github.com/go-rod/rod/lib/proto"
 )
 
@@ -30,34 +34,170 @@ type VHS struct {
 	started      bool
 	recording    bool
 	tty          *exec.Cmd
-	totalFrames  int
+	// prompt is the prompt shown by the shell, as captured during Setup.
+	prompt string
+	// exited is closed when ttyd exits, i.e. when the program run in the
+	// terminal exits.
+	exited chan struct{}
+	// interrupt is closed once the tape is interrupted, e.g. on Ctrl+C, so
+	// that the Sleep and Wait commands stop right away.
+	interrupt   <-chan struct{}
+	totalFrames int
+	castStart   time.Time
+	typingRand  *rand.Rand
+	// clipboard holds the text of the last Copy, in case the system
+	// clipboard isn't available.
+	clipboard string
+	// frameSize is the width and height of the first frame captured, which
+	// all the frames keep, even once a live setting, e.g. FontSize, changes
+	// the size of the canvases.
+	frameSize []int
+	// frameTimes are the times the frames were captured at, since the
+	// recording started, leaving out the time the recording was paused for.
+	frameTimes []time.Duration
+	// frameWriter writes the frames to disk in the background while
+	// recording.
+	frameWriter *frameWriter
+	// frameStream pipes the frames into ffmpeg while recording, with
+	// StreamFrames, and holds the outputs it rendered until Render.
+	frameStream *frameStream
+	// textDump writes the text of each frame to the TextDump, once the first
+	// frame is captured.
+	textDump *textDump
+	// keystrokes are the frames on which keys were pressed, for KeySound.
+	keystrokes []int
+	// captions are the captions shown over the recorded frames, in the order
+	// they were added by Caption commands.
+	captions []caption
+	// themeVariant is the theme variant being recorded, i.e. dark or light,
+	// when the tape sets Theme.Dark or Theme.Light.
+	themeVariant string
+	// progress is notified of the progress of the recording, if set.
+	progress func(Progress)
+	// closeBrowser closes the browser, or only the page when connected to
+	// a remote browser.
+	closeBrowser func() error
 	close        func() error
 }
 
 // Options is the set of options for the setup.
 type Options struct {
-	Shell         Shell
+	Shell Shell
+	// Prompt is the prompt of the shell, instead of the default one, which a
+	// Set Prompt during the recording changes in the running shell.
+	Prompt string
+	// Tmux runs the shell, or the Command, in a tmux session which the tmux
+	// commands, each split into its words, set up, e.g. to split it into
+	// panes.
+	Tmux [][]string
+	// Command is the program run in the terminal instead of the shell, e.g.
+	// a REPL. Like the shell, the recording stops when it exits.
+	Command       []string
 	FontFamily    string
 	FontSize      int
 	LetterSpacing float64
 	LineHeight    float64
-	TypingSpeed   time.Duration
-	Theme         Theme
-	Test          TestOptions
-	Video         VideoOptions
-	LoopOffset    float64
-	CursorBlink   bool
-	Screenshot    ScreenshotOptions
-	Style         StyleOptions
+	// FontFile is a font file (or URL) which is loaded into the terminal and
+	// used before FontFamily, so that the font doesn't need to be installed.
+	FontFile string
+	// FontWeight and FontWeightBold are the weights of normal and bold text,
+	// i.e. normal, bold, or 100 to 900.
+	FontWeight     string
+	FontWeightBold string
+	// MinimumContrastRatio makes xterm.js adjust the text colors to meet the
+	// contrast ratio with the background, from 1 (no change) to 21.
+	MinimumContrastRatio float64
+	TypingSpeed          time.Duration
+	// TypingVariance randomly varies the delay between keystrokes by up to
+	// the given fraction of TypingSpeed (0-1).
+	TypingVariance float64
+	// BracketedPaste pastes the text typed over several lines at once, when
+	// the program in the terminal supports bracketed paste, so that a shell
+	// doesn't run the lines one by one. It's off by default, and every line
+	// is typed.
+	BracketedPaste bool
+	// ExecClear clears the screen after an Exec, before the recording
+	// resumes.
+	ExecClear   bool
+	Theme       Theme
+	Test        TestOptions
+	Video       VideoOptions
+	LoopOffset  float64
+	CursorBlink bool
+	// Banner is shown above the prompt when the recording starts, for
+	// BannerDuration before the first command.
+	Banner         string
+	BannerDuration time.Duration
+	// CleanupWait is the longest time the commands run last are given to
+	// finish once the tape is over, before the terminal is closed. Zero
+	// closes it right away.
+	CleanupWait time.Duration
+	// CursorBlinkRate is the duration the cursor stays visible (and hidden)
+	// while blinking. When zero, xterm.js' own blinking is used.
+	CursorBlinkRate time.Duration
+	CursorStyle     string
+	// CursorColor and CursorAccentColor override the cursor colors of the
+	// theme. The accent color is used for the character under a block
+	// cursor.
+	CursorColor       string
+	CursorAccentColor string
+	// SelectionColor overrides the background color of selected text of the
+	// theme.
+	SelectionColor string
+	// WaitTimeout is how long Wait commands wait for the terminal to match
+	// before failing.
+	WaitTimeout time.Duration
+	// WaitPattern is the regular expression a Wait command without a pattern
+	// waits for. When empty, it is set to the shell prompt during Setup.
+	WaitPattern string
+	// TermRows and TermCols set the size of the terminal. When zero, the
+	// terminal is fit to the window instead.
+	TermRows int
+	TermCols int
+	// TabSettle is how long to wait after each Tab key press, giving the
+	// shell time to render its completions.
+	TabSettle time.Duration
+	// MaxDuration stops the recording once it has run for the given
+	// duration. When zero, there is no limit.
+	MaxDuration time.Duration
+	// Logging is the format of the logs, text or json.
+	Logging string
+	// Env holds the environment variables set in the shell.
+	Env map[string]string
+	// WorkingDir is the directory the shell starts in.
+	WorkingDir string
+	// Port is the port ttyd listens on. When zero, a random port is used.
+	Port int
+	// Debug shows the browser window, and leaves it and the terminal open
+	// when VHS is done, to inspect the page.
+	Debug bool
+	// Headless runs the browser without a window. Set it to false to watch
+	// the recording live.
+	Headless bool
+	// BrowserFlags are extra command-line flags for the browser, e.g.
+	// --no-sandbox to run as root in Docker.
+	BrowserFlags []string
+	// ControlURL is the DevTools URL of a running browser to use, instead
+	// of launching one. The browser must be able to reach ttyd on
+	// localhost.
+	ControlURL string
+	Screenshot ScreenshotOptions
+	Style      StyleOptions
 }
 
 const (
 	defaultFontSize      = 22
 	defaultTypingSpeed   = 50 * time.Millisecond
+	defaultCleanupWait   = 100 * time.Millisecond
 	defaultLineHeight    = 1.0
 	defaultLetterSpacing = 1.0
 	fontsSeparator       = ","
 	defaultCursorBlink   = true
+	defaultCursorStyle   = "block"
+	// The defaults of xterm.js.
+	defaultFontWeight           = "normal"
+	defaultFontWeightBold       = "bold"
+	defaultMinimumContrastRatio = 1.0
 )
 
 var defaultFontFamily = withSymbolsFallback(strings.Join([]string{
@@ -81,6 +221,68 @@ func withSymbolsFallback(font string) string {
 	return font + fontsSeparator + strings.Join(symbolsFallback, fontsSeparator)
 }
 
+// fontFileFamily is the font family name of the font loaded from FontFile.
+const fontFileFamily = "VHS FontFile"
+
+// fontFormats maps the supported font file extensions to their media types.
+var fontFormats = map[string]string{
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// isURL returns whether the path is an HTTP(S) URL rather than a file.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// checkFontFile makes sure the font file is in a supported format and, unless
+// it is a URL, that it exists.
+func checkFontFile(path string) error {
+	if _, ok := fontFormats[strings.ToLower(filepath.Ext(path))]; !ok {
+		return errors.New("expected a .ttf, .otf, .woff, or .woff2 font")
+	}
+	if isURL(path) {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("could not find font: %w", err)
+	}
+	return nil
+}
+
+// fontFileSource returns the source of the font file for the browser. Local
+// files are inlined as data URLs, since the terminal page can't access them.
+func fontFileSource(path string) (string, error) {
+	if isURL(path) {
+		return path, nil
+	}
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read font: %w", err)
+	}
+	mediaType := fontFormats[strings.ToLower(filepath.Ext(path))]
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(bts), nil
+}
+
+// loadFontFile adds the font from FontFile to the page, and waits for it to be
+// loaded so that the terminal measures the right font.
+func (vhs *VHS) loadFontFile() error {
+	src, err := fontFileSource(vhs.Options.FontFile)
+	if err != nil {
+		return err
+	}
+	_, err = vhs.Page.Eval(`async (family, src) => {
+		const font = new FontFace(family, "url(" + src + ")");
+		document.fonts.add(await font.load());
+	}`, fontFileFamily, src)
+	if err != nil {
+		return fmt.Errorf("could not load font %s: %w", vhs.Options.FontFile, err)
+	}
+	return nil
+}
+
 // DefaultVHSOptions returns the default set of options to use for the setup function.
 func DefaultVHSOptions() Options {
 	style := DefaultStyleOptions()
@@ -89,31 +291,43 @@ func DefaultVHSOptions() Options {
 	screenshot := NewScreenshotOptions(video.Input, style)
 
 	return Options{
-		FontFamily:    defaultFontFamily,
-		FontSize:      defaultFontSize,
-		LetterSpacing: defaultLetterSpacing,
-		LineHeight:    defaultLineHeight,
-		TypingSpeed:   defaultTypingSpeed,
-		Shell:         Shells[defaultShell],
-		Theme:         DefaultTheme,
-		CursorBlink:   defaultCursorBlink,
-		Video:         video,
-		Screenshot:    screenshot,
+		FontFamily:           defaultFontFamily,
+		FontSize:             defaultFontSize,
+		LetterSpacing:        defaultLetterSpacing,
+		LineHeight:           defaultLineHeight,
+		FontWeight:           defaultFontWeight,
+		FontWeightBold:       defaultFontWeightBold,
+		MinimumContrastRatio: defaultMinimumContrastRatio,
+		TypingSpeed:          defaultTypingSpeed,
+		CleanupWait:          defaultCleanupWait,
+		Shell:                Shells[defaultShell],
+		Theme:                DefaultTheme,
+		CursorBlink:          defaultCursorBlink,
+		CursorStyle:          defaultCursorStyle,
+		WaitTimeout:          defaultWaitTimeout,
+		Logging:              logText,
+		Headless:             true,
+		Video:                video,
+		Screenshot:           screenshot,
 	}
 }
 
-// New sets up ttyd and go-rod for recording frames.
+// New returns a VHS instance with the default options. Start launches ttyd
+// and the browser used for recording frames.
 func New() VHS {
 	mu := &sync.Mutex{}
 	opts := DefaultVHSOptions()
 	return VHS{
-		Options:   &opts,
-		recording: true,
-		mutex:     mu,
+		Options:    &opts,
+		recording:  true,
+		mutex:      mu,
+		typingRand: rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
 	}
 }
 
 // Start starts ttyd, browser and everything else needed to create the gif.
+// It returns an error, rather than panicking, if ttyd or the browser can't be
+// started.
 func (vhs *VHS) Start() error {
 	vhs.mutex.Lock()
 	defer vhs.mutex.Unlock()
@@ -122,85 +336,299 @@ func (vhs *VHS) Start() error {
 		return fmt.Errorf("vhs is already started")
 	}
 
-	port := randomPort()
-	vhs.tty = buildTtyCmd(port, vhs.Options.Shell)
+	port := vhs.Options.Port
+	if port == 0 {
+		port = randomPort()
+	} else if err := checkPort(port); err != nil {
+		return err
+	}
+
+	shell := vhs.Options.Shell
+	if len(vhs.Options.Command) > 0 {
+		// Run the program directly, without a shell around it.
+		shell = Shell{Command: vhs.Options.Command}
+	}
+	if len(vhs.Options.Tmux) > 0 {
+		shell = tmuxShell(shell, vhs.Options.Tmux, tmuxSocket(port))
+	}
+	vhs.tty = buildTtyCmd(port, shell, vhs.Options.Env)
+	vhs.tty.Dir = vhs.Options.WorkingDir
 	if err := vhs.tty.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return errTtydNotFound
+		}
 		return fmt.Errorf("could not start tty: %w", err)
 	}
+	// ttyd only accepts one connection, so it exits along with the program
+	// run in the terminal.
+	vhs.exited = make(chan struct{})
+	go func() {
+		_ = vhs.tty.Wait()
+		close(vhs.exited)
+	}()
 
-	path, _ := launcher.LookPath()
-	enableNoSandbox := os.Getenv("VHS_NO_SANDBOX") != ""
-	u, err := launcher.New().Leakless(false).Bin(path).NoSandbox(enableNoSandbox).Launch()
-	if err != nil {
-		return fmt.Errorf("could not launch browser: %w", err)
+	// Connect to the given browser, or launch one.
+	u := vhs.Options.ControlURL
+	if u == "" {
+		var err error
+		u, err = vhs.launchBrowser()
+		if err != nil {
+			_ = vhs.tty.Process.Kill()
+			return fmt.Errorf("could not launch browser, make sure chromium is installed: %w", err)
+		}
 	}
-	browser := rod.New().ControlURL(u).MustConnect()
-	page, err := browser.Page(proto.TargetCreateTarget{URL: fmt.Sprintf("http://localhost:%d", port)})
+	browser := rod.New().ControlURL(u)
+	if err := browser.Connect(); err != nil {
+		_ = vhs.tty.Process.Kill()
+		return fmt.Errorf("could not connect to browser: %w", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	page, err := browser.Page(proto.TargetCreateTarget{URL: url})
 	if err != nil {
+		_ = browser.Close()
+		_ = vhs.tty.Process.Kill()
 		return fmt.Errorf("could not open ttyd: %w", err)
 	}
 
 	vhs.browser = browser
 	vhs.Page = page
-	vhs.close = vhs.browser.Close
+	vhs.closeBrowser = browser.Close
+	if vhs.Options.ControlURL != "" {
+		// The browser isn't ours, only close the page we opened.
+		vhs.closeBrowser = page.Close
+	}
+	vhs.close = vhs.closeBrowser
+	if vhs.Options.Debug {
+		vhs.close = func() error {
+			vhs.logStatus("Debug: the browser is left open on the terminal at " + url)
+			return nil
+		}
+	}
 	vhs.started = true
 	return nil
 }
 
+// ErrBrowserNotFound is returned by Start when no browser is installed, and
+// chromium can't be downloaded either.
+var ErrBrowserNotFound = errors.New("no chromium-based browser found")
+
+// browserBin returns the installed browser to launch, or downloads chromium
+// when there is none.
+func browserBin() (string, error) {
+	if path, ok := launcher.LookPath(); ok {
+		return path, nil
+	}
+	path, err := launcher.NewBrowser().Get()
+	if err != nil {
+		return "", fmt.Errorf("%w, and chromium couldn't be downloaded: %v", ErrBrowserNotFound, err)
+	}
+	return path, nil
+}
+
+// launchBrowser launches the browser, and returns the URL to control it.
+func (vhs *VHS) launchBrowser() (string, error) {
+	path, err := browserBin()
+	if err != nil {
+		return "", err
+	}
+	enableNoSandbox := os.Getenv("VHS_NO_SANDBOX") != ""
+	l := launcher.New().
+		Leakless(false).
+		Bin(path).
+		NoSandbox(enableNoSandbox).
+		Headless(vhs.Options.Headless && !vhs.Options.Debug)
+	for _, flag := range vhs.Options.BrowserFlags {
+		name, value := browserFlag(flag)
+		if value == "" {
+			l = l.Set(name)
+		} else {
+			l = l.Set(name, value)
+		}
+	}
+	return l.Launch()
+}
+
+// browserFlag splits a browser command-line flag, e.g. --window-size=800,600,
+// into its name and value.
+func browserFlag(flag string) (flags.Flag, string) {
+	name, value, _ := strings.Cut(strings.TrimPrefix(flag, "--"), "=")
+	return flags.Flag(name), value
+}
+
+// canvasTimeout is how long Setup waits for xterm.js to render its canvases.
+const canvasTimeout = 10 * time.Second
+
+// ErrCanvasesNotFound is returned by Setup when the xterm.js canvases, which
+// frames are captured from, can't be found.
+var ErrCanvasesNotFound = errors.New("terminal canvases not found, xterm.js not ready")
+
 // Setup sets up the VHS instance and performs the necessary actions to reflect
 // the options that are default and set by the user.
-func (vhs *VHS) Setup() {
-	// Set Viewport to the correct size, accounting for the padding that will be
-	// added during the render.
-	padding := vhs.Options.Video.Style.Padding
-	margin := 0
-	if vhs.Options.Video.Style.MarginFill != "" {
-		margin = vhs.Options.Video.Style.Margin
-	}
-	bar := 0
-	if vhs.Options.Video.Style.WindowBar != "" {
-		bar = vhs.Options.Video.Style.WindowBarSize
-	}
-	width := vhs.Options.Video.Style.Width - double(padding) - double(margin)
-	height := vhs.Options.Video.Style.Height - double(padding) - double(margin) - bar
-	vhs.Page = vhs.Page.MustSetViewport(width, height, 0, false)
+func (vhs *VHS) Setup() error {
+	// Set Viewport to the correct size, accounting for the padding, margin and
+	// window bar that will be added during the render.
+	width, height := calcViewportDimensions(*vhs.Options.Video.Style)
+	vhs.Page = vhs.Page.MustSetViewport(width, height, vhs.Options.Video.Scale, false)
 
 	// Let's wait until we can access the window.term variable.
 	vhs.Page = vhs.Page.MustWait("() => window.term != undefined")
 
 	// Find xterm.js canvases for the text and cursor layer for recording.
-	vhs.TextCanvas, _ = vhs.Page.Element("canvas.xterm-text-layer")
-	vhs.CursorCanvas, _ = vhs.Page.Element("canvas.xterm-cursor-layer")
+	if err := vhs.findCanvases(); err != nil {
+		return err
+	}
+
+	// Load the font file, if any, before the terminal uses it.
+	fontFamily := vhs.Options.FontFamily
+	if vhs.Options.FontFile != "" {
+		if err := vhs.loadFontFile(); err != nil {
+			vhs.Errors = append(vhs.Errors, err)
+		} else {
+			fontFamily = fontFileFamily + fontsSeparator + fontFamily
+		}
+	}
 
 	// Apply options to the terminal
 	// By this point the setting commands have been executed, so the `opts` struct is up to date.
-	vhs.Page.MustEval(fmt.Sprintf("() => { term.options = { fontSize: %d, fontFamily: '%s', letterSpacing: %f, lineHeight: %f, theme: %s, cursorBlink: %t } }",
-		vhs.Options.FontSize, vhs.Options.FontFamily, vhs.Options.LetterSpacing,
-		vhs.Options.LineHeight, vhs.Options.Theme.String(), vhs.Options.CursorBlink))
+	vhs.Page.MustEval(fmt.Sprintf("() => { term.options = { fontSize: %d, fontFamily: '%s', fontWeight: '%s', fontWeightBold: '%s', letterSpacing: %f, lineHeight: %f, minimumContrastRatio: %f, theme: %s, cursorBlink: %t, cursorStyle: '%s' } }",
+		vhs.Options.FontSize, fontFamily, vhs.Options.FontWeight, vhs.Options.FontWeightBold,
+		vhs.Options.LetterSpacing, vhs.Options.LineHeight, vhs.Options.MinimumContrastRatio,
+		vhs.Options.termTheme().String(), vhs.Options.CursorBlink && vhs.Options.CursorBlinkRate == 0,
+		vhs.Options.CursorStyle))
 
-	// Fit the terminal into the window
-	vhs.Page.MustEval("term.fit")
+	// Fit the terminal into the window, or resize it to the requested size
+	vhs.Page.MustEval(termSizeJS(vhs.Options))
+
+	// Change the prompt before it's captured, and clear the command which
+	// changed it.
+	if vhs.Options.Prompt != "" {
+		if err := vhs.setPrompt(); err != nil {
+			vhs.Errors = append(vhs.Errors, err)
+		}
+		evalTerm(vhs, "() => term.clear()")
+	}
+
+	// Capture the shell prompt so that Wait knows what to look for.
+	if vhs.Options.WaitPattern == "" {
+		if prompt := vhs.capturePrompt(); prompt != "" {
+			vhs.prompt = prompt
+			vhs.Options.WaitPattern = promptPattern(prompt)
+		}
+	}
+
+	// Start timestamping terminal writes for the asciinema output.
+	if vhs.Options.Video.Output.Cast != "" {
+		vhs.StartCast()
+	}
 
 	_ = os.RemoveAll(vhs.Options.Video.Input)
 	_ = os.MkdirAll(vhs.Options.Video.Input, os.ModePerm)
+	return nil
 }
 
-const cleanupWaitTime = 100 * time.Millisecond
+// rendererJS returns the renderer xterm.js uses: canvas, webgl or dom, or an
+// empty string if the terminal isn't rendered yet. The WebGL renderer draws
+// into a single canvas, without the layers of the canvas renderer.
+const rendererJS = `() => {
+	if (document.querySelector("canvas.xterm-text-layer")) return "canvas";
+	if (document.querySelector(".xterm-screen canvas")) return "webgl";
+	if (document.querySelector(".xterm-rows")) return "dom";
+	return "";
+}`
+
+// findCanvases finds the xterm.js canvases for the text and cursor layers. The
+// lookups are retried until the terminal is rendered, or canvasTimeout.
+//
+// ttyd is started with the canvas renderer. Frames can't be captured from the
+// other renderers: the WebGL canvas is cleared once drawn, and the DOM
+// renderer has no canvas at all.
+func (vhs *VHS) findCanvases() error {
+	if err := vhs.Page.Timeout(canvasTimeout).Wait(rod.Eval(rendererJS)); err != nil {
+		return fmt.Errorf("%w: %v", ErrCanvasesNotFound, err)
+	}
+	res, err := vhs.Page.Eval(rendererJS)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCanvasesNotFound, err)
+	}
+	if renderer := res.Value.Str(); renderer != "canvas" {
+		return fmt.Errorf("%w: xterm.js uses the %s renderer, make sure ttyd supports `-t rendererType=canvas`", ErrCanvasesNotFound, renderer)
+	}
+
+	page := vhs.Page.Timeout(canvasTimeout)
+	text, err := page.Element("canvas.xterm-text-layer")
+	if err != nil {
+		return fmt.Errorf("%w: text layer: %v", ErrCanvasesNotFound, err)
+	}
+	cursor, err := page.Element("canvas.xterm-cursor-layer")
+	if err != nil {
+		return fmt.Errorf("%w: cursor layer: %v", ErrCanvasesNotFound, err)
+	}
+
+	// The canvases are used for the whole recording, so they must not keep
+	// the timeout of the lookup.
+	vhs.TextCanvas = text.CancelTimeout()
+	vhs.CursorCanvas = cursor.CancelTimeout()
+	return nil
+}
+
+// resizeTerminal applies the font size to the terminal and refits it. xterm.js
+// may recreate the canvases when the terminal is resized, so they are looked
+// up again once the terminal is set up. The lock is held so that no frame is
+// captured from a canvas in between.
+func (vhs *VHS) resizeTerminal(fontSize int) error {
+	vhs.mutex.Lock()
+	defer vhs.mutex.Unlock()
+	if vhs.Page == nil {
+		return nil
+	}
+	if _, err := vhs.Page.Eval(fmt.Sprintf("() => term.options.fontSize = %d", fontSize)); err != nil {
+		return fmt.Errorf("could not set the font size: %w", err)
+	}
+	if _, err := vhs.Page.Eval(termSizeJS(vhs.Options)); err != nil {
+		return fmt.Errorf("could not resize the terminal: %w", err)
+	}
+	if vhs.TextCanvas == nil {
+		return nil
+	}
+	return vhs.findCanvases()
+}
 
 // Terminate cleans up a VHS instance and terminates the go-rod browser and ttyd
 // processes.
 func (vhs *VHS) terminate() error {
-	// Give some time for any commands executed (such as `rm`) to finish.
-	//
-	// If a user runs a long running command, they must sleep for the required time
-	// to finish.
-	time.Sleep(cleanupWaitTime)
+	vhs.waitForCleanup()
+
+	// Leave the processes running to inspect them.
+	if vhs.Options.Debug {
+		return nil
+	}
 
 	// Tear down the processes we started.
-	vhs.browser.MustClose()
+	_ = vhs.closeBrowser()
 	return vhs.tty.Process.Kill()
 }
 
+// waitForCleanup gives the commands run last, such as an `rm` cleaning up
+// after the demo, up to CleanupWait to finish before the processes are torn
+// down. It returns as soon as the shell prompt is back, when it's known, so
+// that tapes which end at the prompt don't wait at all. Otherwise, it waits
+// for the whole CleanupWait.
+//
+// A long running command may still need a Sleep, or a Wait, to finish.
+func (vhs *VHS) waitForCleanup() {
+	wait := vhs.Options.CleanupWait
+	if wait <= 0 {
+		return
+	}
+	if vhs.Page != nil && vhs.Options.WaitPattern != "" {
+		if pattern, err := regexp.Compile(vhs.Options.WaitPattern); err == nil {
+			_ = vhs.WaitPattern(pattern, wait)
+			return
+		}
+	}
+	_ = vhs.sleep(wait)
+}
+
 // Cleanup individual frames.
 func (vhs *VHS) Cleanup() error {
 	err := os.RemoveAll(vhs.Options.Video.Input)
@@ -212,44 +640,179 @@ func (vhs *VHS) Cleanup() error {
 
 // Render starts rendering the individual frames into a video.
 func (vhs *VHS) Render() error {
+	if err := checkFFmpeg(vhs.Options.Video.ffmpeg()); err != nil {
+		return err
+	}
+
+	// The streamed frames were rendered while recording.
+	if vhs.frameStream != nil {
+		return vhs.renderStream()
+	}
+
+	// Skip the blank frames before the timeline is made, so that the captions
+	// and key sounds follow the frames which are kept.
+	if err := vhs.skipBlankFrames(); err != nil {
+		return err
+	}
+
+	// Place the captions and key sounds in the output before the frames are
+	// moved around.
+	tl := vhs.timeline()
+	vhs.Options.Video.Captions = vhs.captionTimes(tl)
+	if err := vhs.writeCaptions(); err != nil {
+		return err
+	}
+	if vhs.Options.Video.KeySound {
+		path := filepath.Join(vhs.Options.Video.Input, keySoundFile)
+		if err := writeKeySound(path, vhs.keySoundTimes(tl), tl.seconds(tl.frames)); err != nil {
+			return err
+		}
+	}
+
+	// Trim the frames before the loop offset and boomerang are applied, so
+	// that they only see the frames which are kept.
+	if err := vhs.ApplyTrim(); err != nil {
+		return err
+	}
+
 	// Apply Loop Offset by modifying frame sequence
 	if err := vhs.ApplyLoopOffset(); err != nil {
 		return err
 	}
 
-	// Generate the video(s) with the frames.
-	var cmds []*exec.Cmd
-	cmds = append(cmds, MakeGIF(vhs.Options.Video))
-	cmds = append(cmds, MakeMP4(vhs.Options.Video))
-	cmds = append(cmds, MakeWebM(vhs.Options.Video))
-	cmds = append(cmds, MakeScreenshots(vhs.Options.Screenshot)...)
+	// Play the frames backward after the loop offset is applied, so the
+	// whole sequence is reversed.
+	if err := vhs.ApplyBoomerang(); err != nil {
+		return err
+	}
+
+	// Hold the frames for as long as they were shown while recording, once
+	// they are in their final order.
+	if err := vhs.writeFrameDurations(tl); err != nil {
+		return err
+	}
+
+	// Find the identical frames once the frames are in their final order.
+	if err := vhs.dedupFrames(); err != nil {
+		return err
+	}
+
+	if width, height := vhs.Options.Video.outputDimensions(); width%2 != 0 || height%2 != 0 {
+		evenWidth, evenHeight := evenDimensions(width, height)
+		vhs.logStatus(fmt.Sprintf("Padding the outputs from %dx%d to %dx%d, since videos need even dimensions", width, height, evenWidth, evenHeight))
+	}
+
+	// Generate the video(s) and the poster with the frames.
+	outputs := []struct {
+		format string
+		path   string
+		cmd    *exec.Cmd
+	}{
+		{"GIF", vhs.Options.Video.Output.GIF, MakeGIF(vhs.Options.Video)},
+		{"MP4", vhs.Options.Video.Output.MP4, MakeMP4(vhs.Options.Video)},
+		{"WebM", vhs.Options.Video.Output.WebM, MakeWebM(vhs.Options.Video)},
+		{"APNG", vhs.Options.Video.Output.APNG, MakeAPNG(vhs.Options.Video)},
+		{"WebP", vhs.Options.Video.Output.WebP, MakeWebP(vhs.Options.Video)},
+		{"Poster", vhs.Options.Video.Poster, MakePoster(vhs.Options.Video, vhs.totalFrames)},
+	}
+
+	// Report the start of the rendering before the outputs are created.
+	start := time.Now()
+	progress := Progress{
+		Stage:  StageRendering,
+		Frames: vhs.totalFrames,
+	}
+	for _, output := range outputs {
+		if output.cmd != nil {
+			progress.Outputs++
+		}
+	}
+	vhs.reportProgress(progress)
 
-	for _, cmd := range cmds {
-		if cmd == nil {
+	// Create the outputs one after the other, or up to one per CPU at once
+	// when rendering in parallel. The errors are kept in the order of the
+	// outputs either way.
+	workers := 1
+	if vhs.Options.Video.Parallel {
+		workers = runtime.NumCPU()
+	}
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, workers)
+		errs = make([]*FFmpegError, len(outputs))
+	)
+	for i, output := range outputs {
+		if output.cmd == nil {
 			continue
 		}
-		out, err := cmd.CombinedOutput()
+		i, output := i, output
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			vhs.logStatus("Creating " + output.path + "...")
+			out, err := output.cmd.CombinedOutput()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				vhs.logMessage(string(out))
+				ffmpegErr := newFFmpegError(output.format, output.cmd, out, err)
+				errs[i] = &ffmpegErr
+			}
+			progress.Output = output.format
+			progress.Rendered++
+			progress.Elapsed = time.Since(start)
+			vhs.reportProgress(progress)
+		}()
+	}
+	wg.Wait()
+
+	var renderErr RenderError
+	for _, err := range errs {
 		if err != nil {
-			log.Println(string(out))
+			renderErr.Errors = append(renderErr.Errors, *err)
 		}
 	}
+	renderErr.Errors = append(renderErr.Errors, vhs.renderScreenshots()...)
 
+	if vhs.Options.Video.Output.Cast != "" {
+		vhs.logStatus("Creating " + vhs.Options.Video.Output.Cast + "...")
+	}
+	if err := MakeCast(vhs.Options.Video); err != nil {
+		return err
+	}
+	if len(renderErr.Errors) > 0 {
+		return renderErr
+	}
 	return nil
 }
 
+// TotalFrames returns the number of frames in the recording. After Render, it
+// includes the frames added and removed by Boomerang and the trim settings.
+func (vhs *VHS) TotalFrames() int {
+	return vhs.frames()
+}
+
+// Duration returns the duration of the recording at the playback speed, i.e.
+// the duration of the outputs after Render.
+func (vhs *VHS) Duration() time.Duration {
+	opts := vhs.Options.Video
+	seconds := float64(vhs.TotalFrames()) / float64(opts.captureFramerate()) / opts.PlaybackSpeed
+	return time.Duration(seconds * float64(time.Second))
+}
+
 // ApplyLoopOffset by modifying frame sequence
 func (vhs *VHS) ApplyLoopOffset() error {
 	if vhs.totalFrames <= 0 {
 		return errors.New("no frames")
 	}
 
-	loopOffsetPercentage := vhs.Options.LoopOffset
-
-	// Calculate # of frames to offset from LoopOffset percentage
-	loopOffsetFrames := int(math.Ceil(loopOffsetPercentage / 100.0 * float64(vhs.totalFrames)))
-
-	// Take care of overflow and keep track of exact offsetPercentage
-	loopOffsetFrames = loopOffsetFrames % vhs.totalFrames
+	loopOffsetFrames := loopOffsetFrames(vhs.Options.LoopOffset, vhs.totalFrames)
 
 	// No operation if nothing to offset
 	if loopOffsetFrames <= 0 {
@@ -258,118 +821,360 @@ func (vhs *VHS) ApplyLoopOffset() error {
 
 	// Move all frames in [offsetStart, offsetEnd] to end of frame sequence
 	offsetStart := vhs.Options.Video.StartingFrame
-	offsetEnd := loopOffsetFrames
+	offsetEnd := offsetStart + loopOffsetFrames - 1
 
 	// New starting frame will be the next frame after offsetEnd
 	vhs.Options.Video.StartingFrame = offsetEnd + 1
 
-	// Rename all text and cursor frame files in the range concurrently
-	errCh := make(chan error)
-	doneCh := make(chan bool)
-	var wg sync.WaitGroup
+	formats := []string{vhs.Options.Video.textFrames()}
+	// There are no cursor frames when the cursor is hidden or composited.
+	if vhs.Options.Video.cursorFrames() {
+		formats = append(formats, cursorFrameFormat)
+	}
 
+	// Rename all text and cursor frame files in the range. This is done
+	// sequentially since renames are cheap, and every failure is collected
+	// rather than bailing out on the first one.
+	var errs multiError
 	for counter := offsetStart; counter <= offsetEnd; counter++ {
-		wg.Add(1)
-		go func(frameNum int) {
-			defer wg.Done()
-			offsetFrameNum := frameNum + vhs.totalFrames
+		offsetFrameNum := counter + vhs.totalFrames
+		for _, format := range formats {
 			if err := os.Rename(
-				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(cursorFrameFormat, frameNum)),
-				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(cursorFrameFormat, offsetFrameNum)),
+				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(format, counter)),
+				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(format, offsetFrameNum)),
 			); err != nil {
-				errCh <- fmt.Errorf("error applying offset to cursor frame: %w", err)
+				errs = append(errs, err)
 			}
-		}(counter)
+		}
+	}
 
-		wg.Add(1)
-		go func(frameNum int) {
-			defer wg.Done()
-			offsetFrameNum := frameNum + vhs.totalFrames
-			if err := os.Rename(
-				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(textFrameFormat, frameNum)),
-				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(textFrameFormat, offsetFrameNum)),
+	if len(errs) > 0 {
+		return fmt.Errorf("error applying loop offset to %d frame(s): %w", len(errs), errs)
+	}
+	return nil
+}
+
+// loopOffsetFrames returns the number of frames moved to the end of a sequence
+// of the given number of frames by the LoopOffset percentage.
+func loopOffsetFrames(percentage float64, frames int) int {
+	// Calculate # of frames to offset from LoopOffset percentage
+	offset := int(math.Ceil(percentage / 100.0 * float64(frames)))
+
+	// Take care of overflow and keep track of exact offsetPercentage
+	return offset % frames
+}
+
+// timeline maps the recorded frames to their position in the output, once they
+// are trimmed and moved by the loop offset.
+type timeline struct {
+	framerate int
+	speed     float64
+	// trimStart is the number of frames trimmed off the start, and frames
+	// the number of frames kept.
+	trimStart int
+	frames    int
+	// offset is the number of frames moved to the end by the loop offset.
+	offset int
+	// durations are the durations of the frames in the output, in order,
+	// with a variable framerate. Frames last 1/framerate otherwise.
+	durations []time.Duration
+}
+
+// timeline returns the timeline of the recorded frames. It follows the frames
+// as they are trimmed and moved by the loop offset, so it must be called
+// before those are applied.
+func (vhs *VHS) timeline() timeline {
+	opts := vhs.Options.Video
+	tl := timeline{
+		framerate: opts.captureFramerate(),
+		speed:     opts.PlaybackSpeed,
+	}
+	tl.trimStart = opts.TrimStart.frames(tl.framerate)
+	tl.frames = vhs.totalFrames - tl.trimStart - opts.TrimEnd.frames(tl.framerate)
+	if tl.frames > 0 {
+		tl.offset = loopOffsetFrames(vhs.Options.LoopOffset, tl.frames)
+	}
+	if opts.VariableFramerate && tl.frames > 0 && len(vhs.frameTimes) == vhs.totalFrames {
+		kept := frameDurations(vhs.frameTimes, tl.framerate)[tl.trimStart : tl.trimStart+tl.frames]
+		tl.durations = append(append([]time.Duration{}, kept[tl.offset:]...), kept[:tl.offset]...)
+	}
+	return tl
+}
+
+// position returns the position in the output, from 0, of the recorded frame,
+// from 1, or false if the frame is trimmed.
+func (tl timeline) position(frame int) (int, bool) {
+	pos := frame - 1 - tl.trimStart
+	if pos < 0 || pos >= tl.frames {
+		return 0, false
+	}
+	if pos < tl.offset {
+		return pos - tl.offset + tl.frames, true
+	}
+	return pos - tl.offset, true
+}
+
+// seconds returns the time at which the frame at the position in the output
+// starts.
+func (tl timeline) seconds(pos int) float64 {
+	if tl.durations == nil {
+		return float64(pos) / float64(tl.framerate) / tl.speed
+	}
+	var elapsed time.Duration
+	for _, d := range tl.durations[:pos] {
+		elapsed += d
+	}
+	return elapsed.Seconds() / tl.speed
+}
+
+// ApplyTrim removes the frames trimmed off the start and end of the frame
+// sequence, and moves the starting frame past the ones trimmed off the start.
+// ffmpeg reads frames until one is missing, so removing the frames trimmed off
+// the end is what stops it there.
+func (vhs *VHS) ApplyTrim() error {
+	framerate := vhs.Options.Video.captureFramerate()
+	start := vhs.Options.Video.TrimStart.frames(framerate)
+	end := vhs.Options.Video.TrimEnd.frames(framerate)
+	if start == 0 && end == 0 {
+		return nil
+	}
+	if start+end >= vhs.totalFrames {
+		return fmt.Errorf("cannot trim %d frames off a recording of %d frames", start+end, vhs.totalFrames)
+	}
+
+	formats := []string{vhs.Options.Video.textFrames()}
+	if vhs.Options.Video.cursorFrames() {
+		formats = append(formats, cursorFrameFormat)
+	}
+
+	first := vhs.Options.Video.StartingFrame
+	last := first + vhs.totalFrames - 1
+	var errs multiError
+	for frame := first; frame <= last; frame++ {
+		if frame >= first+start && frame <= last-end {
+			continue
+		}
+		for _, format := range formats {
+			err := os.Remove(filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(format, frame)))
+			if err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error trimming %d frame(s): %w", len(errs), errs)
+	}
+
+	vhs.Options.Video.StartingFrame += start
+	vhs.totalFrames -= start + end
+	return nil
+}
+
+// ApplyBoomerang appends the frames in reverse order to the frame sequence, so
+// that the output plays forward and then backward. The last frame isn't
+// repeated at the turning point, nor is the first frame at the end, so the
+// output loops smoothly.
+func (vhs *VHS) ApplyBoomerang() error {
+	if !vhs.Options.Video.Boomerang || vhs.totalFrames < 3 { //nolint:gomnd
+		return nil
+	}
+
+	formats := []string{vhs.Options.Video.textFrames()}
+	if vhs.Options.Video.cursorFrames() {
+		formats = append(formats, cursorFrameFormat)
+	}
+
+	first := vhs.Options.Video.StartingFrame
+	last := first + vhs.totalFrames - 1
+	next := last + 1
+	for frame := last - 1; frame > first; frame-- {
+		for _, format := range formats {
+			if err := linkFrame(
+				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(format, frame)),
+				filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(format, next)),
 			); err != nil {
-				errCh <- fmt.Errorf("error applying offset to text frame: %w", err)
+				return fmt.Errorf("error applying boomerang to frame %d: %w", frame, err)
 			}
-		}(counter)
+		}
+		next++
 	}
 
-	go func() {
-		wg.Wait()
-		close(doneCh)
-	}()
+	vhs.totalFrames = next - first
+	return nil
+}
 
-	select {
-	case <-doneCh:
+// linkFrame makes the frame available under a new name, preferring a hard
+// link and falling back to a copy.
+func linkFrame(oldname, newname string) error {
+	if err := os.Link(oldname, newname); err == nil {
 		return nil
-	case err := <-errCh:
-		// Bail out in case of an error while renaming
+	}
+	bts, err := os.ReadFile(oldname)
+	if err != nil {
 		return err
 	}
+	return os.WriteFile(newname, bts, os.ModePerm)
 }
 
+// quality is the quality of screenshots, which are kept lossless.
 const quality = 1.0
 
+// ErrMaxDuration is sent by Record when the recording exceeds MaxDuration.
+var ErrMaxDuration = errors.New("recording exceeded the maximum duration")
+
+// ErrFramesDropped is sent by Record, before the channel is closed, when
+// captures took longer than the interval between frames, so some frames
+// weren't captured.
+var ErrFramesDropped = errors.New("frames were dropped")
+
+// ErrTerminalExited is sent by Record when the shell, or the Command, run in
+// the terminal exits.
+var ErrTerminalExited = errors.New("the program in the terminal exited")
+
 // Record begins the goroutine which captures images from the xterm.js canvases.
+//
+// Recording stops when the context is cancelled or, if set, once MaxDuration
+// has elapsed, in which case ErrMaxDuration is sent before the channel is
+// closed. Recording also stops once the shell, or the Command, run in the
+// terminal exits, e.g. after `exit` or Ctrl+D, in which case ErrTerminalExited
+// is sent. The browser is left open then, so that the recording can still be
+// saved.
+//
+// Frames which can't be captured are skipped, and their errors sent, without
+// leaving any of their files behind, so the frames on disk are always
+// numbered consecutively from 1 to TotalFrames. See captureFrame. Frames are
+// captured on a fixed cadence, and those which can't be captured on time
+// since the capture before them took too long are dropped, and reported with
+// ErrFramesDropped once the recording stops, unless VariableFramerate holds the
+// frames before them for longer. The frames are written to disk in the
+// background, so that slow writes don't delay the next capture, and all of them
+// are written before the channel is closed.
 func (vhs *VHS) Record(ctx context.Context) <-chan error {
 	ch := make(chan error)
-	interval := time.Second / time.Duration(vhs.Options.Video.Framerate)
+	interval := time.Second / time.Duration(vhs.Options.Video.captureFramerate())
+
+	// A nil channel never fires, so there is no limit by default.
+	var deadline <-chan time.Time
+	var timer *time.Timer
+	if vhs.Options.MaxDuration > 0 {
+		timer = time.NewTimer(vhs.Options.MaxDuration)
+		deadline = timer.C
+	}
+
+	// Frames are written in the background, with up to a second of frames
+	// waiting to be written, unless they are streamed into ffmpeg.
+	var writer *frameWriter
+	stream := vhs.frameStream
+	if stream == nil {
+		writer = newFrameWriter(vhs.Options.Video.Input, vhs.Options.Video.captureFramerate(), ch)
+		vhs.mutex.Lock()
+		vhs.frameWriter = writer
+		vhs.mutex.Unlock()
+	}
+
+	// dropped is the number of frames which weren't captured since the
+	// capture before them ran past their time.
+	var dropped int
+
+	// flush waits for the frames to be written, or streamed, before the
+	// recording ends, and reports the dropped frames.
+	flush := func() {
+		if stream != nil {
+			for _, err := range stream.close() {
+				ch <- err
+			}
+		} else {
+			vhs.mutex.Lock()
+			vhs.frameWriter = nil
+			vhs.mutex.Unlock()
+			writer.close()
+		}
+		// The frames before the dropped ones are held for longer with a
+		// variable framerate, so the output still plays in time.
+		if dropped > 0 && !vhs.Options.Video.VariableFramerate {
+			ch <- fmt.Errorf("%w: %d frames weren't captured on time, so the output plays faster than the recording", ErrFramesDropped, dropped)
+		}
+	}
 
 	go func() {
+		if timer != nil {
+			defer timer.Stop()
+		}
+		defer vhs.closeTextDump()
+
 		counter := 0
-		start := time.Now()
+		recordStart := time.Now()
+		next := recordStart
+		// paused is the time the recording was paused for, since pausedAt
+		// while it's paused, which the frame times leave out so that the
+		// frames before and after a pause play one after the other.
+		var paused time.Duration
+		var pausedAt time.Time
 		for {
 			select {
 			case <-ctx.Done():
+				flush()
 				_ = vhs.terminate()
 
-				// Save total # of frames for offset calculation
-				vhs.totalFrames = counter
-
 				// Signal caller that we're done recording.
 				close(ch)
 				return
 
-			case <-time.After(interval - time.Since(start)):
-				// record last attempt
-				start = time.Now()
+			case <-deadline:
+				flush()
+				_ = vhs.terminate()
 
-				if !vhs.recording {
-					continue
-				}
-				if vhs.Page == nil {
-					continue
-				}
+				ch <- fmt.Errorf("%w (%s)", ErrMaxDuration, vhs.Options.MaxDuration)
+				close(ch)
+				return
 
-				cursor, cursorErr := vhs.CursorCanvas.CanvasToImage("image/png", quality)
-				text, textErr := vhs.TextCanvas.CanvasToImage("image/png", quality)
-				if textErr != nil || cursorErr != nil {
-					ch <- fmt.Errorf("error: %v, %v", textErr, cursorErr)
-					continue
-				}
+			case <-vhs.exited:
+				flush()
+				ch <- ErrTerminalExited
+				close(ch)
+				return
 
-				counter++
-				if err := os.WriteFile(
-					filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(cursorFrameFormat, counter)),
-					cursor,
-					os.ModePerm,
-				); err != nil {
-					ch <- fmt.Errorf("error writing cursor frame: %w", err)
+			case <-time.After(time.Until(next)):
+				// Capture the frames on a fixed cadence, so that slow
+				// captures don't add up, skipping the frames whose time
+				// passed during the last capture.
+				var late int
+				next, late = nextFrame(next, time.Now(), interval)
+
+				// Hold the lock for the whole iteration so that PauseRecording
+				// can never interleave with a capture in progress.
+				vhs.mutex.Lock()
+				if !vhs.recording || vhs.Page == nil {
+					if pausedAt.IsZero() {
+						pausedAt = time.Now()
+					}
+					vhs.mutex.Unlock()
 					continue
 				}
-				if err := os.WriteFile(
-					filepath.Join(vhs.Options.Video.Input, fmt.Sprintf(textFrameFormat, counter)),
-					text,
-					os.ModePerm,
-				); err != nil {
-					ch <- fmt.Errorf("error writing text frame: %w", err)
-					continue
+				if !pausedAt.IsZero() {
+					paused += time.Since(pausedAt)
+					pausedAt = time.Time{}
 				}
-
-				// Capture current frame and disable frame capturing
-				if vhs.Options.Screenshot.frameCapture {
-					vhs.Options.Screenshot.makeScreenshot(counter)
+				dropped += late
+				elapsed := time.Since(recordStart)
+				err := vhs.captureFrame(counter+1, elapsed)
+				if err == nil {
+					// Keep the total # of frames up to date for the offset
+					// calculation and the logs.
+					counter++
+					vhs.totalFrames = counter
+					vhs.frameTimes = append(vhs.frameTimes, elapsed-paused)
+				}
+				vhs.mutex.Unlock()
+				if err != nil {
+					ch <- err
+					continue
 				}
+				vhs.reportProgress(Progress{
+					Stage:   StageRecording,
+					Frames:  counter,
+					Elapsed: time.Since(recordStart),
+				})
 			}
 		}
 	}()
@@ -377,6 +1182,199 @@ func (vhs *VHS) Record(ctx context.Context) <-chan error {
 	return ch
 }
 
+// nextFrame returns the time of the frame after the one due at the given time,
+// now that it's being captured, and the number of frames which are dropped
+// since their time already passed.
+func nextFrame(due, now time.Time, interval time.Duration) (time.Time, int) {
+	late := int(now.Sub(due) / interval)
+	if late < 0 {
+		late = 0
+	}
+	return due.Add(time.Duration(late+1) * interval), late
+}
+
+// errStaleCanvas is returned when a canvas is no longer on the page.
+var errStaleCanvas = errors.New("the canvas is no longer on the page")
+
+// canvasesJS returns the images of the text canvas (this), in the given format,
+// and the cursor canvas, if any, as data URLs, followed by the size of the
+// canvases, or nothing if either was removed from the page. The cursor is
+// always a PNG image, which keeps its transparency. Both are read in the same
+// task, so xterm.js can't render in between and the cursor always matches the
+// text.
+//
+// Once the canvases are no longer of the given size, they are drawn onto
+// canvases of that size, scaled down if they no longer fit, so that the frames
+// ffmpeg renders are all the same size. The text is drawn over the background.
+const canvasesJS = `(cursor, format, quality, size, background) => {
+	if (!this.isConnected || (cursor && !cursor.isConnected)) {
+		return [];
+	}
+	const fit = (canvas, fill) => {
+		if (!size || (canvas.width === size[0] && canvas.height === size[1])) {
+			return canvas;
+		}
+		const fixed = document.createElement("canvas");
+		fixed.width = size[0];
+		fixed.height = size[1];
+		const ctx = fixed.getContext("2d");
+		if (fill) {
+			ctx.fillStyle = fill;
+			ctx.fillRect(0, 0, fixed.width, fixed.height);
+		}
+		const scale = Math.min(1, fixed.width / canvas.width, fixed.height / canvas.height);
+		ctx.drawImage(canvas, 0, 0, canvas.width * scale, canvas.height * scale);
+		return fixed;
+	};
+	return [
+		fit(this, background).toDataURL(format, quality),
+		cursor ? fit(cursor).toDataURL("image/png") : "",
+		this.width,
+		this.height,
+	];
+}`
+
+// decodeDataURL returns the data of a base64 data URL, or errStaleCanvas if
+// it's empty.
+func decodeDataURL(url string) ([]byte, error) {
+	_, data, ok := strings.Cut(url, ",")
+	if !ok {
+		return nil, errStaleCanvas
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
+
+// captureCanvases captures the text canvas, and the cursor canvas unless the
+// cursor is hidden, with the text in the given format and quality. xterm.js
+// may recreate the canvases when the terminal is resized, e.g. by a full-screen
+// program handling SIGWINCH, so they are looked up again when they are stale.
+// The caller must hold vhs.mutex.
+func (vhs *VHS) captureCanvases(format string, quality float64) (text, cursor []byte, err error) {
+	text, cursor, err = vhs.readCanvases(format, quality)
+	if !errors.Is(err, errStaleCanvas) {
+		return text, cursor, err
+	}
+	if err := vhs.findCanvases(); err != nil {
+		return nil, nil, err
+	}
+	return vhs.readCanvases(format, quality)
+}
+
+// readCanvases reads the images of the canvases captured by captureCanvases.
+// The text and cursor canvases are read at once, so that they are always from
+// the same moment, and neither is returned unless both could be read.
+func (vhs *VHS) readCanvases(format string, quality float64) (text, cursor []byte, err error) {
+	// A nil cursor canvas is passed as null, rather than a nil object.
+	var cursorCanvas interface{}
+	if !vhs.Options.Video.HideCursor {
+		cursorCanvas = vhs.CursorCanvas.Object
+	}
+	res, err := vhs.TextCanvas.Eval(canvasesJS, cursorCanvas, format, quality, vhs.frameSize, vhs.Options.Theme.Background)
+	if errors.Is(err, &rod.ErrObjectNotFound{}) {
+		return nil, nil, errStaleCanvas
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error capturing frame: %w", err)
+	}
+	urls := res.Value.Arr()
+	if len(urls) != 4 { //nolint:gomnd
+		return nil, nil, errStaleCanvas
+	}
+	if vhs.frameSize == nil {
+		vhs.frameSize = []int{urls[2].Int(), urls[3].Int()}
+	}
+	text, err = decodeDataURL(urls[0].Str())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error capturing text frame: %w", err)
+	}
+	if cursorCanvas == nil {
+		return text, nil, nil
+	}
+	cursor, err = decodeDataURL(urls[1].Str())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error capturing cursor frame: %w", err)
+	}
+	return text, cursor, nil
+}
+
+// captureFrame captures the cursor and text canvases and writes them to disk
+// as the given frame. The caller must hold vhs.mutex.
+//
+// A frame is either written whole or not at all: both canvases come from the
+// same moment, and if any file of the frame can't be written, those already
+// written are removed and an error is returned. The frame isn't counted then,
+// so the next frame captured takes its number and the frames stay
+// consecutive. While recording, the frame is handed to the frameWriter instead,
+// which writes it in the background, or to the frameStream with StreamFrames.
+// See frameWriter.close for the frames it can't write.
+func (vhs *VHS) captureFrame(frame int, elapsed time.Duration) error {
+	text, cursor, err := vhs.captureCanvases(vhs.Options.Video.frameMIME(), vhs.Options.Video.CaptureQuality)
+	if err != nil {
+		return err
+	}
+
+	f := capturedFrame{number: frame}
+	var writeCursor bool
+	if !vhs.Options.Video.HideCursor {
+		// Blink the cursor ourselves when a custom rate is set.
+		visible := vhs.cursorVisible(elapsed)
+
+		if vhs.Options.Video.compositeCursor() {
+			if visible {
+				text, err = compositeFrame(text, cursor, vhs.Options.Video.FrameFormat, vhs.Options.Video.CaptureQuality)
+				if err != nil {
+					return fmt.Errorf("error compositing cursor frame: %w", err)
+				}
+			}
+		} else {
+			if !visible {
+				cursor, err = blankFrame(cursor)
+				if err != nil {
+					return fmt.Errorf("error blanking cursor frame: %w", err)
+				}
+			}
+			writeCursor = true
+		}
+	}
+
+	f.files = append(f.files, frameFile{vhs.Options.Video.textFrames(), text})
+	if writeCursor {
+		f.files = append(f.files, frameFile{cursorFrameFormat, cursor})
+	}
+
+	// While streaming, the frame is piped into ffmpeg once the text is
+	// dumped, since the text dump needs the page.
+	if vhs.frameStream != nil {
+		if err := vhs.dumpText(frame); err != nil {
+			return err
+		}
+		vhs.frameStream.write(text)
+		return nil
+	}
+
+	// While recording, the frame is written in the background once the text
+	// is dumped, since the text dump needs the page.
+	if vhs.frameWriter != nil {
+		if err := vhs.dumpText(frame); err != nil {
+			return err
+		}
+		vhs.frameWriter.write(f)
+		return nil
+	}
+
+	if err := f.write(vhs.Options.Video.Input); err != nil {
+		return err
+	}
+	// The text dump can't be undone, so it's written last.
+	if err := vhs.dumpText(frame); err != nil {
+		for _, file := range f.files {
+			_ = os.Remove(file.path(vhs.Options.Video.Input, frame))
+		}
+		return err
+	}
+	return nil
+}
+
 // ResumeRecording indicates to VHS that the recording should be resumed.
 func (vhs *VHS) ResumeRecording() {
 	vhs.mutex.Lock()
@@ -386,17 +1384,13 @@ func (vhs *VHS) ResumeRecording() {
 }
 
 // PauseRecording indicates to VHS that the recording should be paused.
+//
+// PauseRecording blocks until any frame capture in progress has finished, so
+// once it returns no further frames are captured until ResumeRecording is
+// called. Frames are numbered consecutively across pauses.
 func (vhs *VHS) PauseRecording() {
 	vhs.mutex.Lock()
 	defer vhs.mutex.Unlock()
 
 	vhs.recording = false
 }
-
-// ScreenshotNextFrame indicates to VHS that screenshot of next frame must be taken.
-func (vhs *VHS) ScreenshotNextFrame(path string) {
-	vhs.mutex.Lock()
-	defer vhs.mutex.Unlock()
-
-	vhs.Options.Screenshot.enableFrameCapture(path)
-}
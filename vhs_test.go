@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+// testPage returns a page of a headless browser showing the HTML, or skips the
+// test if no browser is installed.
+func testPage(t testing.TB, html string) *rod.Page {
+	t.Helper()
+	bin, ok := launcher.LookPath()
+	if !ok {
+		t.Skip("no browser is installed")
+	}
+	u, err := launcher.New().Bin(bin).Headless(true).Launch()
+	if err != nil {
+		t.Skipf("could not launch the browser: %v", err)
+	}
+	browser := rod.New().ControlURL(u)
+	if err := browser.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = browser.Close() })
+	return browser.MustPage("").MustSetDocumentContent(html)
+}
+
+func TestReadCanvasesSynchronized(t *testing.T) {
+	// Like xterm.js, both canvases are drawn on each animation frame, here in
+	// a new color each time.
+	page := testPage(t, `<canvas id="text" width="8" height="8"></canvas>
+		<canvas id="cursor" width="8" height="8"></canvas>
+		<script>
+		let n = 0;
+		const draw = () => {
+			n = (n + 1) % 256;
+			for (const id of ["text", "cursor"]) {
+				const ctx = document.getElementById(id).getContext("2d");
+				ctx.fillStyle = "rgb(" + n + ", 0, 0)";
+				ctx.fillRect(0, 0, 8, 8);
+			}
+			requestAnimationFrame(draw);
+		};
+		requestAnimationFrame(draw);
+		</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+	v.TextCanvas = page.MustElement("#text")
+	v.CursorCanvas = page.MustElement("#cursor")
+
+	red := func(frame []byte) uint32 {
+		img, err := png.Decode(bytes.NewReader(frame))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _, _, _ := img.At(0, 0).RGBA()
+		return r
+	}
+	for i := 0; i < 100; i++ {
+		text, cursor, err := v.readCanvases("image/png", quality)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if red(text) != red(cursor) {
+			t.Fatalf("expected the cursor to be captured with the text, got frame %d of the text and %d of the cursor", red(text)>>8, red(cursor)>>8)
+		}
+	}
+
+	// Neither canvas is returned once one of them is removed.
+	page.MustElement("#cursor").MustRemove()
+	if text, _, err := v.readCanvases("image/png", quality); !errors.Is(err, errStaleCanvas) || text != nil {
+		t.Errorf("expected the canvases to be stale, got %v", err)
+	}
+}
+
+func TestReadCanvasesFixedSize(t *testing.T) {
+	page := testPage(t, `<canvas id="text" width="8" height="8"></canvas>
+		<canvas id="cursor" width="8" height="8"></canvas>
+		<script>
+		// Like a live FontSize, which changes the size of the canvases.
+		window.resize = (width, height) => {
+			for (const id of ["text", "cursor"]) {
+				const canvas = document.getElementById(id);
+				canvas.width = width;
+				canvas.height = height;
+			}
+			const ctx = document.getElementById("text").getContext("2d");
+			ctx.fillStyle = "#0000ff";
+			ctx.fillRect(0, 0, width, height);
+		};
+		</script>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+	v.TextCanvas = page.MustElement("#text")
+	v.CursorCanvas = page.MustElement("#cursor")
+	v.Options.Theme.Background = "#ff0000"
+
+	decode := func(frame []byte) image.Image {
+		img, err := png.Decode(bytes.NewReader(frame))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	if _, _, err := v.readCanvases("image/png", quality); err != nil {
+		t.Fatal(err)
+	}
+
+	// The wider canvases are scaled down to the size of the first frame,
+	// over the background.
+	page.MustEval("() => window.resize(16, 4)")
+	text, cursor, err := v.readCanvases("image/png", quality)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range []image.Image{decode(text), decode(cursor)} {
+		if size := img.Bounds().Size(); size != image.Pt(8, 8) {
+			t.Errorf("expected the frames to keep their size of 8x8, got %v", size)
+		}
+	}
+	img := decode(text)
+	if r, _, b, _ := img.At(0, 0).RGBA(); r != 0 || b != 0xffff {
+		t.Errorf("expected the text at the top, got %v", img.At(0, 0))
+	}
+	if r, _, b, _ := img.At(0, 7).RGBA(); r != 0xffff || b != 0 {
+		t.Errorf("expected the background below the text, got %v", img.At(0, 7))
+	}
+}
+
+func TestCaptureFrameDiscardsHalfFrames(t *testing.T) {
+	page := testPage(t, `<canvas id="text"></canvas><canvas id="cursor"></canvas>`)
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+	v.TextCanvas = page.MustElement("#text")
+	v.CursorCanvas = page.MustElement("#cursor")
+	if err := os.MkdirAll(v.Options.Video.Input, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cursor frame can't be written over a directory.
+	cursorPath := filepath.Join(v.Options.Video.Input, fmt.Sprintf(cursorFrameFormat, 1))
+	if err := os.Mkdir(cursorPath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.captureFrame(1, 0); err == nil {
+		t.Fatal("expected an error writing the cursor frame")
+	}
+	textPath := filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, 1))
+	if _, err := os.Stat(textPath); !os.IsNotExist(err) {
+		t.Errorf("expected the text frame to be removed with the cursor frame, got %v", err)
+	}
+
+	// The frame is written whole once it can be.
+	_ = os.Remove(cursorPath)
+	if err := v.captureFrame(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{textPath, cursorPath} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected the frame to be written: %v", err)
+		}
+	}
+}
+
+func TestNextFrame(t *testing.T) {
+	start := time.Now()
+	interval := 20 * time.Millisecond
+	tests := []struct {
+		name     string
+		now      time.Duration
+		wantNext time.Duration
+		wantLate int
+	}{
+		{"on time", 0, 20 * time.Millisecond, 0},
+		{"early", -time.Millisecond, 20 * time.Millisecond, 0},
+		{"a bit late", 5 * time.Millisecond, 20 * time.Millisecond, 0},
+		{"a frame late", 25 * time.Millisecond, 40 * time.Millisecond, 1},
+		{"frames late", 65 * time.Millisecond, 80 * time.Millisecond, 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			next, late := nextFrame(start, start.Add(tc.now), interval)
+			if got := next.Sub(start); got != tc.wantNext || late != tc.wantLate {
+				t.Errorf("expected the next frame at %s with %d dropped, got %s with %d", tc.wantNext, tc.wantLate, got, late)
+			}
+		})
+	}
+}
+
+// BenchmarkRecord records a page drawing frames of the default size on each
+// animation frame, like xterm.js, at 60fps until b.N frames are captured, and
+// reports the frames which weren't captured on time as dropped.
+func BenchmarkRecord(b *testing.B) {
+	page := testPage(b, fmt.Sprintf(`<canvas id="text" width="%[1]d" height="%[2]d"></canvas>
+		<canvas id="cursor" width="%[1]d" height="%[2]d"></canvas>
+		<script>
+		let n = 0;
+		const draw = () => {
+			n = (n + 1) %% 256;
+			for (const id of ["text", "cursor"]) {
+				const ctx = document.getElementById(id).getContext("2d");
+				ctx.fillStyle = "rgb(" + n + ", 0, 0)";
+				ctx.fillRect(n %% 64, n %% 64, 64, 64);
+			}
+			requestAnimationFrame(draw);
+		};
+		requestAnimationFrame(draw);
+		</script>`, defaultWidth, defaultHeight))
+
+	const framerate = 60
+	v := New()
+	b.Cleanup(func() { _ = v.Cleanup() })
+	v.Page = page
+	v.TextCanvas = page.MustElement("#text")
+	v.CursorCanvas = page.MustElement("#cursor")
+	v.Options.Video.Framerate = framerate
+	if err := os.MkdirAll(v.Options.Video.Input, os.ModePerm); err != nil {
+		b.Fatal(err)
+	}
+
+	// The recording stops like it does when the shell exits, which leaves
+	// the browser running.
+	v.exited = make(chan struct{})
+	v.progress = func(p Progress) {
+		if p.Frames == b.N {
+			close(v.exited)
+		}
+	}
+	v.ResumeRecording()
+
+	b.ResetTimer()
+	for err := range v.Record(context.Background()) {
+		if !errors.Is(err, ErrTerminalExited) && !errors.Is(err, ErrFramesDropped) {
+			b.Error(err)
+		}
+	}
+	b.StopTimer()
+
+	// The frames between those captured more than an interval apart were
+	// dropped.
+	interval := time.Second / framerate
+	var dropped int
+	for i := 1; i < len(v.frameTimes); i++ {
+		dropped += int((v.frameTimes[i] - v.frameTimes[i-1] - interval/2) / interval)
+	}
+	b.ReportMetric(float64(dropped), "dropped")
+}
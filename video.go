@@ -5,27 +5,76 @@
 // MakeGIF takes several options to modify the behaviour of the ffmpeg process,
 // which can be configured through the Set command.
 //
-// Set MaxColors 256
+// Set GIFColors 256
+// Set GIFDither bayer
 package main
 
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/charmbracelet/vhs/parser"
+)
+
+// The frames are numbered with a fixed width, wide enough for days of
+// recording, so that they sort in order even after the loop offset numbers
+// frames past the total number of frames. ffmpeg's image2 demuxer is given the
+// same patterns, so the numbering stays consistent between the two.
+const (
+	textFrameFormat     = "frame-text-%08d.png"
+	jpegTextFrameFormat = "frame-text-%08d.jpg"
+	cursorFrameFormat   = "frame-cursor-%08d.png"
 )
 
+// The image formats of the frames, set with `Set FrameFormat`.
 const (
-	textFrameFormat   = "frame-text-%05d.png"
-	cursorFrameFormat = "frame-cursor-%05d.png"
+	framePNG  = "png"
+	frameJPEG = "jpeg"
 )
 
+// Default constant rate factors, balancing quality and size.
+const (
+	defaultMP4CRF  = 20
+	defaultWebMCRF = 30
+)
+
+// defaultPixelFormat is the pixel format of MP4 and WebM outputs, which
+// players like QuickTime and Safari need to play them.
+const defaultPixelFormat = "yuv420p"
+
+// pixelFormats are the pixel formats MP4 (H.264) or WebM (VP9) outputs can be
+// encoded with, as named by ffmpeg's -pix_fmt.
+var pixelFormats = []string{
+	"yuv420p", "yuvj420p", "yuv422p", "yuvj422p", "yuv440p", "yuv444p", "yuvj444p",
+	"yuva420p", "nv12", "nv16", "nv21", "gray", "gbrp",
+	"yuv420p10le", "yuv422p10le", "yuv440p10le", "yuv444p10le", "gray10le", "gbrp10le",
+	"yuv420p12le", "yuv422p12le", "yuv440p12le", "yuv444p12le", "gbrp12le",
+}
+
+// isPixelFormat returns whether the pixel format is one of the pixelFormats.
+func isPixelFormat(format string) bool {
+	for _, f := range pixelFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	mp4  = ".mp4"
 	webm = ".webm"
 	gif  = ".gif"
+	apng = ".apng"
+	cast = ".cast"
+	webp = ".webp"
 )
 
 // randomDir returns a random temporary directory to be used for storing frames
@@ -44,36 +93,368 @@ type VideoOutputs struct {
 	GIF    string
 	WebM   string
 	MP4    string
+	APNG   string
+	WebP   string
+	Cast   string
 	Frames string
 }
 
+// videos returns the number of video outputs rendered with ffmpeg.
+func (o VideoOutputs) videos() int {
+	var n int
+	for _, path := range []string{o.GIF, o.WebM, o.MP4, o.APNG, o.WebP} {
+		if path != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// videoTarget is a video output rendered with ffmpeg.
+type videoTarget struct {
+	format string
+	ext    string
+	path   string
+}
+
+// videoTargets returns the video outputs, in the order they are rendered. A
+// GIF is rendered when there are no outputs, like MakeGIF.
+func videoTargets(o VideoOutputs) []videoTarget {
+	all := []videoTarget{
+		{"GIF", gif, o.GIF},
+		{"MP4", mp4, o.MP4},
+		{"WebM", webm, o.WebM},
+		{"APNG", apng, o.APNG},
+		{"WebP", webp, o.WebP},
+	}
+	if o.videos() == 0 {
+		all[0].path = "out.gif"
+	}
+	var targets []videoTarget
+	for _, t := range all {
+		if t.path != "" {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// renderedOutputs returns the paths of the outputs rendered from the given
+// number of frames.
+func (opts VideoOptions) renderedOutputs(frames int) []string {
+	var paths []string
+	for _, t := range videoTargets(opts.Output) {
+		paths = append(paths, t.path)
+	}
+	if opts.Poster != "" && frames > 0 {
+		paths = append(paths, opts.Poster)
+	}
+	if opts.Output.Cast != "" {
+		paths = append(paths, opts.Output.Cast)
+	}
+	return paths
+}
+
+// withVariant returns the outputs with the variant added to the file names,
+// e.g. demo.gif becomes demo-dark.gif.
+func (o VideoOutputs) withVariant(variant string) VideoOutputs {
+	return VideoOutputs{
+		GIF:    variantPath(o.GIF, variant),
+		WebM:   variantPath(o.WebM, variant),
+		MP4:    variantPath(o.MP4, variant),
+		APNG:   variantPath(o.APNG, variant),
+		WebP:   variantPath(o.WebP, variant),
+		Cast:   variantPath(o.Cast, variant),
+		Frames: variantPath(o.Frames, variant),
+	}
+}
+
+// variantPath adds the variant to the path, before its extension. Trailing
+// slashes of directories are kept.
+func variantPath(path, variant string) string {
+	if path == "" || variant == "" {
+		return path
+	}
+	base := strings.TrimRight(path, "/")
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-" + variant + ext + path[len(base):]
+}
+
 // VideoOptions is the set of options for converting frames to a GIF.
 type VideoOptions struct {
-	Framerate     int
-	PlaybackSpeed float64
-	Input         string
-	MaxColors     int
+	Framerate int
+	// CaptureFramerate is the rate at which frames are captured from the
+	// terminal. When zero, Framerate is used.
+	CaptureFramerate int
+	// VariableFramerate holds each frame for as long as it was shown while
+	// recording, rather than for 1/CaptureFramerate, so that the output plays
+	// in time even when captures stall.
+	VariableFramerate bool
+	// frameDurations are the durations of the frames of the output, in
+	// order, once they are written for VariableFramerate.
+	frameDurations []time.Duration
+	// CaptureQuality is the quality, from 0 to 1, the frames are encoded
+	// with by the browser as they are captured. Lower qualities are faster
+	// to encode and smaller on disk for lossy image formats, while PNG frames
+	// are lossless and ignore it.
+	CaptureQuality float64
+	PlaybackSpeed  float64
+	// FrameFormat is the image format of the text frames, png or jpeg. JPEG
+	// frames are faster to capture and smaller on disk, but lossy. The cursor
+	// frames keep their transparency, so they are always PNG images, and
+	// CompositeInGo draws the cursor onto JPEG frames as JPEG images.
+	FrameFormat string
+	Input       string
+	// StreamFrames pipes the frames into ffmpeg as they are captured, rather
+	// than writing them to the Input directory and rendering them once the
+	// recording ends, so that long recordings don't fill up the disk. The
+	// cursor is drawn onto the frames while recording, like CompositeInGo,
+	// since ffmpeg reads a single stream of frames.
+	StreamFrames bool
+	// HideCursor skips capturing the cursor, so only the text frames are
+	// recorded and rendered.
+	HideCursor bool
+	// Boomerang plays the frames forward and then backward.
+	Boomerang bool
+	// Crop is the rectangle of the terminal the video is cropped to, if any.
+	Crop Crop
+	// TrimStart and TrimEnd cut frames off the start and end of the
+	// recording before it is rendered.
+	TrimStart Trim
+	TrimEnd   Trim
+	// CompositeInGo draws the cursor onto the text frames while recording,
+	// so a single frame is written per capture and ffmpeg doesn't have to
+	// overlay them. This halves the frames written to disk at the cost of
+	// decoding and encoding each frame while recording.
+	CompositeInGo bool
+	// Scale is the device scale factor of the terminal, e.g. 2 for a retina
+	// display. The frames are captured at Scale times the resolution and
+	// downscaled to the output dimensions, which makes text sharper. The
+	// frames have Scale² times the pixels, which makes capturing them slower
+	// and uses more disk space.
+	Scale float64
+	// MaxColors is the size of the GIF palette.
+	MaxColors int
+	// Dither is the dithering algorithm used for GIFs. When empty, ffmpeg's
+	// default is used.
+	Dither        string
 	Output        VideoOutputs
 	StartingFrame int
 	Style         *StyleOptions
+	// CRF is the constant rate factor of MP4 and WebM outputs, lower values
+	// mean better quality and bigger files. When zero, a default is used.
+	CRF int
+	// Bitrate caps the bitrate of MP4 and WebM outputs (e.g. 500K, 2M).
+	Bitrate string
+	// PixelFormat is the pixel format of MP4 and WebM outputs. When empty,
+	// yuv420p is used, which plays everywhere.
+	PixelFormat string
+	// FFmpegPath is the ffmpeg binary used to render. When empty, ffmpeg is
+	// looked up in the $PATH.
+	FFmpegPath string
+	// ExtraArgs are passed to ffmpeg right before the output file.
+	ExtraArgs []string
+	// SkipBlankFrames trims off the frames at the start of the recording
+	// which only show the background, e.g. while the prompt renders.
+	SkipBlankFrames bool
+	// KeySound adds the sound of the key presses to MP4 and WebM outputs.
+	KeySound bool
+	// Audio is an audio file, e.g. a narration, played over MP4 and WebM
+	// outputs. It's cut off at the end of the video, and followed by silence
+	// if it's shorter, unless AudioLoop plays it over and over.
+	Audio     string
+	AudioLoop bool
+	// Captions are drawn over the output while they are shown, in place of
+	// the caption of the style.
+	Captions []TimedCaption
+	// TextDump is a file, or a directory, the text of the terminal is written
+	// to for each frame captured, e.g. to compare the contents of the terminal
+	// in tests.
+	TextDump string
+	// Parallel creates the outputs at once, up to one per CPU, rather than
+	// one after the other. This is faster with several outputs, at the cost
+	// of more memory.
+	Parallel bool
+	// Poster is a PNG or JPEG image of a single frame of the output, e.g. to
+	// show before a video is played on the web.
+	Poster string
+	// LoopCount is the number of times GIF and APNG outputs are played
+	// again after the first time: 0 loops forever, and -1 plays them once.
+	LoopCount int
+	// Dedup collapses the runs of identical frames of GIF outputs, e.g.
+	// while the demo pauses, into single frames held for as long, which
+	// makes the GIFs smaller.
+	Dedup bool
+	// lastDuplicates is the number of identical frames ending the frames,
+	// which the last frame of a deduplicated GIF is held for.
+	lastDuplicates int
+	// PosterFrame is the position of the poster frame in the output, as a
+	// percentage from the second frame (0) to the last one (100). The first
+	// frame is skipped since it's usually blank.
+	PosterFrame float64
+}
+
+// Crop is a rectangle of the terminal, including its padding, the video is
+// cropped to. An empty rectangle doesn't crop anything.
+type Crop struct {
+	X, Y          int
+	Width, Height int
+}
+
+// parseCrop parses the position and size of a crop, e.g. 0 0 400 200.
+func parseCrop(s string) (Crop, error) {
+	var crop Crop
+	if len(strings.Fields(s)) != 4 { //nolint:gomnd
+		return Crop{}, fmt.Errorf("expected the x, y, width and height of the crop, got %s", s)
+	}
+	if _, err := fmt.Sscan(s, &crop.X, &crop.Y, &crop.Width, &crop.Height); err != nil {
+		return Crop{}, fmt.Errorf("expected the x, y, width and height of the crop, got %s", s)
+	}
+	if crop.X < 0 || crop.Y < 0 || crop.Width <= 0 || crop.Height <= 0 {
+		return Crop{}, fmt.Errorf("expected a position inside the terminal and a positive size, got %s", s)
+	}
+	return crop, nil
+}
+
+// Trim is an amount cut off the recording, either a number of frames or a
+// duration.
+type Trim struct {
+	Frames   int
+	Duration time.Duration
+}
+
+// parseTrim parses a number of frames, or a duration with a unit.
+func parseTrim(s string) (Trim, error) {
+	if !parser.IsValidTrim(s) {
+		return Trim{}, fmt.Errorf("expected a number of frames or a duration, got %s", s)
+	}
+	if frames, err := strconv.Atoi(s); err == nil {
+		return Trim{Frames: frames}, nil
+	}
+	d, _ := time.ParseDuration(s)
+	return Trim{Duration: d}, nil
+}
+
+// frames returns the number of frames trimmed at the given framerate.
+func (t Trim) frames(framerate int) int {
+	if t.Duration > 0 {
+		return int(math.Round(t.Duration.Seconds() * float64(framerate)))
+	}
+	return t.Frames
 }
 
 const (
-	defaultFramerate     = 50
-	defaultStartingFrame = 1
+	defaultFramerate      = 50
+	defaultScale          = 1.0
+	defaultStartingFrame  = 1
+	defaultCaptureQuality = 1.0
 )
 
 // DefaultVideoOptions is the set of default options for converting frames
 // to a GIF, which are used if they are not overridden.
 func DefaultVideoOptions() VideoOptions {
 	return VideoOptions{
-		Framerate:     defaultFramerate,
-		Input:         randomDir(),
-		MaxColors:     defaultMaxColors,
-		Output:        VideoOutputs{GIF: "", WebM: "", MP4: "", Frames: ""},
-		PlaybackSpeed: defaultPlaybackSpeed,
-		StartingFrame: defaultStartingFrame,
+		Framerate:      defaultFramerate,
+		CaptureQuality: defaultCaptureQuality,
+		Input:          randomDir(),
+		MaxColors:      defaultMaxColors,
+		Output:         VideoOutputs{GIF: "", WebM: "", MP4: "", APNG: "", WebP: "", Frames: ""},
+		PlaybackSpeed:  defaultPlaybackSpeed,
+		Scale:          defaultScale,
+		StartingFrame:  defaultStartingFrame,
+	}
+}
+
+// captureFramerate returns the rate at which frames are captured, falling
+// back to the output framerate when no capture framerate is set. It is never
+// zero, so that it is safe to compute the capture interval from it.
+func (opts VideoOptions) captureFramerate() int {
+	if opts.CaptureFramerate > 0 {
+		return opts.CaptureFramerate
+	}
+	if opts.Framerate > 0 {
+		return opts.Framerate
 	}
+	return defaultFramerate
+}
+
+// variableFramerate reports whether the frames are read with their durations,
+// from the concat demuxer files, rather than at the capture framerate.
+func (opts VideoOptions) variableFramerate() bool {
+	return opts.VariableFramerate && len(opts.frameDurations) > 0
+}
+
+// textFrames returns the pattern of the names of the text frames, which have
+// the extension of the FrameFormat.
+func (opts VideoOptions) textFrames() string {
+	if opts.FrameFormat == frameJPEG {
+		return jpegTextFrameFormat
+	}
+	return textFrameFormat
+}
+
+// frameMIME returns the MIME type the text frames are captured as.
+func (opts VideoOptions) frameMIME() string {
+	if opts.FrameFormat == frameJPEG {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// pixelFormat returns the pixel format of MP4 and WebM outputs.
+func (opts VideoOptions) pixelFormat() string {
+	if opts.PixelFormat == "" {
+		return defaultPixelFormat
+	}
+	return opts.PixelFormat
+}
+
+// outputDimensions returns the width and height of the outputs, before they
+// are padded to even dimensions.
+func (opts VideoOptions) outputDimensions() (int, int) {
+	if opts.Crop.Width > 0 && opts.Crop.Height > 0 {
+		return opts.Crop.Width, opts.Crop.Height
+	}
+	return opts.Style.Width, opts.Style.Height
+}
+
+// evenDimensions returns the given dimensions rounded up to even numbers,
+// which yuv420p and H.264 need.
+func evenDimensions(width, height int) (int, int) {
+	return width + width%2, height + height%2
+}
+
+// cursorFrames reports whether cursor frames are written separately from
+// the text frames and need to be overlaid by ffmpeg.
+func (opts VideoOptions) cursorFrames() bool {
+	return !opts.HideCursor && !opts.compositeCursor()
+}
+
+// compositeCursor reports whether the cursor is drawn onto the text frames
+// while recording.
+func (opts VideoOptions) compositeCursor() bool {
+	return opts.CompositeInGo || opts.StreamFrames
+}
+
+// ffmpeg returns the ffmpeg binary to render with.
+func (opts VideoOptions) ffmpeg() string {
+	return ffmpegBin(opts.FFmpegPath)
+}
+
+// ffmpegBin returns the given ffmpeg binary, or ffmpeg if none is given.
+func ffmpegBin(path string) string {
+	if path == "" {
+		return "ffmpeg"
+	}
+	return path
+}
+
+// checkFFmpeg returns an error if the given ffmpeg binary can't be executed.
+func checkFFmpeg(path string) error {
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("ffmpeg not found at %s. Install it from: http://ffmpeg.org", path)
+	}
+	return nil
 }
 
 func marginFillIsColor(marginFill string) bool {
@@ -92,45 +473,96 @@ func ensureDir(output string) {
 // buildFFopts assembles an ffmpeg command from some VideoOptions
 func buildFFopts(opts VideoOptions, targetFile string) []string {
 	var args []string
-	streamCounter := 2
+	streamCounter := 1
+	if opts.cursorFrames() {
+		streamCounter = 2
+	}
 
 	streamBuilder := NewStreamBuilder(streamCounter, opts.Input, opts.Style)
 
 	// Input frame options, used no matter what
 	// Stream 0: text frames
-	// Stream 1: cursor frames
-	streamBuilder.args = append(streamBuilder.args,
-		"-y",
-		"-r", fmt.Sprint(opts.Framerate),
-		"-start_number", fmt.Sprint(opts.StartingFrame),
-		"-i", filepath.Join(opts.Input, textFrameFormat),
-		"-r", fmt.Sprint(opts.Framerate),
-		"-start_number", fmt.Sprint(opts.StartingFrame),
-		"-i", filepath.Join(opts.Input, cursorFrameFormat),
-	)
+	// Stream 1: cursor frames, unless the cursor is hidden or composited
+	streamBuilder.args = append(streamBuilder.args, "-y")
+	switch {
+	case opts.StreamFrames:
+		streamBuilder.args = append(streamBuilder.args,
+			"-f", "image2pipe",
+			"-r", fmt.Sprint(opts.captureFramerate()),
+			"-i", "-",
+		)
+	case opts.variableFramerate():
+		streamBuilder.args = append(streamBuilder.args,
+			"-f", "concat",
+			"-i", filepath.Join(opts.Input, textConcatFile),
+		)
+		if opts.cursorFrames() {
+			streamBuilder.args = append(streamBuilder.args,
+				"-f", "concat",
+				"-i", filepath.Join(opts.Input, cursorConcatFile),
+			)
+		}
+	default:
+		streamBuilder.args = append(streamBuilder.args,
+			"-r", fmt.Sprint(opts.captureFramerate()),
+			"-start_number", fmt.Sprint(opts.StartingFrame),
+			"-i", filepath.Join(opts.Input, opts.textFrames()),
+		)
+		if opts.cursorFrames() {
+			streamBuilder.args = append(streamBuilder.args,
+				"-r", fmt.Sprint(opts.captureFramerate()),
+				"-start_number", fmt.Sprint(opts.StartingFrame),
+				"-i", filepath.Join(opts.Input, cursorFrameFormat),
+			)
+		}
+	}
 
 	streamBuilder = streamBuilder.
 		WithMargin().
 		WithBar().
 		WithCorner()
 
+	// Only MP4 and WebM outputs have sound.
+	if ext := filepath.Ext(targetFile); ext == mp4 || ext == webm {
+		if opts.Audio != "" {
+			streamBuilder = streamBuilder.WithAudio(opts.Audio, opts.AudioLoop)
+		}
+		if opts.KeySound {
+			streamBuilder = streamBuilder.WithAudio(filepath.Join(opts.Input, keySoundFile), false)
+		}
+	}
+
 	filterBuilder := NewVideoFilterBuilder(&opts).
 		WithWindowBar(streamBuilder.barStream).
 		WithBorderRadius(streamBuilder.cornerStream).
-		WithMarginFill(streamBuilder.marginStream)
+		WithMarginFill(streamBuilder.marginStream).
+		WithCrop(opts.Crop).
+		WithCaption(opts.Input, opts.Captions).
+		WithEvenDimensions(opts.outputDimensions()).
+		WithAudio(streamBuilder.audioStreams)
 
 	// Format-specific options
 	switch filepath.Ext(targetFile) {
 	case gif:
-		filterBuilder = filterBuilder.WithGIF()
+		if opts.Dedup {
+			filterBuilder = filterBuilder.WithDedup()
+			streamBuilder = streamBuilder.WithDedup(opts.finalDelay())
+		}
+		filterBuilder = filterBuilder.WithGIF(opts.MaxColors, opts.Dither)
+		streamBuilder = streamBuilder.WithGIF(opts.LoopCount)
 	case webm:
-		streamBuilder = streamBuilder.WithWebm()
+		streamBuilder = streamBuilder.WithWebm(opts.CRF, opts.Bitrate, opts.pixelFormat())
 	case mp4:
-		streamBuilder = streamBuilder.WithMP4()
+		streamBuilder = streamBuilder.WithMP4(opts.CRF, opts.Bitrate, opts.pixelFormat())
+	case apng:
+		streamBuilder = streamBuilder.WithAPNG(opts.LoopCount)
+	case webp:
+		streamBuilder = streamBuilder.WithWebP()
 	}
 
 	args = append(args, streamBuilder.Build()...)
 	args = append(args, filterBuilder.Build()...)
+	args = append(args, opts.ExtraArgs...)
 	args = append(args, targetFile)
 
 	return args
@@ -140,18 +572,17 @@ func buildFFopts(opts VideoOptions, targetFile string) []string {
 func MakeGIF(opts VideoOptions) *exec.Cmd {
 	targetFile := opts.Output.GIF
 
-	if opts.Output.GIF == "" && opts.Output.WebM == "" && opts.Output.MP4 == "" {
+	if opts.Output.GIF == "" && opts.Output.WebM == "" && opts.Output.MP4 == "" && opts.Output.APNG == "" && opts.Output.WebP == "" {
 		targetFile = "out.gif"
 	} else if opts.Output.GIF == "" {
 		return nil
 	}
 
-	log.Println(GrayStyle.Render("Creating " + opts.Output.GIF + "..."))
 	ensureDir(opts.Output.GIF)
 
 	//nolint:gosec
 	return exec.Command(
-		"ffmpeg",
+		opts.ffmpeg(),
 		buildFFopts(opts, targetFile)...,
 	)
 }
@@ -162,12 +593,11 @@ func MakeWebM(opts VideoOptions) *exec.Cmd {
 		return nil
 	}
 
-	log.Println(GrayStyle.Render("Creating " + opts.Output.WebM + "..."))
 	ensureDir(opts.Output.WebM)
 
 	//nolint:gosec
 	return exec.Command(
-		"ffmpeg",
+		opts.ffmpeg(),
 		buildFFopts(opts, opts.Output.WebM)...,
 	)
 }
@@ -178,12 +608,96 @@ func MakeMP4(opts VideoOptions) *exec.Cmd {
 		return nil
 	}
 
-	log.Println(GrayStyle.Render("Creating " + opts.Output.MP4 + "..."))
 	ensureDir(opts.Output.MP4)
 
 	//nolint:gosec
 	return exec.Command(
-		"ffmpeg",
+		opts.ffmpeg(),
 		buildFFopts(opts, opts.Output.MP4)...,
 	)
 }
+
+// MakeAPNG takes a list of images (as frames) and converts them to an
+// animated PNG.
+func MakeAPNG(opts VideoOptions) *exec.Cmd {
+	if opts.Output.APNG == "" {
+		return nil
+	}
+
+	ensureDir(opts.Output.APNG)
+
+	//nolint:gosec
+	return exec.Command(
+		opts.ffmpeg(),
+		buildFFopts(opts, opts.Output.APNG)...,
+	)
+}
+
+// posterFormats are the extensions of the poster images.
+var posterFormats = map[string]bool{".png": true, ".jpg": true, ".jpeg": true}
+
+// posterFrame returns the frame of the poster, out of the given number of
+// frames from the starting frame.
+func posterFrame(opts VideoOptions, frames int) int {
+	if frames < 2 { //nolint:gomnd
+		return opts.StartingFrame
+	}
+	pos := 1 + int(math.Round(opts.PosterFrame/100*float64(frames-2))) //nolint:gomnd
+	return opts.StartingFrame + pos
+}
+
+// MakePoster takes the poster frame out of the frames and renders it like the
+// videos, as a PNG or JPEG image.
+func MakePoster(opts VideoOptions, frames int) *exec.Cmd {
+	if opts.Poster == "" || frames <= 0 {
+		return nil
+	}
+
+	ensureDir(opts.Poster)
+
+	// Render the video from the poster frame on, and stop after the first
+	// frame. The captions are moved to the start with it.
+	frame := posterFrame(opts, frames)
+	tl := timeline{
+		framerate: opts.captureFramerate(),
+		speed:     opts.PlaybackSpeed,
+		durations: opts.frameDurations,
+	}
+	t := tl.seconds(frame - opts.StartingFrame)
+	var captions []TimedCaption
+	for _, c := range opts.Captions {
+		if c.Start <= t && t < c.End {
+			captions = append(captions, TimedCaption{Text: c.Text, Start: 0, End: c.End - t, Index: c.Index})
+		}
+	}
+	// The poster is a single frame, so its frames are read from the poster
+	// frame on regardless of their durations.
+	opts.StartingFrame = frame
+	opts.frameDurations = nil
+	opts.Captions = captions
+	// The FFmpegArgs still apply, with the single frame taking precedence.
+	// They are copied, so the videos' arguments aren't changed.
+	opts.ExtraArgs = append(append([]string{}, opts.ExtraArgs...), "-frames:v", "1", "-update", "1")
+
+	//nolint:gosec
+	return exec.Command(
+		opts.ffmpeg(),
+		buildFFopts(opts, opts.Poster)...,
+	)
+}
+
+// MakeWebP takes a list of images (as frames) and converts them to an
+// animated WebP.
+func MakeWebP(opts VideoOptions) *exec.Cmd {
+	if opts.Output.WebP == "" {
+		return nil
+	}
+
+	ensureDir(opts.Output.WebP)
+
+	//nolint:gosec
+	return exec.Command(
+		opts.ffmpeg(),
+		buildFFopts(opts, opts.Output.WebP)...,
+	)
+}
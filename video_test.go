@@ -0,0 +1,700 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testVideoOptions(tb testing.TB) VideoOptions {
+	tb.Helper()
+	opts := DefaultVideoOptions()
+	opts.Style = DefaultStyleOptions()
+	tb.Cleanup(func() { _ = os.RemoveAll(opts.Input) })
+	return opts
+}
+
+func TestBuildFFoptsAPNG(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.StartingFrame = 5
+
+	args := strings.Join(buildFFopts(opts, "out.apng"), " ")
+
+	if !strings.Contains(args, "-f apng -plays 0") {
+		t.Errorf("expected apng muxer options, got: %s", args)
+	}
+	if !strings.Contains(args, "-start_number 5") {
+		t.Errorf("expected starting frame to be honored, got: %s", args)
+	}
+	if !strings.HasSuffix(args, "out.apng") {
+		t.Errorf("expected output file to be last argument, got: %s", args)
+	}
+}
+
+func TestBuildFFoptsLoopCount(t *testing.T) {
+	opts := testVideoOptions(t)
+	if args := strings.Join(buildFFopts(opts, "out.gif"), " "); !strings.Contains(args, "-loop 0") {
+		t.Errorf("expected the GIF to loop forever by default, got: %s", args)
+	}
+
+	tests := []struct {
+		loopCount int
+		gif, apng string
+	}{
+		{-1, "-loop -1", "-plays 1"},
+		{3, "-loop 3", "-plays 4"},
+	}
+	for _, tc := range tests {
+		opts.LoopCount = tc.loopCount
+		if args := strings.Join(buildFFopts(opts, "out.gif"), " "); !strings.Contains(args, tc.gif) {
+			t.Errorf("expected %q for a loop count of %d, got: %s", tc.gif, tc.loopCount, args)
+		}
+		if args := strings.Join(buildFFopts(opts, "out.apng"), " "); !strings.Contains(args, tc.apng) {
+			t.Errorf("expected %q for a loop count of %d, got: %s", tc.apng, tc.loopCount, args)
+		}
+	}
+}
+
+func TestMakeAPNG(t *testing.T) {
+	opts := testVideoOptions(t)
+
+	if cmd := MakeAPNG(opts); cmd != nil {
+		t.Errorf("expected nil command when APNG output is unset")
+	}
+}
+
+func TestBuildFFoptsWebP(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.Framerate = 30
+
+	args := strings.Join(buildFFopts(opts, "out.webp"), " ")
+
+	if !strings.Contains(args, "-vcodec libwebp -pix_fmt yuva420p") {
+		t.Errorf("expected webp encoder options, got: %s", args)
+	}
+	if !strings.Contains(args, "fps=30") {
+		t.Errorf("expected framerate to be honored, got: %s", args)
+	}
+}
+
+func TestCaptureFramerate(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.Framerate = 25
+
+	if got := opts.captureFramerate(); got != 25 {
+		t.Errorf("expected capture framerate to fall back to 25, got %d", got)
+	}
+
+	opts.CaptureFramerate = 60
+	if got := opts.captureFramerate(); got != 60 {
+		t.Errorf("expected capture framerate to be 60, got %d", got)
+	}
+
+	args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+	if !strings.Contains(args, "-r 60") {
+		t.Errorf("expected input rate to be the capture framerate, got: %s", args)
+	}
+	if !strings.Contains(args, "fps=25") {
+		t.Errorf("expected output rate to be the framerate, got: %s", args)
+	}
+
+	opts.CaptureFramerate, opts.Framerate = 0, 0
+	if got := opts.captureFramerate(); got != defaultFramerate {
+		t.Errorf("expected capture framerate to fall back to the default, got %d", got)
+	}
+}
+
+func TestFFmpegOptions(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.Output.MP4 = "out.mp4"
+	opts.FFmpegPath = "/opt/ffmpeg/bin/ffmpeg"
+	opts.ExtraArgs = []string{"-tune", "animation"}
+
+	cmd := MakeMP4(opts)
+	if cmd.Args[0] != opts.FFmpegPath {
+		t.Errorf("expected ffmpeg path %s, got %s", opts.FFmpegPath, cmd.Args[0])
+	}
+
+	args := strings.Join(cmd.Args, " ")
+	if !strings.HasSuffix(args, "-tune animation out.mp4") {
+		t.Errorf("expected extra args right before the output file, got: %s", args)
+	}
+}
+
+func TestCheckFFmpeg(t *testing.T) {
+	if err := checkFFmpeg("vhs-missing-ffmpeg"); err == nil {
+		t.Errorf("expected an error for a missing ffmpeg binary")
+	}
+}
+
+func TestBuildFFoptsQuality(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		opts := testVideoOptions(t)
+
+		if args := strings.Join(buildFFopts(opts, "out.mp4"), " "); !strings.Contains(args, "-crf 20") {
+			t.Errorf("expected default mp4 crf, got: %s", args)
+		}
+		if args := strings.Join(buildFFopts(opts, "out.webm"), " "); !strings.Contains(args, "-crf 30 -b:v 0") {
+			t.Errorf("expected default webm crf, got: %s", args)
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		opts := testVideoOptions(t)
+		opts.CRF = 28
+		opts.Bitrate = "2M"
+
+		if args := strings.Join(buildFFopts(opts, "out.mp4"), " "); !strings.Contains(args, "-crf 28 -maxrate 2M -bufsize 2M") {
+			t.Errorf("expected custom mp4 quality, got: %s", args)
+		}
+		if args := strings.Join(buildFFopts(opts, "out.webm"), " "); !strings.Contains(args, "-crf 28 -b:v 2M") {
+			t.Errorf("expected custom webm quality, got: %s", args)
+		}
+	})
+}
+
+func TestBuildFFoptsGIFPalette(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		opts := testVideoOptions(t)
+
+		args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+		if !strings.Contains(args, "palettegen=max_colors=256[plt]") {
+			t.Errorf("expected default palette size, got: %s", args)
+		}
+		if !strings.Contains(args, "[plt_b][plt]paletteuse[palette]") {
+			t.Errorf("expected default dithering, got: %s", args)
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		opts := testVideoOptions(t)
+		opts.MaxColors = 64
+		opts.Dither = "bayer"
+
+		args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+		if !strings.Contains(args, "palettegen=max_colors=64[plt]") {
+			t.Errorf("expected custom palette size, got: %s", args)
+		}
+		if !strings.Contains(args, "paletteuse=dither=bayer[palette]") {
+			t.Errorf("expected custom dithering, got: %s", args)
+		}
+	})
+}
+
+func TestBuildFFoptsSingleStream(t *testing.T) {
+	tests := map[string]func(*VideoOptions){
+		"hide cursor":     func(opts *VideoOptions) { opts.HideCursor = true },
+		"composite in go": func(opts *VideoOptions) { opts.CompositeInGo = true },
+	}
+	for name, set := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := testVideoOptions(t)
+			set(&opts)
+			opts.Style.WindowBar = "Colorful"
+
+			args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+			if strings.Contains(args, "frame-cursor") {
+				t.Errorf("expected no cursor frames input, got: %s", args)
+			}
+			if strings.Contains(args, "overlay[merged]") {
+				t.Errorf("expected no cursor overlay, got: %s", args)
+			}
+			if !strings.Contains(args, "[0]scale=") {
+				t.Errorf("expected text frames to be scaled directly, got: %s", args)
+			}
+			if !strings.Contains(args, "[1]scale=1200:600[bg]") || !strings.Contains(args, "[2]loop=-1[loopbar]") {
+				t.Errorf("expected margin and window bar to follow the text frames, got: %s", args)
+			}
+		})
+	}
+}
+
+func TestRenderFFmpegFailure(t *testing.T) {
+	ffmpeg, err := exec.LookPath("false")
+	if err != nil {
+		t.Skip("false is not available")
+	}
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	dir := t.TempDir()
+	v.totalFrames = 1
+	v.Options.Video.FFmpegPath = ffmpeg
+	v.Options.Video.Output.GIF = filepath.Join(dir, "out.gif")
+	v.Options.Video.Output.MP4 = filepath.Join(dir, "out.mp4")
+
+	err = v.Render()
+	var renderErr RenderError
+	if !errors.As(err, &renderErr) {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	if len(renderErr.Errors) != 2 {
+		t.Fatalf("expected both outputs to fail, got %v", renderErr.Errors)
+	}
+
+	gifErr := renderErr.Errors[0]
+	if gifErr.Format != "GIF" || gifErr.Output != v.Options.Video.Output.GIF || gifErr.ExitCode != 1 {
+		t.Errorf("expected GIF to fail with exit code 1, got %+v", gifErr)
+	}
+	if !strings.Contains(err.Error(), "could not create MP4 "+v.Options.Video.Output.MP4) {
+		t.Errorf("expected error to name the MP4 output, got %q", err)
+	}
+}
+
+func TestRenderParallel(t *testing.T) {
+	ffmpeg, err := exec.LookPath("false")
+	if err != nil {
+		t.Skip("false is not available")
+	}
+
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	dir := t.TempDir()
+	v.totalFrames = 1
+	v.Options.Video.FFmpegPath = ffmpeg
+	v.Options.Video.Parallel = true
+	v.Options.Video.Output.GIF = filepath.Join(dir, "out.gif")
+	v.Options.Video.Output.MP4 = filepath.Join(dir, "out.mp4")
+	v.Options.Video.Output.WebM = filepath.Join(dir, "out.webm")
+
+	var rendered int
+	v.progress = func(p Progress) { rendered = p.Rendered }
+	err = v.Render()
+	var renderErr RenderError
+	if !errors.As(err, &renderErr) {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	if rendered != 3 {
+		t.Errorf("expected the 3 outputs to be reported, got %d", rendered)
+	}
+	var formats []string
+	for _, err := range renderErr.Errors {
+		formats = append(formats, err.Format)
+	}
+	if want := []string{"GIF", "MP4", "WebM"}; !reflect.DeepEqual(formats, want) {
+		t.Errorf("expected the errors in the order of the outputs %v, got %v", want, formats)
+	}
+}
+
+// BenchmarkRender compares creating the outputs one after the other and at
+// once, with an ffmpeg which takes 100ms per output.
+func BenchmarkRender(b *testing.B) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		b.Skip("sh is not available")
+	}
+	ffmpeg := filepath.Join(b.TempDir(), "ffmpeg")
+	requireNoErr(b, os.WriteFile(ffmpeg, []byte("#!/bin/sh\nsleep 0.1\n"), 0o755)) //nolint:gosec
+
+	for _, parallel := range []bool{false, true} {
+		b.Run(fmt.Sprintf("parallel=%t", parallel), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				v := New()
+				dir := b.TempDir()
+				v.totalFrames = 1
+				v.Options.Video.FFmpegPath = ffmpeg
+				v.Options.Video.Parallel = parallel
+				v.Options.Video.Output.GIF = filepath.Join(dir, "out.gif")
+				v.Options.Video.Output.MP4 = filepath.Join(dir, "out.mp4")
+				v.Options.Video.Output.WebM = filepath.Join(dir, "out.webm")
+				requireNoErr(b, v.Render())
+				_ = v.Cleanup()
+			}
+		})
+	}
+}
+
+func TestBuildFFoptsPlaybackSpeed(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.PlaybackSpeed = 2
+
+	args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+	if !strings.Contains(args, "[scaled]setpts=PTS/2.000000,fps=50[speed]") {
+		t.Errorf("expected speed to be changed before the framerate is applied, got: %s", args)
+	}
+}
+
+func TestBuildFFoptsScale(t *testing.T) {
+	opts := testVideoOptions(t)
+	if args := strings.Join(buildFFopts(opts, "out.gif"), " "); strings.Contains(args, "flags=lanczos") {
+		t.Errorf("expected the default scaler without a scale, got: %s", args)
+	}
+
+	opts.Scale = 2
+	if args := strings.Join(buildFFopts(opts, "out.gif"), " "); !strings.Contains(args, "force_original_aspect_ratio=1:flags=lanczos[scaled]") {
+		t.Errorf("expected scaled frames to be downscaled with lanczos, got: %s", args)
+	}
+}
+
+func TestApplyBoomerang(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.Options.Video.Boomerang = true
+	v.Options.Video.HideCursor = true
+	v.Options.Video.StartingFrame = 3
+	v.totalFrames = 4
+
+	// Frames 3 to 6, e.g. after a loop offset of 2 frames.
+	for frame := 3; frame <= 6; frame++ {
+		path := filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, frame))
+		requireNoErr(t, os.WriteFile(path, []byte(fmt.Sprint(frame)), os.ModePerm))
+	}
+
+	requireNoErr(t, v.ApplyBoomerang())
+	if v.totalFrames != 6 {
+		t.Errorf("expected 6 frames, got %d", v.totalFrames)
+	}
+
+	want := []string{"3", "4", "5", "6", "5", "4"}
+	for i, w := range want {
+		bts, err := os.ReadFile(filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, i+3)))
+		requireNoErr(t, err)
+		if string(bts) != w {
+			t.Errorf("expected frame %d to be a copy of frame %s, got %s", i+3, w, bts)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, 9))); err == nil {
+		t.Error("expected the first frame not to be repeated at the end")
+	}
+}
+
+func TestApplyLoopOffset(t *testing.T) {
+	setup := func(t *testing.T, frames ...int) VHS {
+		t.Helper()
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+		v.Options.Video.HideCursor = true
+		v.Options.LoopOffset = 50
+		v.totalFrames = 4
+		for _, frame := range frames {
+			path := filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, frame))
+			requireNoErr(t, os.WriteFile(path, []byte(fmt.Sprint(frame)), os.ModePerm))
+		}
+		return v
+	}
+
+	for _, tc := range []struct {
+		offset        float64
+		startingFrame int
+		// sequence is the original frame at each frame number, from the
+		// starting frame on.
+		sequence []string
+	}{
+		{offset: 25, startingFrame: 2, sequence: []string{"2", "3", "4", "1"}},
+		{offset: 50, startingFrame: 3, sequence: []string{"3", "4", "1", "2"}},
+		{offset: 0, startingFrame: 1, sequence: []string{"1", "2", "3", "4"}},
+		// A full loop wraps around to the first frame.
+		{offset: 100, startingFrame: 1, sequence: []string{"1", "2", "3", "4"}},
+	} {
+		t.Run(fmt.Sprintf("%v%%", tc.offset), func(t *testing.T) {
+			v := setup(t, 1, 2, 3, 4)
+			v.Options.LoopOffset = tc.offset
+			requireNoErr(t, v.ApplyLoopOffset())
+			if v.Options.Video.StartingFrame != tc.startingFrame {
+				t.Errorf("expected starting frame %d, got %d", tc.startingFrame, v.Options.Video.StartingFrame)
+			}
+			for i, want := range tc.sequence {
+				frame := tc.startingFrame + i
+				bts, err := os.ReadFile(filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, frame)))
+				requireNoErr(t, err)
+				if string(bts) != want {
+					t.Errorf("expected frame %d to be frame %s, got %s", frame, want, bts)
+				}
+			}
+		})
+	}
+
+	t.Run("rename failure", func(t *testing.T) {
+		// The first frame is missing, so it can't be renamed.
+		v := setup(t, 2, 3, 4)
+		err := v.ApplyLoopOffset()
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected a missing frame error, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "1 frame(s)") {
+			t.Errorf("expected error to count the failed frames, got %q", err)
+		}
+		if _, err := os.Stat(filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, 6))); err != nil {
+			t.Errorf("expected the other frames to still be renamed, got %v", err)
+		}
+	})
+}
+
+func TestApplyTrim(t *testing.T) {
+	setup := func(t *testing.T) VHS {
+		t.Helper()
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+		v.Options.Video.HideCursor = true
+		v.totalFrames = 6
+		for frame := 1; frame <= 6; frame++ {
+			path := filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, frame))
+			requireNoErr(t, os.WriteFile(path, []byte(fmt.Sprint(frame)), os.ModePerm))
+		}
+		return v
+	}
+	// sequence returns the frames from the starting frame on, until one is
+	// missing, like ffmpeg reads them.
+	sequence := func(t *testing.T, v VHS) []string {
+		t.Helper()
+		var frames []string
+		for frame := v.Options.Video.StartingFrame; ; frame++ {
+			bts, err := os.ReadFile(filepath.Join(v.Options.Video.Input, fmt.Sprintf(textFrameFormat, frame)))
+			if err != nil {
+				return frames
+			}
+			frames = append(frames, string(bts))
+		}
+	}
+
+	t.Run("frames", func(t *testing.T) {
+		v := setup(t)
+		v.Options.Video.TrimStart = Trim{Frames: 1}
+		v.Options.Video.TrimEnd = Trim{Frames: 2}
+		requireNoErr(t, v.ApplyTrim())
+		if v.totalFrames != 3 {
+			t.Errorf("expected 3 frames, got %d", v.totalFrames)
+		}
+		if got := sequence(t, v); !reflect.DeepEqual(got, []string{"2", "3", "4"}) {
+			t.Errorf("expected frames 2 to 4, got %v", got)
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		v := setup(t)
+		// Two frames at the default framerate of 50.
+		v.Options.Video.TrimEnd = Trim{Duration: 40 * time.Millisecond}
+		requireNoErr(t, v.ApplyTrim())
+		if got := sequence(t, v); !reflect.DeepEqual(got, []string{"1", "2", "3", "4"}) {
+			t.Errorf("expected frames 1 to 4, got %v", got)
+		}
+	})
+
+	t.Run("loop offset and boomerang", func(t *testing.T) {
+		v := setup(t)
+		v.Options.Video.TrimStart = Trim{Frames: 1}
+		v.Options.Video.TrimEnd = Trim{Frames: 1}
+		v.Options.LoopOffset = 50
+		v.Options.Video.Boomerang = true
+		requireNoErr(t, v.ApplyTrim())
+		requireNoErr(t, v.ApplyLoopOffset())
+		requireNoErr(t, v.ApplyBoomerang())
+		want := []string{"4", "5", "2", "3", "2", "5"}
+		if got := sequence(t, v); !reflect.DeepEqual(got, want) {
+			t.Errorf("expected frames %v, got %v", want, got)
+		}
+	})
+
+	t.Run("everything", func(t *testing.T) {
+		v := setup(t)
+		v.Options.Video.TrimStart = Trim{Frames: 3}
+		v.Options.Video.TrimEnd = Trim{Frames: 3}
+		if err := v.ApplyTrim(); err == nil {
+			t.Error("expected an error when trimming every frame")
+		}
+	})
+}
+
+func TestDuration(t *testing.T) {
+	v := New()
+	t.Cleanup(func() { _ = v.Cleanup() })
+	v.totalFrames = 125
+
+	if v.TotalFrames() != 125 {
+		t.Errorf("expected 125 frames, got %d", v.TotalFrames())
+	}
+	// 125 frames at the default framerate of 50.
+	if got := v.Duration(); got != 2500*time.Millisecond {
+		t.Errorf("expected a duration of 2.5s, got %s", got)
+	}
+	v.Options.Video.CaptureFramerate = 25
+	v.Options.Video.PlaybackSpeed = 2
+	if got := v.Duration(); got != 2500*time.Millisecond {
+		t.Errorf("expected a duration of 2.5s at twice the speed, got %s", got)
+	}
+}
+
+func TestMakePoster(t *testing.T) {
+	opts := testVideoOptions(t)
+	if MakePoster(opts, 10) != nil {
+		t.Error("expected no poster by default")
+	}
+
+	opts.Poster = filepath.Join(t.TempDir(), "poster.png")
+	opts.StartingFrame = 3
+	opts.Captions = []TimedCaption{{"one", 0, 0.1, 0}, {"two", 0.1, 0.5, 1}}
+	for frame, want := range map[float64]int{0: 4, 50: 8, 100: 12} {
+		opts.PosterFrame = frame
+		args := strings.Join(MakePoster(opts, 10).Args, " ")
+		if !strings.Contains(args, fmt.Sprintf("-start_number %d ", want)) {
+			t.Errorf("expected the poster at %v%% to be frame %d, got: %s", frame, want, args)
+		}
+		if !strings.HasSuffix(args, "-frames:v 1 -update 1 "+opts.Poster) {
+			t.Errorf("expected a single frame to be rendered, got: %s", args)
+		}
+	}
+
+	// The caption shown on the poster frame is moved to the start.
+	opts.PosterFrame = 0
+	args := strings.Join(MakePoster(opts, 10).Args, " ")
+	if !strings.Contains(args, ":enable='gte(t,0.000)*lt(t,0.080)'") || strings.Contains(args, "caption-1.txt") {
+		t.Errorf("expected only the first caption to be shown, got: %s", args)
+	}
+
+	// The second caption is read from its own file, which the videos read
+	// too, even once it's the only one shown.
+	opts.PosterFrame = 100
+	args = strings.Join(MakePoster(opts, 10).Args, " ")
+	if !strings.Contains(args, "caption-1.txt") || strings.Contains(args, "caption-0.txt") {
+		t.Errorf("expected only the second caption to be shown, got: %s", args)
+	}
+
+	// The FFmpegArgs are passed to the poster too, before the single frame.
+	extraArgs := make([]string, 1, 2)
+	extraArgs[0] = "-dither"
+	opts.ExtraArgs = extraArgs
+	args = strings.Join(MakePoster(opts, 10).Args, " ")
+	if !strings.HasSuffix(args, "-dither -frames:v 1 -update 1 "+opts.Poster) {
+		t.Errorf("expected the FFmpegArgs before the single frame, got: %s", args)
+	}
+	if got := opts.ExtraArgs[:cap(opts.ExtraArgs)]; got[1] != "" {
+		t.Errorf("expected the FFmpegArgs not to be changed, got %q", got)
+	}
+}
+
+func TestParseCrop(t *testing.T) {
+	got, err := parseCrop("10 20 400 200")
+	requireNoErr(t, err)
+	if want := (Crop{X: 10, Y: 20, Width: 400, Height: 200}); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	for _, s := range []string{"0 0 400", "0 0 400 200 1", "0 0 0 200", "-1 0 400 200", "a b c d"} {
+		if _, err := parseCrop(s); err == nil {
+			t.Errorf("expected an error for %s", s)
+		}
+	}
+}
+
+func TestParseTrim(t *testing.T) {
+	for s, want := range map[string]Trim{
+		"10":    {Frames: 10},
+		"0":     {},
+		"1.5s":  {Duration: 1500 * time.Millisecond},
+		"500ms": {Duration: 500 * time.Millisecond},
+	} {
+		got, err := parseTrim(s)
+		requireNoErr(t, err)
+		if got != want {
+			t.Errorf("expected %s to be %+v, got %+v", s, want, got)
+		}
+	}
+	for _, s := range []string{"-1", "1.5", "-2s", "end"} {
+		if _, err := parseTrim(s); err == nil {
+			t.Errorf("expected an error for %s", s)
+		}
+	}
+}
+
+func TestFrameFormat(t *testing.T) {
+	// Frame numbers around the 5 digit boundary, including the ones the loop
+	// offset moves past the total number of frames.
+	const totalFrames = 99_998
+	var frames []int
+	for frame := totalFrames - 2; frame <= totalFrames+4; frame++ {
+		frames = append(frames, frame)
+	}
+
+	var names []string
+	for _, frame := range frames {
+		name := fmt.Sprintf(textFrameFormat, frame)
+		var got int
+		if _, err := fmt.Sscanf(name, textFrameFormat, &got); err != nil || got != frame {
+			t.Fatalf("expected %s to be frame %d, got %d (%v)", name, frame, got, err)
+		}
+		names = append(names, name)
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("expected frame names to sort in order, got %v", names)
+	}
+
+	args := strings.Join(buildFFopts(testVideoOptions(t), "out.gif"), " ")
+	if !strings.Contains(args, textFrameFormat) || !strings.Contains(args, cursorFrameFormat) {
+		t.Errorf("expected ffmpeg to read the same frame names, got: %s", args)
+	}
+}
+
+func TestVariantPath(t *testing.T) {
+	tests := map[string]string{
+		"":               "",
+		"demo.gif":       "demo-dark.gif",
+		"out/demo.webm":  "out/demo-dark.webm",
+		"frames/":        "frames-dark/",
+		"golden.test":    "golden-dark.test",
+		"demo.light.gif": "demo.light-dark.gif",
+	}
+	for path, want := range tests {
+		if got := variantPath(path, "dark"); got != want {
+			t.Errorf("variantPath(%q): expected %q, got %q", path, want, got)
+		}
+	}
+	if got := variantPath("demo.gif", ""); got != "demo.gif" {
+		t.Errorf("expected the path to be unchanged without a variant, got %q", got)
+	}
+}
+
+func TestBuildFFoptsPixelFormat(t *testing.T) {
+	opts := testVideoOptions(t)
+	for _, target := range []string{"out.mp4", "out.webm"} {
+		if args := strings.Join(buildFFopts(opts, target), " "); !strings.Contains(args, "-pix_fmt yuv420p") {
+			t.Errorf("expected %s to be yuv420p, got: %s", target, args)
+		}
+	}
+
+	opts.PixelFormat = "yuv444p"
+	if args := strings.Join(buildFFopts(opts, "out.mp4"), " "); !strings.Contains(args, "-pix_fmt yuv444p") {
+		t.Errorf("expected the pixel format to be overridden, got: %s", args)
+	}
+}
+
+func TestBuildFFoptsEvenDimensions(t *testing.T) {
+	opts := testVideoOptions(t)
+	if args := strings.Join(buildFFopts(opts, "out.mp4"), " "); strings.Contains(args, "[even]") {
+		t.Errorf("expected even dimensions not to be padded, got: %s", args)
+	}
+
+	// Every output is padded alike.
+	opts.Style.Width = 1201
+	for _, target := range []string{"out.gif", "out.mp4", "out.webm"} {
+		args := strings.Join(buildFFopts(opts, target), " ")
+		if !strings.Contains(args, "pad=1202:600:0:0:#171717[even]") {
+			t.Errorf("expected %s to be padded to even dimensions, got: %s", target, args)
+		}
+	}
+
+	// The crop is padded rather than the terminal.
+	opts.Crop = Crop{Width: 301, Height: 99}
+	if args := strings.Join(buildFFopts(opts, "out.mp4"), " "); !strings.Contains(args, "pad=302:100:0:0:#171717[even]") {
+		t.Errorf("expected the crop to be padded to even dimensions, got: %s", args)
+	}
+}
+
+func TestBuildFFoptsFrameFormat(t *testing.T) {
+	opts := testVideoOptions(t)
+	opts.FrameFormat = frameJPEG
+
+	args := strings.Join(buildFFopts(opts, "out.gif"), " ")
+	for _, want := range []string{filepath.Join(opts.Input, jpegTextFrameFormat), filepath.Join(opts.Input, cursorFrameFormat)} {
+		if !strings.Contains(args, "-i "+want) {
+			t.Errorf("expected the frames %s to be read, got: %s", want, args)
+		}
+	}
+	if opts.frameMIME() != "image/jpeg" {
+		t.Errorf("expected the frames to be captured as JPEG, got %s", opts.frameMIME())
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWaitTimeout = 15 * time.Second
+	waitTick           = 10 * time.Millisecond
+
+	// waitTailLines is the number of lines of the terminal included in the
+	// error when a Wait times out.
+	waitTailLines = 5
+)
+
+// errInterrupted is returned by WaitPattern when the tape is interrupted while
+// it waits.
+var errInterrupted = errors.New("interrupted while waiting")
+
+var errNoWaitPattern = errors.New("no pattern to wait for: the shell prompt could not be detected, use `Set WaitPattern`")
+
+// Buffer returns the lines currently visible in the terminal.
+func (vhs *VHS) Buffer() ([]string, error) {
+	buf, err := vhs.Page.Eval("() => Array(term.rows).fill(0).map((e, i) => term.buffer.active.getLine(term.buffer.active.viewportY + i).translateToString().trimEnd())")
+	if err != nil {
+		return nil, fmt.Errorf("could not read terminal buffer: %w", err)
+	}
+
+	lines := make([]string, 0, len(buf.Value.Arr()))
+	for _, line := range buf.Value.Arr() {
+		lines = append(lines, line.Str())
+	}
+	return lines, nil
+}
+
+// CurrentLine returns the line of the terminal the cursor is on.
+func (vhs *VHS) CurrentLine() (string, error) {
+	line, err := vhs.Page.Eval("() => term.buffer.active.getLine(term.buffer.active.viewportY + term.buffer.active.cursorY).translateToString().trimEnd()")
+	if err != nil {
+		return "", fmt.Errorf("could not read terminal buffer: %w", err)
+	}
+	return line.Value.Str(), nil
+}
+
+// capturePrompt waits for the shell to print its prompt and returns it. It
+// returns an empty string if nothing is printed before the WaitTimeout.
+func (vhs *VHS) capturePrompt() string {
+	deadline := time.Now().Add(vhs.Options.WaitTimeout)
+	for time.Now().Before(deadline) {
+		line, err := vhs.CurrentLine()
+		if err != nil {
+			return ""
+		}
+		if strings.TrimSpace(line) != "" {
+			return line
+		}
+		time.Sleep(waitTick)
+	}
+	return ""
+}
+
+// promptPattern returns the pattern matching a screen which ends with the
+// given prompt.
+func promptPattern(prompt string) string {
+	return regexp.QuoteMeta(strings.TrimSpace(prompt)) + "$"
+}
+
+// WaitPattern polls the terminal buffer until it matches the pattern. It
+// returns an error if the pattern does not match before the timeout elapses.
+//
+// The pattern is matched against the text of the screen with trailing blank
+// lines removed, so `$` matches the end of the last line with any output.
+func (vhs *VHS) WaitPattern(pattern *regexp.Regexp, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		lines, err := vhs.Buffer()
+		if err != nil {
+			return err
+		}
+		screen := strings.TrimRight(strings.Join(lines, "\n"), "\n")
+		if pattern.MatchString(screen) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for /%s/, last output:\n%s",
+				timeout, pattern, tail(screen, waitTailLines))
+		}
+		if err := vhs.sleep(waitTick); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep pauses for the duration, or until the tape is interrupted, in which
+// case it returns errInterrupted.
+func (vhs *VHS) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-vhs.interrupt:
+		return errInterrupted
+	}
+}
+
+// tail returns the last n lines of s.
+func tail(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
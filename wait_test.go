@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/charmbracelet/vhs/parser"
+)
+
+func TestPromptPattern(t *testing.T) {
+	pattern := regexp.MustCompile(promptPattern("> "))
+
+	tests := []struct {
+		screen string
+		want   bool
+	}{
+		{screen: ">", want: true},
+		{screen: "> ls\nfile.txt\n>", want: true},
+		{screen: "> ls", want: false},
+		{screen: "> sleep 5\n>\n> echo done", want: false},
+	}
+
+	for _, tc := range tests {
+		if got := pattern.MatchString(tc.screen); got != tc.want {
+			t.Errorf("match %q: expected %t, got %t", tc.screen, tc.want, got)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	if got := tail("a\nb\nc", 2); got != "b\nc" {
+		t.Errorf("expected last 2 lines, got %q", got)
+	}
+	if got := tail("a\nb", 5); got != "a\nb" {
+		t.Errorf("expected all lines, got %q", got)
+	}
+}
+
+func TestExecuteWait(t *testing.T) {
+	t.Run("no pattern", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+
+		ExecuteWait(parser.Command{}, &v)
+		if len(v.Errors) != 1 || !errors.Is(v.Errors[0], errNoWaitPattern) {
+			t.Fatalf("expected errNoWaitPattern, got %v", v.Errors)
+		}
+	})
+
+	t.Run("invalid pattern setting", func(t *testing.T) {
+		v := New()
+		t.Cleanup(func() { _ = v.Cleanup() })
+
+		ExecuteSetWaitPattern(parser.Command{Args: "["}, &v)
+		if len(v.Errors) != 1 {
+			t.Fatalf("expected an error for an invalid pattern, got %v", v.Errors)
+		}
+		if v.Options.WaitPattern != "" {
+			t.Errorf("expected WaitPattern to be unchanged, got %q", v.Options.WaitPattern)
+		}
+	})
+}